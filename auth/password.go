@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2Params はargon2idのパラメータ。
+type Argon2Params struct {
+	Time    uint32
+	Memory  uint32 // KiB
+	Threads uint8
+	KeyLen  uint32
+	SaltLen uint32
+}
+
+// DefaultParams は新しくハッシュ化するときに使うパラメータ。
+// 変更しても既存のハッシュは検証でき、次回ログイン時にこの値で再ハッシュされる。
+var DefaultParams = Argon2Params{
+	Time:    1,
+	Memory:  64 * 1024,
+	Threads: 4,
+	KeyLen:  32,
+	SaltLen: 16,
+}
+
+// legacyParams は `salt:hash` 形式で保存されていたハッシュのパラメータ。
+var legacyParams = Argon2Params{
+	Time:    1,
+	Memory:  64 * 1024,
+	Threads: 4,
+	KeyLen:  32,
+	SaltLen: 16,
+}
+
+var ErrInvalidHash = errors.New("パスワードハッシュの形式が不正です")
+
+// HashPassword は DefaultParams でパスワードをハッシュ化し、
+// `$argon2id$v=19$m=...,t=...,p=...$salt$hash` 形式で返す。
+func HashPassword(password string) (string, error) {
+	return hashPassword(password, DefaultParams)
+}
+
+func hashPassword(password string, p Argon2Params) (string, error) {
+	salt := make([]byte, p.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	hash := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, p.KeyLen)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Time, p.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(hash)), nil
+}
+
+// VerifyPassword はパスワードがハッシュと一致するかを定数時間で比較する。
+// 一致し、かつハッシュが旧形式または DefaultParams と異なるパラメータの場合は needsRehash が true になる。
+func VerifyPassword(password, encodedHash string) (ok, needsRehash bool) {
+	p, salt, expected, err := decodeHash(encodedHash)
+	if err != nil {
+		return false, false
+	}
+
+	hash := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, uint32(len(expected)))
+	if subtle.ConstantTimeCompare(hash, expected) != 1 {
+		return false, false
+	}
+
+	legacy := !strings.HasPrefix(encodedHash, "$")
+	return true, legacy || p != DefaultParams
+}
+
+// decodeHash はPHC形式と旧 `salt:hash` 形式のどちらも読み取る。
+func decodeHash(encodedHash string) (Argon2Params, []byte, []byte, error) {
+	if !strings.HasPrefix(encodedHash, "$") {
+		saltEncoded, hashEncoded, found := strings.Cut(encodedHash, ":")
+		if !found {
+			return Argon2Params{}, nil, nil, ErrInvalidHash
+		}
+		return decodeSaltAndHash(legacyParams, saltEncoded, hashEncoded)
+	}
+
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, hash
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return Argon2Params{}, nil, nil, ErrInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Argon2Params{}, nil, nil, ErrInvalidHash
+	}
+
+	var p Argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Time, &p.Threads); err != nil {
+		return Argon2Params{}, nil, nil, ErrInvalidHash
+	}
+	if p.Memory == 0 || p.Time == 0 || p.Threads == 0 {
+		return Argon2Params{}, nil, nil, ErrInvalidHash
+	}
+
+	return decodeSaltAndHash(p, parts[4], parts[5])
+}
+
+func decodeSaltAndHash(p Argon2Params, saltEncoded, hashEncoded string) (Argon2Params, []byte, []byte, error) {
+	salt, err := base64.RawStdEncoding.DecodeString(saltEncoded)
+	if err != nil || len(salt) == 0 {
+		return Argon2Params{}, nil, nil, ErrInvalidHash
+	}
+
+	hash, err := base64.RawStdEncoding.DecodeString(hashEncoded)
+	if err != nil || len(hash) == 0 {
+		return Argon2Params{}, nil, nil, ErrInvalidHash
+	}
+
+	p.SaltLen = uint32(len(salt))
+	p.KeyLen = uint32(len(hash))
+	return p, salt, hash, nil
+}
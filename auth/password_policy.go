@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// PasswordPolicy はパスワードに求める条件。
+type PasswordPolicy struct {
+	MinLength       int                 // 最小文字数（バイト数ではなく文字数で数える）
+	commonPasswords map[string]struct{} // 使用を禁止する よく使われるパスワード（小文字）
+}
+
+// NewPasswordPolicy は1行に1つのパスワードを書いたファイルを読み込んでポリシーを作る。
+// commonPasswordsPath が空の場合は一覧による確認を行わない。
+func NewPasswordPolicy(minLength int, commonPasswordsPath string) (*PasswordPolicy, error) {
+	policy := &PasswordPolicy{MinLength: minLength, commonPasswords: make(map[string]struct{})}
+	if commonPasswordsPath == "" {
+		return policy, nil
+	}
+
+	f, err := os.Open(commonPasswordsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		policy.commonPasswords[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// Validate はポリシーに違反している内容をフォームに表示する文言で返す。違反がなければ nil を返す。
+func (p *PasswordPolicy) Validate(username, password string) []string {
+	var problems []string
+	if utf8.RuneCountInString(password) < p.MinLength {
+		problems = append(problems, fmt.Sprintf("パスワードは%d文字以上にしてください", p.MinLength))
+	}
+	if username != "" && strings.EqualFold(password, username) {
+		problems = append(problems, "ユーザー名と同じパスワードは使えません")
+	}
+	if _, ok := p.commonPasswords[strings.ToLower(password)]; ok {
+		problems = append(problems, "よく使われていて推測されやすいパスワードです。別のパスワードにしてください")
+	}
+	return problems
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestPasswordPolicy(t *testing.T) *PasswordPolicy {
+	path := filepath.Join(t.TempDir(), "common_passwords.txt")
+	content := "# よく使われるパスワード一覧\npassword\n123456789\n\nQwertyuiop\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	policy, err := NewPasswordPolicy(8, path)
+	if err != nil {
+		t.Fatalf("NewPasswordPolicy() error = %v", err)
+	}
+	return policy
+}
+
+func TestPasswordPolicy_Validate(t *testing.T) {
+	policy := newTestPasswordPolicy(t)
+
+	tests := []struct {
+		name         string
+		username     string
+		password     string
+		wantProblems int
+	}{
+		{name: "条件を満たす", username: "alice", password: "correct horse", wantProblems: 0},
+		{name: "短すぎる", username: "alice", password: "a", wantProblems: 1},
+		{name: "マルチバイトは文字数で数える", username: "alice", password: "あいうえおかきく", wantProblems: 0},
+		{name: "マルチバイトで短すぎる", username: "alice", password: "あいうえお", wantProblems: 1},
+		{name: "ユーザー名と同じ", username: "alice_wonder", password: "Alice_Wonder", wantProblems: 1},
+		{name: "よく使われるパスワード", username: "alice", password: "123456789", wantProblems: 1},
+		{name: "よく使われるパスワード（大文字小文字の違いは無視）", username: "alice", password: "QWERTYUIOP", wantProblems: 1},
+		{name: "コメント行は一覧に含めない", username: "alice", password: "# よく使われるパスワード一覧", wantProblems: 0},
+		{name: "短くてよく使われる", username: "alice", password: "password", wantProblems: 1},
+		{name: "複数の違反", username: "pass", password: "pass", wantProblems: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := policy.Validate(tt.username, tt.password)
+			if len(got) != tt.wantProblems {
+				t.Errorf("Validate() = %v, want %d problems", got, tt.wantProblems)
+			}
+		})
+	}
+}
+
+func TestNewPasswordPolicy_MissingFile(t *testing.T) {
+	if _, err := NewPasswordPolicy(8, filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Errorf("NewPasswordPolicy() error = nil, want error for missing file")
+	}
+}
@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// legacyHash は旧 `salt:hash` 形式のハッシュを作る。
+func legacyHash(password string) string {
+	salt := []byte("0123456789abcdef")
+	hash := argon2.IDKey([]byte(password), salt, legacyParams.Time, legacyParams.Memory, legacyParams.Threads, legacyParams.KeyLen)
+	return base64.RawStdEncoding.EncodeToString(salt) + ":" + base64.RawStdEncoding.EncodeToString(hash)
+}
+
+func TestHashPassword_Format(t *testing.T) {
+	encoded, err := HashPassword("password123")
+	if err != nil {
+		t.Fatalf("HashPassword() error = %v", err)
+	}
+
+	if !strings.HasPrefix(encoded, "$argon2id$v=19$m=65536,t=1,p=4$") {
+		t.Errorf("HashPassword() = %v, want PHC format", encoded)
+	}
+
+	other, err := HashPassword("password123")
+	if err != nil {
+		t.Fatalf("HashPassword() error = %v", err)
+	}
+	if encoded == other {
+		t.Errorf("HashPassword() should use a random salt, got same hash twice")
+	}
+}
+
+func TestVerifyPassword(t *testing.T) {
+	current, err := HashPassword("password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	outdated, err := hashPassword("password123", Argon2Params{Time: 1, Memory: 8 * 1024, Threads: 1, KeyLen: 32, SaltLen: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name            string
+		password        string
+		encodedHash     string
+		wantOK          bool
+		wantNeedsRehash bool
+	}{
+		{
+			name:            "現在のパラメータで一致",
+			password:        "password123",
+			encodedHash:     current,
+			wantOK:          true,
+			wantNeedsRehash: false,
+		},
+		{
+			name:        "現在のパラメータで不一致",
+			password:    "wrongpassword",
+			encodedHash: current,
+			wantOK:      false,
+		},
+		{
+			name:            "古いパラメータで一致",
+			password:        "password123",
+			encodedHash:     outdated,
+			wantOK:          true,
+			wantNeedsRehash: true,
+		},
+		{
+			name:            "旧形式で一致",
+			password:        "password123",
+			encodedHash:     legacyHash("password123"),
+			wantOK:          true,
+			wantNeedsRehash: true,
+		},
+		{
+			name:        "旧形式で不一致",
+			password:    "wrongpassword",
+			encodedHash: legacyHash("password123"),
+			wantOK:      false,
+		},
+		{
+			name:        "未対応のアルゴリズム",
+			password:    "password123",
+			encodedHash: strings.Replace(current, "argon2id", "argon2i", 1),
+			wantOK:      false,
+		},
+		{
+			name:        "未対応のバージョン",
+			password:    "password123",
+			encodedHash: strings.Replace(current, "v=19", "v=16", 1),
+			wantOK:      false,
+		},
+		{
+			name:        "パラメータが壊れている",
+			password:    "password123",
+			encodedHash: strings.Replace(current, "m=65536,t=1,p=4", "m=x", 1),
+			wantOK:      false,
+		},
+		{
+			name:        "空文字列",
+			password:    "password123",
+			encodedHash: "",
+			wantOK:      false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, needsRehash := VerifyPassword(tt.password, tt.encodedHash)
+			if ok != tt.wantOK {
+				t.Errorf("VerifyPassword() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if needsRehash != tt.wantNeedsRehash {
+				t.Errorf("VerifyPassword() needsRehash = %v, want %v", needsRehash, tt.wantNeedsRehash)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret は RFC 6238 のテストベクタで使われる鍵 "12345678901234567890" のBase32表現。
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 Appendix B の値（SHA1）の下6桁
+	tests := []struct {
+		name string
+		unix int64
+		want string
+	}{
+		{name: "59秒", unix: 59, want: "287082"},
+		{name: "1111111109秒", unix: 1111111109, want: "081804"},
+		{name: "1111111111秒", unix: 1111111111, want: "050471"},
+		{name: "1234567890秒", unix: 1234567890, want: "005924"},
+		{name: "2000000000秒", unix: 2000000000, want: "279037"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TOTPCode(rfc6238Secret, time.Unix(tt.unix, 0))
+			if err != nil {
+				t.Fatalf("TOTPCode() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("TOTPCode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	issued := time.Unix(1111111111, 0)
+	code, err := TOTPCode(rfc6238Secret, issued)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		at       time.Time
+		wantOK   bool
+		wantStep int64
+	}{
+		{name: "同じステップ", code: code, at: issued, wantOK: true, wantStep: TOTPStep(issued)},
+		{name: "1ステップ後は許容", code: code, at: issued.Add(30 * time.Second), wantOK: true, wantStep: TOTPStep(issued)},
+		{name: "1ステップ前は許容", code: code, at: issued.Add(-30 * time.Second), wantOK: true, wantStep: TOTPStep(issued)},
+		{name: "2ステップ後は拒否", code: code, at: issued.Add(90 * time.Second), wantOK: false},
+		{name: "コードが違う", code: "000000", at: issued, wantOK: false},
+		{name: "桁数が違う", code: code[:5], at: issued, wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := ValidateTOTP(rfc6238Secret, tt.code, tt.at)
+			if ok != tt.wantOK {
+				t.Errorf("ValidateTOTP() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && step != tt.wantStep {
+				t.Errorf("ValidateTOTP() step = %v, want %v", step, tt.wantStep)
+			}
+		})
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	got := TOTPURI("maiyumi", "alice", rfc6238Secret)
+	want := "otpauth://totp/maiyumi:alice?algorithm=SHA1&digits=6&issuer=maiyumi&period=30&secret=" + rfc6238Secret
+	if got != want {
+		t.Errorf("TOTPURI() = %v, want %v", got, want)
+	}
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes()
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes() error = %v", err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Errorf("GenerateRecoveryCodes() len = %v, want %v", len(codes), recoveryCodeCount)
+	}
+
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("GenerateRecoveryCodes() code = %v, want xxxxx-xxxxx", code)
+		}
+		if seen[code] {
+			t.Errorf("GenerateRecoveryCodes() returned duplicate code %v", code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestHashRecoveryCode(t *testing.T) {
+	want := HashRecoveryCode("abcde-fghij")
+	for _, input := range []string{"abcde-fghij", "ABCDE-FGHIJ", "abcdefghij", " abcde fghij "} {
+		if got := HashRecoveryCode(input); got != want {
+			t.Errorf("HashRecoveryCode(%q) = %v, want %v", input, got, want)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+// Package export はユーザーデータの書き出し・読み込みに使う共通の形式を定義する。
+package export
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/Kamekure-Maisuke/maiyumi/model"
+)
+
+// FormatVersion は書き出し形式のバージョン。列を変えたときに上げる。
+// 2: スコアを評価軸ごとに持つ形式に変更
+const FormatVersion = 2
+
+// utf8BOM はExcelで文字化けせずに開けるようCSVの先頭に付ける。
+const utf8BOM = "\ufeff"
+
+// User はパスワードハッシュや二要素認証の秘密鍵を含まないユーザー情報。
+type User struct {
+	Username         string `json:"username"`
+	TwoFactorEnabled bool   `json:"two_factor_enabled"`
+	CreatedAt        string `json:"created_at"`
+}
+
+// Dimension はユーザーが定義した評価軸。
+type Dimension struct {
+	Name         string `json:"name"`
+	MinScore     int    `json:"min_score"`
+	MaxScore     int    `json:"max_score"`
+	DisplayOrder int    `json:"display_order"`
+}
+
+// Score の Value は未評価の場合 nil になる。
+type Score struct {
+	Dimension string `json:"dimension"`
+	Value     *int   `json:"value"`
+	Total     int    `json:"total"`
+}
+
+// Talent の Scores は Archive.Dimensions と同じ順に並ぶ。
+type Talent struct {
+	ID          int      `json:"id"`
+	Name        string   `json:"name"`
+	Affiliation string   `json:"affiliation"`
+	Scores      []Score  `json:"scores"`
+	Tags        []string `json:"tags"`
+	IsFavorite  bool     `json:"is_favorite"`
+	CreatedAt   string   `json:"created_at"`
+}
+
+// Adjustment の TalentID は同じアーカイブ内の Talent.ID を指す。
+type Adjustment struct {
+	ID         int    `json:"id"`
+	TalentID   int    `json:"talent_id"`
+	TalentName string `json:"talent_name"`
+	Dimension  string `json:"dimension"`
+	Points     int    `json:"points"`
+	Reason     string `json:"reason"`
+	CreatedAt  string `json:"created_at"`
+}
+
+// Archive は書き出すデータ一式。
+type Archive struct {
+	Version     int          `json:"version"`
+	ExportedAt  time.Time    `json:"exported_at"`
+	User        User         `json:"user"`
+	Dimensions  []Dimension  `json:"dimensions"`
+	Talents     []Talent     `json:"talents"`
+	Adjustments []Adjustment `json:"adjustments"`
+}
+
+// NewArchive はリポジトリから取得したモデルを書き出し用の形式に変換する。
+func NewArchive(user *model.User, dimensions []model.ScoreDimension, talents []model.Talent, adjustments []model.Adjustment, exportedAt time.Time) *Archive {
+	a := &Archive{
+		Version:    FormatVersion,
+		ExportedAt: exportedAt.UTC(),
+		User: User{
+			Username:         user.Username,
+			TwoFactorEnabled: user.TOTPSecret != "",
+			CreatedAt:        user.CreatedAt,
+		},
+		Dimensions:  NewDimensions(dimensions),
+		Talents:     NewTalents(talents),
+		Adjustments: make([]Adjustment, 0, len(adjustments)),
+	}
+
+	names := make(map[int]string, len(talents))
+	for _, t := range talents {
+		names[t.ID] = t.Name
+	}
+
+	for _, adj := range adjustments {
+		a.Adjustments = append(a.Adjustments, Adjustment{
+			ID:         adj.ID,
+			TalentID:   adj.TalentID,
+			TalentName: names[adj.TalentID],
+			Dimension:  adj.DimensionName,
+			Points:     adj.Points,
+			Reason:     adj.Reason,
+			CreatedAt:  adj.CreatedAt,
+		})
+	}
+
+	return a
+}
+
+// NewDimensions は評価軸のモデルを書き出し用の形式に変換する。
+func NewDimensions(dimensions []model.ScoreDimension) []Dimension {
+	converted := make([]Dimension, 0, len(dimensions))
+	for _, d := range dimensions {
+		converted = append(converted, Dimension{
+			Name:         d.Name,
+			MinScore:     d.MinScore,
+			MaxScore:     d.MaxScore,
+			DisplayOrder: d.DisplayOrder,
+		})
+	}
+	return converted
+}
+
+// NewTalents はタレントのモデルを書き出し用の形式に変換する。
+func NewTalents(talents []model.Talent) []Talent {
+	converted := make([]Talent, 0, len(talents))
+	for _, t := range talents {
+		scores := make([]Score, 0, len(t.Scores))
+		for _, s := range t.Scores {
+			score := Score{Dimension: s.Name, Total: s.Total}
+			if s.Rated {
+				score.Value = &s.Value
+			}
+			scores = append(scores, score)
+		}
+		tags := make([]string, 0, len(t.Tags))
+		for _, tag := range t.Tags {
+			tags = append(tags, tag.Name)
+		}
+		converted = append(converted, Talent{
+			ID:          t.ID,
+			Name:        t.Name,
+			Affiliation: t.Affiliation.String,
+			Scores:      scores,
+			Tags:        tags,
+			IsFavorite:  t.IsFavorite,
+			CreatedAt:   t.CreatedAt,
+		})
+	}
+	return converted
+}
+
+// totalColumnSuffix は調整後のスコアの列名に付ける。
+const totalColumnSuffix = "_total"
+
+// CSVの列。
+var (
+	UserCSVHeader       = []string{"username", "two_factor_enabled", "created_at"}
+	DimensionCSVHeader  = []string{"name", "min_score", "max_score", "display_order"}
+	AdjustmentCSVHeader = []string{"id", "talent_id", "talent_name", "dimension", "points", "reason", "created_at"}
+)
+
+// TalentCSVHeader はタレントのCSVの列を返す。評価軸ごとに初期値と調整後の値の列があり、
+// 初期値の列名は評価軸の名前なので、書き出したファイルをそのまま取り込める。
+func TalentCSVHeader(dimensions []Dimension) []string {
+	header := []string{"id", "name", "affiliation"}
+	for _, d := range dimensions {
+		header = append(header, d.Name)
+	}
+	header = append(header, "is_favorite")
+	for _, d := range dimensions {
+		header = append(header, d.Name+totalColumnSuffix)
+	}
+	return append(header, "created_at")
+}
+
+// cell はCSVやXLSXの1セル。number が true の場合、XLSXでは数値として書き出す。
+type cell struct {
+	value  string
+	number bool
+}
+
+func textCells(values ...string) []cell {
+	cells := make([]cell, len(values))
+	for i, v := range values {
+		cells[i] = cell{value: v}
+	}
+	return cells
+}
+
+func cellValues(cells []cell) []string {
+	values := make([]string, len(cells))
+	for i, c := range cells {
+		values[i] = c.value
+	}
+	return values
+}
+
+func (u User) csvRecord() []string {
+	return []string{u.Username, strconv.FormatBool(u.TwoFactorEnabled), u.CreatedAt}
+}
+
+func (d Dimension) csvRecord() []string {
+	return []string{d.Name, strconv.Itoa(d.MinScore), strconv.Itoa(d.MaxScore), strconv.Itoa(d.DisplayOrder)}
+}
+
+// cells は TalentCSVHeader の列の順にセルを返す。未評価のスコアは空にする。
+func (t Talent) cells() []cell {
+	cells := []cell{{value: strconv.Itoa(t.ID), number: true}, {value: t.Name}, {value: t.Affiliation}}
+	for _, s := range t.Scores {
+		if s.Value == nil {
+			cells = append(cells, cell{})
+			continue
+		}
+		cells = append(cells, cell{value: strconv.Itoa(*s.Value), number: true})
+	}
+	cells = append(cells, cell{value: strconv.FormatBool(t.IsFavorite)})
+	for _, s := range t.Scores {
+		cells = append(cells, cell{value: strconv.Itoa(s.Total), number: true})
+	}
+	return append(cells, cell{value: t.CreatedAt})
+}
+
+func (a Adjustment) csvRecord() []string {
+	return []string{
+		strconv.Itoa(a.ID), strconv.Itoa(a.TalentID), a.TalentName,
+		a.Dimension, strconv.Itoa(a.Points), a.Reason, a.CreatedAt,
+	}
+}
+
+// writeCSV はBOM付きUTF-8でヘッダーと行を書き出す。
+func writeCSV(w io.Writer, header []string, records [][]string) error {
+	if _, err := io.WriteString(w, utf8BOM); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(records); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// WriteTalentsCSV はタレント一覧をCSVで書き出す。
+func WriteTalentsCSV(w io.Writer, dimensions []Dimension, talents []Talent) error {
+	records := make([][]string, len(talents))
+	for i, t := range talents {
+		records[i] = cellValues(t.cells())
+	}
+	return writeCSV(w, TalentCSVHeader(dimensions), records)
+}
+
+func writeDimensionsCSV(w io.Writer, dimensions []Dimension) error {
+	records := make([][]string, len(dimensions))
+	for i, d := range dimensions {
+		records[i] = d.csvRecord()
+	}
+	return writeCSV(w, DimensionCSVHeader, records)
+}
+
+// WriteAdjustmentsCSV は調整履歴をCSVで書き出す。
+func WriteAdjustmentsCSV(w io.Writer, adjustments []Adjustment) error {
+	records := make([][]string, len(adjustments))
+	for i, a := range adjustments {
+		records[i] = a.csvRecord()
+	}
+	return writeCSV(w, AdjustmentCSVHeader, records)
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// WriteTalentsJSON はタレント一覧をJSONの配列で書き出す。
+func WriteTalentsJSON(w io.Writer, talents []Talent) error {
+	return writeJSON(w, talents)
+}
+
+// WriteZIP はアーカイブ全体のJSONと、ユーザー・評価軸・タレント・調整履歴それぞれのJSONとCSVをZIPにまとめて書き出す。
+func WriteZIP(w io.Writer, a *Archive) error {
+	zw := zip.NewWriter(w)
+
+	files := []struct {
+		name  string
+		write func(io.Writer) error
+	}{
+		{"data.json", func(w io.Writer) error { return writeJSON(w, a) }},
+		{"user.json", func(w io.Writer) error { return writeJSON(w, a.User) }},
+		{"user.csv", func(w io.Writer) error { return writeCSV(w, UserCSVHeader, [][]string{a.User.csvRecord()}) }},
+		{"dimensions.json", func(w io.Writer) error { return writeJSON(w, a.Dimensions) }},
+		{"dimensions.csv", func(w io.Writer) error { return writeDimensionsCSV(w, a.Dimensions) }},
+		{"talents.json", func(w io.Writer) error { return WriteTalentsJSON(w, a.Talents) }},
+		{"talents.csv", func(w io.Writer) error { return WriteTalentsCSV(w, a.Dimensions, a.Talents) }},
+		{"adjustments.json", func(w io.Writer) error { return writeJSON(w, a.Adjustments) }},
+		{"adjustments.csv", func(w io.Writer) error { return WriteAdjustmentsCSV(w, a.Adjustments) }},
+	}
+
+	for _, f := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     f.name,
+			Method:   zip.Deflate,
+			Modified: a.ExportedAt,
+		})
+		if err != nil {
+			return err
+		}
+		if err := f.write(fw); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
@@ -0,0 +1,213 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kamekure-Maisuke/maiyumi/model"
+)
+
+// testDimensions は初期の評価軸にIDを振ったもの。
+func testDimensions() []model.ScoreDimension {
+	dimensions := make([]model.ScoreDimension, len(model.DefaultScoreDimensions))
+	for i, d := range model.DefaultScoreDimensions {
+		d.ID = i + 1
+		d.UserID = 1
+		dimensions[i] = d
+	}
+	return dimensions
+}
+
+// testScores は testDimensions の順にスコアを作る。totals を省略した場合は初期値と同じにする。
+func testScores(values []int, totals ...int) []model.Score {
+	dimensions := testDimensions()
+	scores := make([]model.Score, len(values))
+	for i, v := range values {
+		total := v
+		if i < len(totals) {
+			total = totals[i]
+		}
+		scores[i] = model.Score{DimensionID: dimensions[i].ID, Name: dimensions[i].Name, Value: v, Total: total, Rated: true}
+	}
+	return scores
+}
+
+func newTestArchive() *Archive {
+	user := &model.User{
+		ID:         1,
+		Username:   "alice",
+		Password:   "$argon2id$v=19$m=65536,t=1,p=4$c2FsdA$aGFzaA",
+		TOTPSecret: "SECRET",
+		CreatedAt:  "2025-01-01T00:00:00Z",
+	}
+	unrated := testScores([]int{1, 2, 3})
+	unrated[2] = model.Score{DimensionID: 3, Name: "才能", Total: 2}
+	talents := []model.Talent{
+		{ID: 10, UserID: 1, Name: "タレント, \"A\"", Affiliation: sql.NullString{String: "事務所", Valid: true}, Scores: testScores([]int{5, 6, 7}, 6, 6, 7), Tags: []model.Tag{{ID: 1, Name: "推し"}}, IsFavorite: true},
+		{ID: 11, UserID: 1, Name: "タレントB", Scores: unrated},
+	}
+	adjustments := []model.Adjustment{
+		{ID: 100, TalentID: 10, DimensionID: 1, DimensionName: "美しさ", Points: 1, Reason: "改行を\n含む理由"},
+	}
+	return NewArchive(user, testDimensions(), talents, adjustments, time.Date(2025, 2, 3, 4, 5, 6, 0, time.UTC))
+}
+
+func readZIP(t *testing.T, data []byte) map[string][]byte {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("zip.NewReader() error = %v", err)
+	}
+
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = b
+	}
+	return files
+}
+
+func TestNewArchive(t *testing.T) {
+	a := newTestArchive()
+
+	if !a.User.TwoFactorEnabled {
+		t.Errorf("NewArchive() TwoFactorEnabled = false, want true")
+	}
+	if len(a.Talents) != 2 || len(a.Adjustments) != 1 {
+		t.Fatalf("NewArchive() talents = %d, adjustments = %d, want 2, 1", len(a.Talents), len(a.Adjustments))
+	}
+	if a.Talents[1].Affiliation != "" {
+		t.Errorf("NewArchive() affiliation = %q, want empty for NULL", a.Talents[1].Affiliation)
+	}
+	if a.Adjustments[0].TalentName != "タレント, \"A\"" {
+		t.Errorf("NewArchive() talent_name = %v, want %v", a.Adjustments[0].TalentName, "タレント, \"A\"")
+	}
+	if a.Adjustments[0].Dimension != "美しさ" {
+		t.Errorf("NewArchive() dimension = %v, want %v", a.Adjustments[0].Dimension, "美しさ")
+	}
+	if len(a.Dimensions) != 3 || a.Dimensions[0].Name != "美しさ" {
+		t.Errorf("NewArchive() dimensions = %+v", a.Dimensions)
+	}
+	if len(a.Talents[0].Tags) != 1 || a.Talents[0].Tags[0] != "推し" {
+		t.Errorf("NewArchive() tags = %v, want [推し]", a.Talents[0].Tags)
+	}
+	if a.Talents[1].Tags == nil {
+		t.Errorf("NewArchive() tags = nil, want empty slice for talent without tags")
+	}
+	if s := a.Talents[1].Scores[2]; s.Value != nil || s.Total != 2 {
+		t.Errorf("NewArchive() unrated score = %+v, want nil value with total 2", s)
+	}
+}
+
+func TestWriteZIP(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteZIP(&buf, newTestArchive()); err != nil {
+		t.Fatalf("WriteZIP() error = %v", err)
+	}
+	files := readZIP(t, buf.Bytes())
+
+	for _, name := range []string{"data.json", "user.json", "user.csv", "dimensions.json", "dimensions.csv", "talents.json", "talents.csv", "adjustments.json", "adjustments.csv"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("WriteZIP() missing %s", name)
+		}
+	}
+
+	// パスワードハッシュと秘密鍵はどのファイルにも含めない
+	for name, content := range files {
+		for _, secret := range []string{"argon2id", "SECRET"} {
+			if bytes.Contains(content, []byte(secret)) {
+				t.Errorf("WriteZIP() %s contains %q", name, secret)
+			}
+		}
+	}
+
+	var decoded Archive
+	if err := json.Unmarshal(files["data.json"], &decoded); err != nil {
+		t.Fatalf("data.json is not valid JSON: %v", err)
+	}
+	if decoded.Version != FormatVersion || decoded.User.Username != "alice" || len(decoded.Talents) != 2 {
+		t.Errorf("data.json = %+v, want version %d with 2 talents for alice", decoded, FormatVersion)
+	}
+}
+
+func TestWriteZIP_CSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteZIP(&buf, newTestArchive()); err != nil {
+		t.Fatalf("WriteZIP() error = %v", err)
+	}
+	files := readZIP(t, buf.Bytes())
+
+	tests := []struct {
+		name       string
+		file       string
+		header     []string
+		wantRows   int
+		wantRecord []string
+	}{
+		{
+			name:       "タレント",
+			file:       "talents.csv",
+			header:     []string{"id", "name", "affiliation", "美しさ", "可愛さ", "才能", "is_favorite", "美しさ_total", "可愛さ_total", "才能_total", "created_at"},
+			wantRows:   2,
+			wantRecord: []string{"10", "タレント, \"A\"", "事務所", "5", "6", "7", "true", "6", "6", "7", ""},
+		},
+		{
+			name:       "評価軸",
+			file:       "dimensions.csv",
+			header:     DimensionCSVHeader,
+			wantRows:   3,
+			wantRecord: []string{"美しさ", "1", "10", "1"},
+		},
+		{
+			name:       "調整履歴",
+			file:       "adjustments.csv",
+			header:     AdjustmentCSVHeader,
+			wantRows:   1,
+			wantRecord: []string{"100", "10", "タレント, \"A\"", "美しさ", "1", "改行を\n含む理由", ""},
+		},
+		{
+			name:       "ユーザー",
+			file:       "user.csv",
+			header:     UserCSVHeader,
+			wantRows:   1,
+			wantRecord: []string{"alice", "true", "2025-01-01T00:00:00Z"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := string(files[tt.file])
+			if !strings.HasPrefix(content, utf8BOM) {
+				t.Errorf("%s should start with a UTF-8 BOM", tt.file)
+			}
+
+			records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(content, utf8BOM))).ReadAll()
+			if err != nil {
+				t.Fatalf("%s is not valid CSV: %v", tt.file, err)
+			}
+			if strings.Join(records[0], ",") != strings.Join(tt.header, ",") {
+				t.Errorf("%s header = %v, want %v", tt.file, records[0], tt.header)
+			}
+			if len(records)-1 != tt.wantRows {
+				t.Fatalf("%s rows = %d, want %d", tt.file, len(records)-1, tt.wantRows)
+			}
+			if strings.Join(records[1], "|") != strings.Join(tt.wantRecord, "|") {
+				t.Errorf("%s first row = %q, want %q", tt.file, records[1], tt.wantRecord)
+			}
+		})
+	}
+}
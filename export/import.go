@@ -0,0 +1,194 @@
+package export
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Kamekure-Maisuke/maiyumi/model"
+)
+
+// MaxImportRows は1回の取り込みで受け付ける最大行数。
+const MaxImportRows = 1000
+
+var ErrTooManyRows = fmt.Errorf("一度に取り込めるのは%d行までです", MaxImportRows)
+
+// nameAliases と affiliationAliases は見出しとして受け付ける名前。
+// 書き出した talents.csv の見出しと、スプレッドシートでよく使う日本語の見出しの両方を受け付ける。
+var (
+	nameAliases        = []string{"name", "名前", "タレント名"}
+	affiliationAliases = []string{"affiliation", "所属"}
+)
+
+// legacyScoreColumns は評価軸を導入する前（形式のバージョン1）に書き出したCSVのスコアの列名と、
+// 移行後の評価軸の名前の対応。同じ名前の評価軸があれば、その評価軸の列として読み込む。
+var legacyScoreColumns = map[string]string{
+	"beauty":   "美しさ",
+	"cuteness": "可愛さ",
+	"talent":   "才能",
+}
+
+// TalentRow は取り込むCSVの1行と、その検証結果。
+type TalentRow struct {
+	Line        int // 見出しを含むCSV上の行番号
+	Name        string
+	Affiliation string
+	Scores      []model.Score
+	ScoreInputs []string // 評価軸ごとに入力された値（確認画面の表示用）
+	Errors      []string
+}
+
+func (r TalentRow) Valid() bool {
+	return len(r.Errors) == 0
+}
+
+// Model は行の内容をユーザーのタレントとして返す。
+func (r TalentRow) Model(userID int) *model.Talent {
+	t := &model.Talent{
+		UserID: userID,
+		Name:   r.Name,
+		Scores: r.Scores,
+	}
+	if r.Affiliation != "" {
+		t.Affiliation = sql.NullString{String: r.Affiliation, Valid: true}
+	}
+	return t
+}
+
+// columnIndex は各列がCSVの何列目にあるか。scores は評価軸の順に並び、列がなければ -1。
+type columnIndex struct {
+	name        int
+	affiliation int
+	scores      []int
+}
+
+// positionalIndex は見出しがない場合の列の順番（名前、所属、評価軸の表示順）を返す。
+func positionalIndex(dimensions []model.ScoreDimension) columnIndex {
+	index := columnIndex{name: 0, affiliation: 1, scores: make([]int, len(dimensions))}
+	for i := range dimensions {
+		index.scores[i] = i + 2
+	}
+	return index
+}
+
+// headerIndex は1行目が見出しであれば列の対応を返す。名前の列がなければ見出しではないとみなす。
+func headerIndex(record []string, dimensions []model.ScoreDimension) (columnIndex, bool) {
+	index := columnIndex{name: -1, affiliation: -1, scores: make([]int, len(dimensions))}
+	for i := range index.scores {
+		index.scores[i] = -1
+	}
+
+	for i, cell := range record {
+		cell = strings.TrimSpace(cell)
+		lower := strings.ToLower(cell)
+		if slices.Contains(nameAliases, lower) {
+			index.name = i
+			continue
+		}
+		if slices.Contains(affiliationAliases, lower) {
+			index.affiliation = i
+			continue
+		}
+		for j, d := range dimensions {
+			if strings.EqualFold(cell, d.Name) || legacyScoreColumns[lower] == d.Name {
+				index.scores[j] = i
+			}
+		}
+	}
+	return index, index.name >= 0
+}
+
+// ReadTalentsCSV はタレントのCSVを読み込み、行ごとにユーザーの評価軸に照らして model.ValidateTalent で検証する。
+// 不正な行もエラー内容付きで返す。CSV自体が読めない場合だけエラーを返す。
+func ReadTalentsCSV(r io.Reader, dimensions []model.ScoreDimension) ([]TalentRow, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("CSVが空です")
+	}
+	if err != nil {
+		return nil, err
+	}
+	header[0] = strings.TrimPrefix(header[0], utf8BOM)
+
+	var rows []TalentRow
+	index, ok := headerIndex(header, dimensions)
+	if !ok {
+		// 見出しがなければ1行目からデータとして扱う
+		index = positionalIndex(dimensions)
+		line, _ := cr.FieldPos(0)
+		rows = append(rows, parseTalentRow(line, header, index, dimensions))
+	}
+
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if isBlankRecord(record) {
+			continue
+		}
+		if len(rows) >= MaxImportRows {
+			return nil, ErrTooManyRows
+		}
+		line, _ := cr.FieldPos(0)
+		rows = append(rows, parseTalentRow(line, record, index, dimensions))
+	}
+	return rows, nil
+}
+
+func isBlankRecord(record []string) bool {
+	for _, cell := range record {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func parseTalentRow(line int, record []string, index columnIndex, dimensions []model.ScoreDimension) TalentRow {
+	cell := func(i int) string {
+		if i < 0 || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	row := TalentRow{
+		Line:        line,
+		Name:        cell(index.name),
+		Affiliation: cell(index.affiliation),
+		ScoreInputs: make([]string, len(dimensions)),
+	}
+
+	var parseErrors []string
+	for i, d := range dimensions {
+		input := cell(index.scores[i])
+		row.ScoreInputs[i] = input
+		if input == "" {
+			// 未入力は ValidateTalent で報告する
+			continue
+		}
+		v, err := strconv.Atoi(input)
+		if err != nil {
+			parseErrors = append(parseErrors, d.Name+"は整数で入力してください")
+			// 範囲の検証で同じ項目を重ねて報告しないよう、仮に最小値を入れておく
+			v = d.MinScore
+		}
+		row.Scores = append(row.Scores, model.Score{DimensionID: d.ID, Name: d.Name, Value: v, Rated: true})
+	}
+
+	row.Errors = append(parseErrors, model.ValidateTalent(row.Model(0), dimensions)...)
+	return row
+}
@@ -0,0 +1,196 @@
+package export
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Kamekure-Maisuke/maiyumi/model"
+)
+
+func TestReadTalentsCSV(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantNames  []string
+		wantErrors []int // 行ごとのエラー数
+	}{
+		{
+			name:       "英語の見出し",
+			input:      "name,affiliation,beauty,cuteness,talent\nA,事務所,1,5,10\n",
+			wantNames:  []string{"A"},
+			wantErrors: []int{0},
+		},
+		{
+			name:       "日本語の見出しで列の順番が異なる",
+			input:      "所属,名前,才能,可愛さ,美しさ\n事務所,B,3,2,1\n",
+			wantNames:  []string{"B"},
+			wantErrors: []int{0},
+		},
+		{
+			name:       "見出しなし",
+			input:      "C,,5,5,5\n",
+			wantNames:  []string{"C"},
+			wantErrors: []int{0},
+		},
+		{
+			name:       "BOM付き",
+			input:      utf8BOM + "name,beauty,cuteness,talent\nD,5,5,5\n",
+			wantNames:  []string{"D"},
+			wantErrors: []int{0},
+		},
+		{
+			name:       "不正な行もエラー付きで返す",
+			input:      "name,affiliation,beauty,cuteness,talent\n,x,5,5,5\nE,,0,11,5\nF,,abc,5,5\nG,,5,5,5\n",
+			wantNames:  []string{"", "E", "F", "G"},
+			wantErrors: []int{1, 2, 1, 0},
+		},
+		{
+			name:       "空行は読み飛ばす",
+			input:      "name,beauty,cuteness,talent\n\nH,5,5,5\n,,,\n",
+			wantNames:  []string{"H"},
+			wantErrors: []int{0},
+		},
+		{
+			name:       "列が足りない行",
+			input:      "name,beauty,cuteness,talent\nI,5\n",
+			wantNames:  []string{"I"},
+			wantErrors: []int{2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := ReadTalentsCSV(strings.NewReader(tt.input), testDimensions())
+			if err != nil {
+				t.Fatalf("ReadTalentsCSV() error = %v", err)
+			}
+			if len(rows) != len(tt.wantNames) {
+				t.Fatalf("ReadTalentsCSV() len = %v, want %v", len(rows), len(tt.wantNames))
+			}
+			for i, row := range rows {
+				if row.Name != tt.wantNames[i] {
+					t.Errorf("ReadTalentsCSV()[%d].Name = %v, want %v", i, row.Name, tt.wantNames[i])
+				}
+				if len(row.Errors) != tt.wantErrors[i] {
+					t.Errorf("ReadTalentsCSV()[%d].Errors = %v, want %d errors", i, row.Errors, tt.wantErrors[i])
+				}
+			}
+		})
+	}
+}
+
+func TestReadTalentsCSV_LineNumbers(t *testing.T) {
+	rows, err := ReadTalentsCSV(strings.NewReader("name,beauty,cuteness,talent\nA,5,5,5\n\nB,5,5,5\n"), testDimensions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows[0].Line != 2 || rows[1].Line != 4 {
+		t.Errorf("ReadTalentsCSV() lines = %d, %d, want 2, 4", rows[0].Line, rows[1].Line)
+	}
+}
+
+func TestReadTalentsCSV_ScoreInputs(t *testing.T) {
+	rows, err := ReadTalentsCSV(strings.NewReader("name,beauty,cuteness,talent\nA,abc,5, 7\n"), testDimensions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"abc", "5", "7"}
+	if strings.Join(rows[0].ScoreInputs, "|") != strings.Join(want, "|") {
+		t.Errorf("ReadTalentsCSV() ScoreInputs = %q, want %q", rows[0].ScoreInputs, want)
+	}
+}
+
+func TestReadTalentsCSV_CustomDimensions(t *testing.T) {
+	dimensions := []model.ScoreDimension{
+		{ID: 7, Name: "歌唱力", MinScore: 0, MaxScore: 100},
+		{ID: 8, Name: "Dance", MinScore: 1, MaxScore: 5},
+	}
+
+	tests := []struct {
+		name       string
+		input      string
+		wantScores []int
+		wantErrors int
+	}{
+		{name: "評価軸の名前の見出し", input: "名前,dance,歌唱力\nA,3,80\n", wantScores: []int{80, 3}, wantErrors: 0},
+		{name: "見出しなしは評価軸の表示順", input: "A,,80,3\n", wantScores: []int{80, 3}, wantErrors: 0},
+		{name: "評価軸ごとの範囲で検証", input: "name,歌唱力,Dance\nA,0,6\n", wantScores: []int{0, 6}, wantErrors: 1},
+		{name: "評価軸にない列は無視", input: "name,歌唱力,Dance,beauty\nA,50,2,99\n", wantScores: []int{50, 2}, wantErrors: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := ReadTalentsCSV(strings.NewReader(tt.input), dimensions)
+			if err != nil {
+				t.Fatalf("ReadTalentsCSV() error = %v", err)
+			}
+			row := rows[0]
+			if len(row.Errors) != tt.wantErrors {
+				t.Errorf("ReadTalentsCSV() Errors = %v, want %d errors", row.Errors, tt.wantErrors)
+			}
+			if len(row.Scores) != len(tt.wantScores) {
+				t.Fatalf("ReadTalentsCSV() Scores = %+v, want %v", row.Scores, tt.wantScores)
+			}
+			for i, s := range row.Scores {
+				if s.DimensionID != dimensions[i].ID || s.Value != tt.wantScores[i] {
+					t.Errorf("ReadTalentsCSV() Scores[%d] = %+v, want dimension %d value %d", i, s, dimensions[i].ID, tt.wantScores[i])
+				}
+			}
+		})
+	}
+}
+
+func TestReadTalentsCSV_ExportedFile(t *testing.T) {
+	a := newTestArchive()
+	var buf bytes.Buffer
+	if err := WriteTalentsCSV(&buf, a.Dimensions, a.Talents); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := ReadTalentsCSV(&buf, testDimensions())
+	if err != nil {
+		t.Fatalf("ReadTalentsCSV() error = %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("ReadTalentsCSV() len = %v, want 2", len(rows))
+	}
+
+	got := rows[0].Model(1)
+	if got.Name != "タレント, \"A\"" || got.Affiliation.String != "事務所" {
+		t.Errorf("ReadTalentsCSV() = %+v, want exported talent", got)
+	}
+	for i, want := range []int{5, 6, 7} {
+		if got.Scores[i].Value != want {
+			t.Errorf("ReadTalentsCSV() Scores[%d] = %d, want %d", i, got.Scores[i].Value, want)
+		}
+	}
+	if rows[1].Model(1).Affiliation.Valid {
+		t.Errorf("ReadTalentsCSV() empty affiliation should be NULL")
+	}
+	// 未評価のスコアは空欄で書き出されるため、取り込むには入力が必要
+	if rows[1].Valid() {
+		t.Errorf("ReadTalentsCSV() row with unrated score should be invalid")
+	}
+}
+
+func TestReadTalentsCSV_Errors(t *testing.T) {
+	if _, err := ReadTalentsCSV(strings.NewReader(""), testDimensions()); err == nil {
+		t.Errorf("ReadTalentsCSV() with empty input error = nil, want error")
+	}
+
+	if _, err := ReadTalentsCSV(strings.NewReader("name\n\"unterminated\n"), testDimensions()); err == nil {
+		t.Errorf("ReadTalentsCSV() with broken quote error = nil, want error")
+	}
+
+	var many strings.Builder
+	many.WriteString("name,beauty,cuteness,talent\n")
+	for range MaxImportRows + 1 {
+		many.WriteString("A,5,5,5\n")
+	}
+	if _, err := ReadTalentsCSV(strings.NewReader(many.String()), testDimensions()); !errors.Is(err, ErrTooManyRows) {
+		t.Errorf("ReadTalentsCSV() error = %v, want %v", err, ErrTooManyRows)
+	}
+}
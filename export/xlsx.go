@@ -0,0 +1,106 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// XLSXは外部ライブラリを使わず、1シートだけの最小限の構成で書き出す。
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+</Types>`
+	xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`
+	xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="talents" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`
+	xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+</Relationships>`
+)
+
+// xlsxColumn は0始まりの列番号を A, B, ..., Z, AA のような列名に変換する。
+func xlsxColumn(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+func writeXLSXSheet(w io.Writer, rows [][]cell) error {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for r, row := range rows {
+		rowNum := strconv.Itoa(r + 1)
+		b.WriteString(`<row r="` + rowNum + `">`)
+		for col, c := range row {
+			ref := xlsxColumn(col) + rowNum
+			if c.number {
+				b.WriteString(`<c r="` + ref + `"><v>` + c.value + `</v></c>`)
+				continue
+			}
+			b.WriteString(`<c r="` + ref + `" t="inlineStr"><is><t xml:space="preserve">`)
+			// EscapeText はXMLで使えない制御文字も置き換える
+			if err := xml.EscapeText(&b, []byte(c.value)); err != nil {
+				return err
+			}
+			b.WriteString(`</t></is></c>`)
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteTalentsXLSX はタレント一覧をExcel形式で書き出す。列はCSVと同じで、IDとスコアは数値のセルにする。
+func WriteTalentsXLSX(w io.Writer, dimensions []Dimension, talents []Talent, modified time.Time) error {
+	rows := make([][]cell, 0, len(talents)+1)
+	rows = append(rows, textCells(TalentCSVHeader(dimensions)...))
+	for _, t := range talents {
+		rows = append(rows, t.cells())
+	}
+
+	zw := zip.NewWriter(w)
+	files := []struct {
+		name  string
+		write func(io.Writer) error
+	}{
+		{"[Content_Types].xml", func(w io.Writer) error { _, err := io.WriteString(w, xlsxContentTypes); return err }},
+		{"_rels/.rels", func(w io.Writer) error { _, err := io.WriteString(w, xlsxRootRels); return err }},
+		{"xl/workbook.xml", func(w io.Writer) error { _, err := io.WriteString(w, xlsxWorkbook); return err }},
+		{"xl/_rels/workbook.xml.rels", func(w io.Writer) error { _, err := io.WriteString(w, xlsxWorkbookRels); return err }},
+		{"xl/worksheets/sheet1.xml", func(w io.Writer) error { return writeXLSXSheet(w, rows) }},
+	}
+
+	for _, f := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     f.name,
+			Method:   zip.Deflate,
+			Modified: modified,
+		})
+		if err != nil {
+			return err
+		}
+		if err := f.write(fw); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
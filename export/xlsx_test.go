@@ -0,0 +1,86 @@
+package export
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestXLSXColumn(t *testing.T) {
+	tests := []struct {
+		name  string
+		index int
+		want  string
+	}{
+		{name: "先頭の列", index: 0, want: "A"},
+		{name: "1文字の最後", index: 25, want: "Z"},
+		{name: "2文字の先頭", index: 26, want: "AA"},
+		{name: "2文字の途中", index: 27, want: "AB"},
+		{name: "3文字の先頭", index: 702, want: "AAA"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := xlsxColumn(tt.index); got != tt.want {
+				t.Errorf("xlsxColumn(%d) = %v, want %v", tt.index, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteTalentsXLSX(t *testing.T) {
+	var buf bytes.Buffer
+	a := newTestArchive()
+	talents := append(a.Talents, Talent{ID: 12, Name: "<b>&制御\x01文字</b>"})
+	if err := WriteTalentsXLSX(&buf, a.Dimensions, talents, time.Date(2025, 2, 3, 4, 5, 6, 0, time.UTC)); err != nil {
+		t.Fatalf("WriteTalentsXLSX() error = %v", err)
+	}
+	files := readZIP(t, buf.Bytes())
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/worksheets/sheet1.xml"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("WriteTalentsXLSX() missing %s", name)
+		}
+	}
+
+	var sheet struct {
+		Rows []struct {
+			Cells []struct {
+				Ref    string `xml:"r,attr"`
+				Type   string `xml:"t,attr"`
+				Value  string `xml:"v"`
+				Inline string `xml:"is>t"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := xml.Unmarshal(files["xl/worksheets/sheet1.xml"], &sheet); err != nil {
+		t.Fatalf("sheet1.xml is not well-formed: %v", err)
+	}
+
+	if len(sheet.Rows) != 4 {
+		t.Fatalf("WriteTalentsXLSX() rows = %d, want 4", len(sheet.Rows))
+	}
+	header := sheet.Rows[0].Cells
+	if len(header) != len(TalentCSVHeader(a.Dimensions)) || header[len(header)-1].Ref != "K1" {
+		t.Errorf("WriteTalentsXLSX() header = %+v", header)
+	}
+
+	first := sheet.Rows[1].Cells
+	if first[1].Type != "inlineStr" || first[1].Inline != "タレント, \"A\"" {
+		t.Errorf("WriteTalentsXLSX() name cell = %+v", first[1])
+	}
+	if first[7].Type != "" || first[7].Value != "6" {
+		t.Errorf("WriteTalentsXLSX() total_beauty cell = %+v, want numeric 6", first[7])
+	}
+
+	if unrated := sheet.Rows[2].Cells[5]; unrated.Type != "inlineStr" || unrated.Inline != "" {
+		t.Errorf("WriteTalentsXLSX() unrated score cell = %+v, want empty string", unrated)
+	}
+
+	escaped := sheet.Rows[3].Cells[1].Inline
+	if !strings.HasPrefix(escaped, "<b>&制御") {
+		t.Errorf("WriteTalentsXLSX() escaped name = %q", escaped)
+	}
+}
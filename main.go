@@ -1,44 +1,92 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"crypto/rand"
+	"crypto/subtle"
 	"database/sql"
-	"encoding/base64"
 	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
 	"html/template"
+	"io"
 	"log"
+	"maps"
+	"net"
 	"net/http"
+	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
+	"github.com/Kamekure-Maisuke/maiyumi/auth"
+	"github.com/Kamekure-Maisuke/maiyumi/export"
 	"github.com/Kamekure-Maisuke/maiyumi/model"
 	"github.com/Kamekure-Maisuke/maiyumi/repository"
 	"github.com/common-nighthawk/go-figure"
-	"golang.org/x/crypto/argon2"
 	_ "modernc.org/sqlite"
 )
 
 const (
-	argon2Time    = 1
-	argon2Memory  = 64 * 1024
-	argon2Threads = 4
-	argon2KeyLen  = 32
-	argon2SaltLen = 16
+	sessionIdleTimeout   = 24 * time.Hour
+	sessionMaxLifetime   = 7 * 24 * time.Hour
+	sessionSweepInterval = 10 * time.Minute
+	totpLoginTimeout     = 5 * time.Minute
+	totpIssuer           = "maiyumi"
 )
 
+const (
+	defaultTrashRetention = 30 * 24 * time.Hour // ごみ箱のタレントを自動で削除するまでの既定の期間
+	trashPurgeInterval    = time.Hour
+)
+
+// maxImportFileSize は取り込むCSVファイルの最大サイズ。
+const maxImportFileSize = 1 << 20
+
+// maxImportRequestSize は取り込みのリクエストの本文の最大サイズ。
+// 確認画面からはCSVをフォームの値として送り直し、パーセントエンコードで最大3倍になるため、余裕を持たせる。
+const maxImportRequestSize = 4 * maxImportFileSize
+
+const (
+	passwordMinLength   = 8
+	commonPasswordsFile = "common_passwords.txt"
+)
+
+var loginThrottlePolicy = repository.LoginThrottlePolicy{
+	Window:        24 * time.Hour,
+	UserThreshold: 5,
+	IPThreshold:   20,
+	BaseLockout:   time.Minute,
+	MaxLockout:    time.Hour,
+}
+
 type App struct {
-	userRepo       repository.UserRepository
-	talentRepo     repository.TalentRepository
-	adjustmentRepo repository.AdjustmentRepository
-	sessionRepo    repository.SessionRepository
-	resultStore    *sync.Map
-	tmpl           *template.Template
+	userRepo         repository.UserRepository
+	talentRepo       repository.TalentRepository
+	adjustmentRepo   repository.AdjustmentRepository
+	dimensionRepo    repository.ScoreDimensionRepository
+	tagRepo          repository.TagRepository
+	affiliationRepo  repository.AffiliationRepository
+	savedSearchRepo  repository.SavedSearchRepository
+	auditRepo        repository.AuditRepository
+	sessionRepo      repository.SessionRepository
+	loginAttemptRepo repository.LoginAttemptRepository
+	resultStore      *sync.Map
+	pendingLogins    *sync.Map // 二要素認証待ちのログイン（トークン → pendingLogin）
+	passwordPolicy   *auth.PasswordPolicy
+	trashRetention   time.Duration // ごみ箱のタレントを自動で削除するまでの期間
+	tmpl             *template.Template
 }
 
 func initDB() (*sql.DB, error) {
-	db, err := sql.Open("sqlite", "data.db")
+	// foreign_keys と busy_timeout は接続ごとの設定のため、プール内のすべての接続で有効になるようDSNで指定する。
+	// busy_timeout は同時に書き込むリクエストがあったとき、すぐに SQLITE_BUSY にせずロックが外れるのを待つ
+	db, err := sql.Open("sqlite", "data.db?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
 	if err != nil {
 		return nil, err
 	}
@@ -46,30 +94,133 @@ func initDB() (*sql.DB, error) {
 	createTableSQL := `
 	CREATE TABLE IF NOT EXISTS users (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		username TEXT UNIQUE NOT NULL,
+		username TEXT UNIQUE NOT NULL COLLATE NOCASE,
 		password TEXT NOT NULL,
+		totp_secret TEXT NOT NULL DEFAULT '',
+		totp_last_step INTEGER NOT NULL DEFAULT 0,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);
+	CREATE TABLE IF NOT EXISTS recovery_codes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		code_hash TEXT NOT NULL,
+		used_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+	CREATE TABLE IF NOT EXISTS affiliations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		name_key TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+		UNIQUE (user_id, name_key)
+	);
 	CREATE TABLE IF NOT EXISTS talents (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		user_id INTEGER NOT NULL,
 		name TEXT NOT NULL,
-		affiliation TEXT,
-		beauty INTEGER NOT NULL CHECK(beauty >= 1 AND beauty <= 10),
-		cuteness INTEGER NOT NULL CHECK(cuteness >= 1 AND cuteness <= 10),
-		talent INTEGER NOT NULL CHECK(talent >= 1 AND talent <= 10),
+		affiliation_id INTEGER,
 		is_favorite BOOLEAN DEFAULT 0,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (user_id) REFERENCES users(id)
+		deleted_at DATETIME,
+		FOREIGN KEY (user_id) REFERENCES users(id),
+		FOREIGN KEY (affiliation_id) REFERENCES affiliations(id) ON DELETE SET NULL
+	);
+	CREATE TABLE IF NOT EXISTS score_dimensions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		min_score INTEGER NOT NULL,
+		max_score INTEGER NOT NULL,
+		display_order INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+		UNIQUE (user_id, name),
+		CHECK(min_score < max_score)
+	);
+	CREATE TABLE IF NOT EXISTS talent_scores (
+		talent_id INTEGER NOT NULL,
+		dimension_id INTEGER NOT NULL,
+		score INTEGER NOT NULL,
+		PRIMARY KEY (talent_id, dimension_id),
+		FOREIGN KEY (talent_id) REFERENCES talents(id) ON DELETE CASCADE,
+		FOREIGN KEY (dimension_id) REFERENCES score_dimensions(id) ON DELETE CASCADE
 	);
 	CREATE TABLE IF NOT EXISTS adjustments (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		talent_id INTEGER NOT NULL,
-		adjustment_type TEXT NOT NULL CHECK(adjustment_type IN ('beauty', 'cuteness', 'talent')),
+		dimension_id INTEGER NOT NULL,
 		points INTEGER NOT NULL CHECK(points >= -10 AND points <= 10),
 		reason TEXT NOT NULL,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (talent_id) REFERENCES talents(id) ON DELETE CASCADE
+		FOREIGN KEY (talent_id) REFERENCES talents(id) ON DELETE CASCADE,
+		FOREIGN KEY (dimension_id) REFERENCES score_dimensions(id) ON DELETE CASCADE
+	);
+	CREATE TABLE IF NOT EXISTS audit_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		actor_id INTEGER,
+		entity_type TEXT NOT NULL,
+		entity_id INTEGER NOT NULL,
+		action TEXT NOT NULL,
+		changes TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS talent_revisions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		talent_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		affiliation TEXT NOT NULL,
+		scores TEXT NOT NULL,
+		tags TEXT NOT NULL,
+		reverted_from INTEGER,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (talent_id) REFERENCES talents(id) ON DELETE CASCADE,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+	CREATE TABLE IF NOT EXISTS tags (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+		UNIQUE (user_id, name)
+	);
+	CREATE TABLE IF NOT EXISTS talent_tags (
+		talent_id INTEGER NOT NULL,
+		tag_id INTEGER NOT NULL,
+		PRIMARY KEY (talent_id, tag_id),
+		FOREIGN KEY (talent_id) REFERENCES talents(id) ON DELETE CASCADE,
+		FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+	);
+	CREATE TABLE IF NOT EXISTS saved_searches (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		query TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+		UNIQUE (user_id, name)
+	);
+	CREATE TABLE IF NOT EXISTS sessions (
+		id TEXT PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		user_agent TEXT NOT NULL DEFAULT '',
+		ip_address TEXT NOT NULL DEFAULT '',
+		csrf_token TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		last_seen_at DATETIME
+	);
+	CREATE TABLE IF NOT EXISTS login_attempts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		username TEXT NOT NULL,
+		ip_address TEXT NOT NULL,
+		succeeded BOOLEAN NOT NULL,
+		cleared BOOLEAN NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);`
 
 	_, err = db.Exec(createTableSQL)
@@ -80,11 +231,88 @@ func initDB() (*sql.DB, error) {
 	// マイグレーション: is_favoriteカラムを追加（既存DBのため）
 	db.Exec("ALTER TABLE talents ADD COLUMN is_favorite BOOLEAN DEFAULT 0")
 
+	// マイグレーション: ごみ箱に移した日時を追加
+	db.Exec("ALTER TABLE talents ADD COLUMN deleted_at DATETIME")
+
+	// マイグレーション: sessionsに最終アクセス時刻を追加
+	db.Exec("ALTER TABLE sessions ADD COLUMN last_seen_at DATETIME")
+	db.Exec("UPDATE sessions SET last_seen_at = created_at WHERE last_seen_at IS NULL")
+
+	// マイグレーション: sessionsをユーザー名からユーザーIDに紐付け直す
+	db.Exec("ALTER TABLE sessions ADD COLUMN user_id INTEGER")
+	db.Exec("ALTER TABLE sessions ADD COLUMN user_agent TEXT NOT NULL DEFAULT ''")
+	db.Exec("ALTER TABLE sessions ADD COLUMN ip_address TEXT NOT NULL DEFAULT ''")
+	db.Exec("UPDATE sessions SET user_id = (SELECT id FROM users WHERE users.username = sessions.username) WHERE user_id IS NULL")
+	db.Exec("DELETE FROM sessions WHERE user_id IS NULL")
+	db.Exec("ALTER TABLE sessions DROP COLUMN username")
+
+	// マイグレーション: sessionsにCSRFトークンを追加（既存セッションは再ログインが必要）
+	db.Exec("ALTER TABLE sessions ADD COLUMN csrf_token TEXT NOT NULL DEFAULT ''")
+
+	// マイグレーション: 大文字小文字だけが異なるユーザー名は、後から登録された方の末尾にIDを付けて一意にする
+	if err := migrateDuplicateUsernames(db); err != nil {
+		return nil, err
+	}
+
+	// マイグレーション: usersに二要素認証の秘密鍵と使用済みステップを追加
+	db.Exec("ALTER TABLE users ADD COLUMN totp_secret TEXT NOT NULL DEFAULT ''")
+	db.Exec("ALTER TABLE users ADD COLUMN totp_last_step INTEGER NOT NULL DEFAULT 0")
+
+	// マイグレーション: 美しさ・可愛さ・才能の列を、ユーザーごとの評価軸とスコアの行に移す
+	if err := migrateScoreColumns(db); err != nil {
+		return nil, err
+	}
+
+	// マイグレーション: 所属名の列を所属のテーブルに移し、表記ゆれをまとめる
+	if err := migrateAffiliations(db); err != nil {
+		return nil, err
+	}
+
+	// マイグレーション: 調整履歴の変更の記録を監査ログにまとめる
+	if err := migrateAdjustmentChanges(db); err != nil {
+		return nil, err
+	}
+
+	// マイグレーション: 全文検索の索引とトリガーを用意し、索引がなかった場合は既存のタレントを登録する。
+	// 索引があった場合は、停止中にアプリ以外から変更されたタレントを反映する
+	var hasSearchIndex int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'talents_fts'").Scan(&hasSearchIndex); err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(repository.TalentSearchSchema); err != nil {
+		return nil, err
+	}
+	if hasSearchIndex == 0 {
+		if err := repository.RebuildTalentSearchIndex(db); err != nil {
+			return nil, err
+		}
+	} else if err := repository.SyncTalentSearchIndex(db); err != nil {
+		return nil, err
+	}
+
+	// マイグレーション: 版のないタレントの現在の内容を最初の版として記録する
+	if err := repository.BackfillTalentRevisions(db); err != nil {
+		return nil, err
+	}
+
 	// インデックスの作成
 	indexSQL := `
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username_nocase ON users(username COLLATE NOCASE);
 	CREATE INDEX IF NOT EXISTS idx_talents_user_id ON talents(user_id);
 	CREATE INDEX IF NOT EXISTS idx_talents_user_id_favorite ON talents(user_id, is_favorite);
-	CREATE INDEX IF NOT EXISTS idx_adjustments_talent_id_type ON adjustments(talent_id, adjustment_type);
+	CREATE INDEX IF NOT EXISTS idx_talents_affiliation_id ON talents(affiliation_id);
+	CREATE INDEX IF NOT EXISTS idx_talents_deleted_at ON talents(deleted_at);
+	CREATE INDEX IF NOT EXISTS idx_adjustments_talent_id_dimension ON adjustments(talent_id, dimension_id);
+	CREATE INDEX IF NOT EXISTS idx_talent_revisions_talent_id ON talent_revisions(talent_id);
+	CREATE INDEX IF NOT EXISTS idx_audit_events_user_id ON audit_events(user_id, id);
+	CREATE INDEX IF NOT EXISTS idx_audit_events_entity ON audit_events(entity_type, entity_id);
+	CREATE INDEX IF NOT EXISTS idx_talent_scores_dimension_id ON talent_scores(dimension_id);
+	CREATE INDEX IF NOT EXISTS idx_talent_tags_tag_id ON talent_tags(tag_id);
+	CREATE INDEX IF NOT EXISTS idx_sessions_last_seen_at ON sessions(last_seen_at);
+	CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
+	CREATE INDEX IF NOT EXISTS idx_login_attempts_username ON login_attempts(username, created_at);
+	CREATE INDEX IF NOT EXISTS idx_login_attempts_ip_address ON login_attempts(ip_address, created_at);
+	CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_id ON recovery_codes(user_id);
 	`
 	_, err = db.Exec(indexSQL)
 	if err != nil {
@@ -99,60 +327,270 @@ func initDB() (*sql.DB, error) {
 	return db, nil
 }
 
-func hashPassword(password string) (string, error) {
-	salt := make([]byte, argon2SaltLen)
-	if _, err := rand.Read(salt); err != nil {
-		return "", err
+// usernameMaxLength は repository.NormalizeUsername が受け付けるユーザー名の最大文字数。
+const usernameMaxLength = 32
+
+// migrateDuplicateUsernames は大文字小文字だけが異なるユーザー名のうち、後から登録された方を「名前_ID」に変える。
+// 既存の名前と衝突する場合は連番を足し、最大文字数に収まるよう元の名前を切り詰める。
+// 変更したユーザーには新しいログイン名を伝える必要があるため、変更前後の名前をログに出す。
+func migrateDuplicateUsernames(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
 
-	hash := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+	rows, err := tx.Query(`
+		SELECT id, username FROM users
+		WHERE id NOT IN (SELECT MIN(id) FROM users GROUP BY username COLLATE NOCASE)
+		ORDER BY id`)
+	if err != nil {
+		return err
+	}
+	type rename struct {
+		id       int
+		username string
+	}
+	var renames []rename
+	for rows.Next() {
+		var r rename
+		if err := rows.Scan(&r.id, &r.username); err != nil {
+			rows.Close()
+			return err
+		}
+		renames = append(renames, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
 
-	encoded := base64.RawStdEncoding.EncodeToString(salt) + ":" + base64.RawStdEncoding.EncodeToString(hash)
-	return encoded, nil
+	for _, r := range renames {
+		newUsername, err := uniqueUsername(tx, r.username, r.id)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE users SET username = ? WHERE id = ?", newUsername, r.id); err != nil {
+			return fmt.Errorf("ユーザー名の重複の解消に失敗しました: %w", err)
+		}
+		log.Printf("ユーザー名の重複を解消しました: ID %d のユーザー名を %q から %q に変更しました", r.id, r.username, newUsername)
+	}
+	return tx.Commit()
 }
 
-func verifyPassword(password, encodedHash string) bool {
-	parts := make([]byte, len(encodedHash))
-	copy(parts, encodedHash)
+// uniqueUsername は username の末尾に「_ID」（衝突する場合は「_ID_連番」）を付けた、まだ使われていないユーザー名を返す。
+func uniqueUsername(tx *sql.Tx, username string, id int) (string, error) {
+	for n := 1; ; n++ {
+		suffix := "_" + strconv.Itoa(id)
+		if n > 1 {
+			suffix += "_" + strconv.Itoa(n)
+		}
+		base := []rune(username)
+		if limit := usernameMaxLength - utf8.RuneCountInString(suffix); len(base) > limit {
+			base = base[:limit]
+		}
+		candidate := string(base) + suffix
 
-	var colonPos int
-	for i := 0; i < len(encodedHash); i++ {
-		if encodedHash[i] == ':' {
-			colonPos = i
-			break
+		var exists int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM users WHERE username = ? COLLATE NOCASE", candidate).Scan(&exists); err != nil {
+			return "", err
+		}
+		if exists == 0 {
+			return candidate, nil
 		}
 	}
+}
+
+// migrateAdjustmentChanges は adjustment_changes に記録した調整履歴の編集・削除を監査ログに移し、テーブルを削除する。
+// 監査ログにも同時に記録した変更は移さない。移行済みのDBでは何もしない。
+func migrateAdjustmentChanges(db *sql.DB) error {
+	var legacy int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'adjustment_changes'").Scan(&legacy); err != nil {
+		return err
+	}
+	if legacy == 0 {
+		return nil
+	}
 
-	if colonPos == 0 {
-		return false
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// 監査ログと同じく、削除は変更前の値だけ、編集は変わった項目だけを記録する
+	statements := []string{
+		`INSERT INTO audit_events (user_id, actor_id, entity_type, entity_id, action, changes, created_at)
+		SELECT c.user_id, c.user_id, '` + model.AuditEntityAdjustment + `', c.adjustment_id, c.action,
+			CASE c.action
+				WHEN 'delete' THEN json_object(
+					'dimension', json_object('before', COALESCE(d.name, '')),
+					'points', json_object('before', c.old_points),
+					'reason', json_object('before', c.old_reason),
+					'talent_id', json_object('before', c.talent_id))
+				ELSE json_patch(
+					CASE WHEN c.old_points != c.new_points
+						THEN json_object('points', json_object('before', c.old_points, 'after', c.new_points)) ELSE '{}' END,
+					CASE WHEN c.old_reason != c.new_reason
+						THEN json_object('reason', json_object('before', c.old_reason, 'after', c.new_reason)) ELSE '{}' END)
+			END,
+			c.created_at
+		FROM adjustment_changes c
+		LEFT JOIN score_dimensions d ON d.id = c.dimension_id
+		WHERE NOT EXISTS (
+			SELECT 1 FROM audit_events e
+			WHERE e.entity_type = '` + model.AuditEntityAdjustment + `' AND e.entity_id = c.adjustment_id
+				AND e.action = c.action AND e.created_at = c.created_at
+		)
+		ORDER BY c.id`,
+		"DELETE FROM audit_events WHERE entity_type = '" + model.AuditEntityAdjustment + "' AND action = '" + model.AuditActionUpdate + "' AND changes = '{}'",
+		"DROP TABLE adjustment_changes",
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("調整履歴の変更の記録の移行に失敗しました: %w", err)
+		}
 	}
+	return tx.Commit()
+}
+
+// legacyScoreColumns は評価軸を導入する前の talents の列と、移行先の評価軸の名前。
+// adjustment_type の値も同じ列名を使っていた。
+var legacyScoreColumns = []struct {
+	column string
+	name   string
+}{
+	{"beauty", "美しさ"},
+	{"cuteness", "可愛さ"},
+	{"talent", "才能"},
+}
 
-	saltEncoded := encodedHash[:colonPos]
-	hashEncoded := encodedHash[colonPos+1:]
+// migrateScoreColumns は talents の固定のスコア列を score_dimensions と talent_scores の行に移し、
+// adjustments の adjustment_type を dimension_id に置き換える。移行済みのDBでは何もしない。
+func migrateScoreColumns(db *sql.DB) error {
+	var legacy int
+	if err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('talents') WHERE name = 'beauty'").Scan(&legacy); err != nil {
+		return err
+	}
+	if legacy == 0 {
+		return nil
+	}
 
-	salt, err := base64.RawStdEncoding.DecodeString(saltEncoded)
+	tx, err := db.Begin()
 	if err != nil {
-		return false
+		return err
+	}
+	defer tx.Rollback()
+
+	statements := []string{"ALTER TABLE adjustments ADD COLUMN dimension_id INTEGER REFERENCES score_dimensions(id) ON DELETE CASCADE"}
+	for _, d := range model.DefaultScoreDimensions {
+		statements = append(statements, fmt.Sprintf(`
+			INSERT INTO score_dimensions (user_id, name, min_score, max_score, display_order)
+			SELECT id, '%s', %d, %d, %d FROM users`,
+			d.Name, d.MinScore, d.MaxScore, d.DisplayOrder))
+	}
+	for _, c := range legacyScoreColumns {
+		statements = append(statements,
+			`INSERT INTO talent_scores (talent_id, dimension_id, score)
+			SELECT t.id, d.id, t.`+c.column+` FROM talents t
+			JOIN score_dimensions d ON d.user_id = t.user_id AND d.name = '`+c.name+`'`,
+			`UPDATE adjustments SET dimension_id = (
+				SELECT d.id FROM talents t
+				JOIN score_dimensions d ON d.user_id = t.user_id AND d.name = '`+c.name+`'
+				WHERE t.id = adjustments.talent_id
+			) WHERE adjustment_type = '`+c.column+`'`)
+	}
+	statements = append(statements,
+		"DROP INDEX IF EXISTS idx_adjustments_talent_id_type",
+		"ALTER TABLE adjustments DROP COLUMN adjustment_type",
+		"ALTER TABLE talents DROP COLUMN beauty",
+		"ALTER TABLE talents DROP COLUMN cuteness",
+		"ALTER TABLE talents DROP COLUMN talent",
+	)
+
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("評価軸への移行に失敗しました: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// migrateAffiliations は talents の affiliation 列の所属名を affiliations の行に移し、affiliation_id で参照するようにする。
+// repository.AffiliationKey が同じになる所属名は、最初に登録された表記の1つの所属にまとめる。移行済みのDBでは何もしない。
+func migrateAffiliations(db *sql.DB) error {
+	var legacy int
+	if err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('talents') WHERE name = 'affiliation'").Scan(&legacy); err != nil {
+		return err
+	}
+	if legacy == 0 {
+		return nil
 	}
 
-	expectedHash, err := base64.RawStdEncoding.DecodeString(hashEncoded)
+	tx, err := db.Begin()
 	if err != nil {
-		return false
+		return err
 	}
+	defer tx.Rollback()
 
-	hash := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+	if _, err := tx.Exec("ALTER TABLE talents ADD COLUMN affiliation_id INTEGER REFERENCES affiliations(id) ON DELETE SET NULL"); err != nil {
+		return fmt.Errorf("所属への移行に失敗しました: %w", err)
+	}
 
-	if len(hash) != len(expectedHash) {
-		return false
+	rows, err := tx.Query(`
+		SELECT user_id, affiliation FROM talents
+		WHERE affiliation IS NOT NULL
+		GROUP BY user_id, affiliation
+		ORDER BY MIN(id)`)
+	if err != nil {
+		return err
+	}
+	type legacyAffiliation struct {
+		userID int
+		name   string
+	}
+	var legacyAffiliations []legacyAffiliation
+	for rows.Next() {
+		var a legacyAffiliation
+		if err := rows.Scan(&a.userID, &a.name); err != nil {
+			rows.Close()
+			return err
+		}
+		legacyAffiliations = append(legacyAffiliations, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
 	}
 
-	for i := range len(hash) {
-		if hash[i] != expectedHash[i] {
-			return false
+	for _, a := range legacyAffiliations {
+		key := repository.AffiliationKey(a.name)
+		if key == "" {
+			continue
+		}
+		statements := []struct {
+			query string
+			args  []any
+		}{
+			{`INSERT INTO affiliations (user_id, name, name_key) VALUES (?, ?, ?)
+				ON CONFLICT (user_id, name_key) DO NOTHING`,
+				[]any{a.userID, strings.TrimSpace(a.name), key}},
+			{`UPDATE talents SET affiliation_id = (
+				SELECT id FROM affiliations WHERE user_id = ? AND name_key = ?
+			) WHERE user_id = ? AND affiliation = ?`,
+				[]any{a.userID, key, a.userID, a.name}},
+		}
+		for _, stmt := range statements {
+			if _, err := tx.Exec(stmt.query, stmt.args...); err != nil {
+				return fmt.Errorf("所属への移行に失敗しました: %w", err)
+			}
 		}
 	}
 
-	return true
+	if _, err := tx.Exec("ALTER TABLE talents DROP COLUMN affiliation"); err != nil {
+		return fmt.Errorf("所属への移行に失敗しました: %w", err)
+	}
+	return tx.Commit()
 }
 
 func generateSessionID() string {
@@ -197,41 +635,281 @@ func convertToNogiName(input string) (string, error) {
 	return result.String(), nil
 }
 
-func (app *App) getUsername(r *http.Request) string {
+func (app *App) setSessionCookie(w http.ResponseWriter, sessionID string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "session_id",
+		Value:    sessionID,
+		Path:     "/",
+		Expires:  time.Now().Add(sessionMaxLifetime),
+		HttpOnly: true,
+	})
+}
+
+// startSessionSweeper は期限切れセッションを定期的に削除するゴルーチンを起動する。
+func (app *App) startSessionSweeper(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			deleted, err := app.sessionRepo.DeleteExpired()
+			if err != nil {
+				log.Printf("期限切れセッションの削除に失敗しました: %v", err)
+				continue
+			}
+			if deleted > 0 {
+				log.Printf("期限切れセッションを%d件削除しました", deleted)
+			}
+
+			now := time.Now()
+			app.pendingLogins.Range(func(key, value any) bool {
+				if now.After(value.(pendingLogin).ExpiresAt) {
+					app.pendingLogins.Delete(key)
+				}
+				return true
+			})
+		}
+	}()
+}
+
+// startTrashPurger は保存期間を過ぎたごみ箱のタレントを定期的に削除するゴルーチンを起動する。
+func (app *App) startTrashPurger(interval, retention time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			deleted, err := app.talentRepo.PurgeTrash(time.Now().Add(-retention))
+			if err != nil {
+				log.Printf("ごみ箱のタレントの削除に失敗しました: %v", err)
+				continue
+			}
+			if deleted > 0 {
+				log.Printf("保存期間を過ぎたごみ箱のタレントを%d件削除しました", deleted)
+			}
+		}
+	}()
+}
+
+type contextKey int
+
+const (
+	userContextKey contextKey = iota
+	sessionContextKey
+)
+
+// clientIP はリクエスト元のIPアドレスを返す。
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// startSession は新しいセッションを作成し、Cookieに設定する。
+func (app *App) startSession(w http.ResponseWriter, r *http.Request, userID int) error {
+	session := &model.Session{
+		ID:        generateSessionID(),
+		UserID:    userID,
+		CSRFToken: generateSessionID(),
+		UserAgent: r.UserAgent(),
+		IPAddress: clientIP(r),
+	}
+	if err := app.sessionRepo.Create(session); err != nil {
+		return err
+	}
+	app.setSessionCookie(w, session.ID)
+	return nil
+}
+
+// pendingLogin はパスワード認証に成功し、二要素認証を待っているログイン。
+type pendingLogin struct {
+	UserID    int
+	Username  string
+	ExpiresAt time.Time
+}
+
+// getPendingLogin はCookieから二要素認証待ちのログインを取り出す。
+func (app *App) getPendingLogin(r *http.Request) (string, *pendingLogin) {
+	cookie, err := r.Cookie("totp_token")
+	if err != nil {
+		return "", nil
+	}
+	value, ok := app.pendingLogins.Load(cookie.Value)
+	if !ok {
+		return "", nil
+	}
+	pending := value.(pendingLogin)
+	if time.Now().After(pending.ExpiresAt) {
+		app.pendingLogins.Delete(cookie.Value)
+		return "", nil
+	}
+	return cookie.Value, &pending
+}
+
+// completeLogin はログイン成功を記録してセッションを開始する。
+func (app *App) completeLogin(w http.ResponseWriter, r *http.Request, user *model.User) {
+	if err := app.loginAttemptRepo.Record(user.Username, clientIP(r), true); err != nil {
+		log.Printf("ログイン試行の記録に失敗しました: %v", err)
+	}
+
+	if err := app.startSession(w, r, user.ID); err != nil {
+		http.Error(w, "セッションの作成に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (app *App) getSession(r *http.Request) *model.Session {
 	cookie, err := r.Cookie("session_id")
 	if err != nil {
-		return ""
+		return nil
+	}
+	session, ok := app.sessionRepo.Get(cookie.Value)
+	if !ok {
+		return nil
 	}
-	username, _ := app.sessionRepo.Get(cookie.Value)
-	return username
+	return session
 }
 
-func (app *App) requireAuth(w http.ResponseWriter, r *http.Request) bool {
-	username := app.getUsername(r)
-	if username == "" {
-		http.Redirect(w, r, "/login", http.StatusSeeOther)
-		return false
+// withAuth はセッションからログインユーザーを解決してコンテキストに格納する。
+// 未ログインの場合はログイン画面にリダイレクトする。
+func (app *App) withAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session := app.getSession(r)
+		if session == nil {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+
+		user, err := app.userRepo.FindByID(session.UserID)
+		if err != nil {
+			app.sessionRepo.Delete(session.ID)
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), sessionContextKey, session)
+		ctx = context.WithValue(ctx, userContextKey, user)
+		next(w, r.WithContext(ctx))
 	}
-	return true
 }
 
-func (app *App) handleIndex(w http.ResponseWriter, r *http.Request) {
-	if !app.requireAuth(w, r) {
+// currentUser は withAuth がコンテキストに格納したログインユーザーを返す。
+func currentUser(r *http.Request) *model.User {
+	user, _ := r.Context().Value(userContextKey).(*model.User)
+	return user
+}
+
+// currentSession は withAuth がコンテキストに格納したセッションを返す。
+func currentSession(r *http.Request) *model.Session {
+	session, _ := r.Context().Value(sessionContextKey).(*model.Session)
+	return session
+}
+
+// csrfToken はフォームに埋め込むCSRFトークンを返す。
+// ログイン中はセッションのトークン、未ログインの場合はCookieに発行したトークンを使う。
+func (app *App) csrfToken(w http.ResponseWriter, r *http.Request) string {
+	if session := currentSession(r); session != nil {
+		return session.CSRFToken
+	}
+	if cookie, err := r.Cookie("csrf_token"); err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+
+	token := generateSessionID()
+	http.SetCookie(w, &http.Cookie{
+		Name:     "csrf_token",
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return token
+}
+
+// withCSRF はPOSTリクエストのCSRFトークンを検証する。
+// ログインが必要なハンドラでは withAuth の内側で使う。
+func (app *App) withCSRF(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			var expected string
+			if session := currentSession(r); session != nil {
+				expected = session.CSRFToken
+			} else if cookie, err := r.Cookie("csrf_token"); err == nil {
+				expected = cookie.Value
+			}
+
+			actual := r.FormValue("csrf_token")
+			if expected == "" || subtle.ConstantTimeCompare([]byte(expected), []byte(actual)) != 1 {
+				app.renderError(w, r, http.StatusForbidden, "フォームの有効期限が切れているか、不正なリクエストです。ページを再読み込みしてからやり直してください。")
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// withBodyLimit はリクエストの本文を limit バイトまでに制限し、超えた場合は 413 を返す。
+// withCSRF がフォームを読む前に制限するため、その外側で使う。
+func (app *App) withBodyLimit(limit int64, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		if r.Method == http.MethodPost {
+			// ParseMultipartForm は multipart でない本文を読んだときのエラーを返さないため、先に ParseForm で読む
+			err := r.ParseForm()
+			if err == nil {
+				err = r.ParseMultipartForm(limit)
+			}
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				app.renderError(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("送信できるデータは%dKBまでです", limit/1024))
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// render はCSRFトークンを埋め込めるようにして、テンプレートを status で描画する。
+func (app *App) render(w http.ResponseWriter, r *http.Request, status int, name string, data any) {
+	// Cookieを発行する場合があるため、ヘッダーを送る前にトークンを確定させる
+	token := app.csrfToken(w, r)
+
+	tmpl, err := app.tmpl.Clone()
+	if err != nil {
+		http.Error(w, "画面の表示に失敗しました", http.StatusInternalServerError)
 		return
 	}
+	tmpl.Funcs(template.FuncMap{
+		"csrfField": func() template.HTML {
+			return template.HTML(`<input type="hidden" name="csrf_token" value="` + template.HTMLEscapeString(token) + `">`)
+		},
+	})
+	w.WriteHeader(status)
+	tmpl.ExecuteTemplate(w, name, data)
+}
 
-	username := app.getUsername(r)
+func (app *App) renderError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	app.render(w, r, status, "error.tmpl", map[string]any{
+		"Status":     status,
+		"StatusText": http.StatusText(status),
+		"Message":    message,
+	})
+}
+
+func (app *App) handleIndex(w http.ResponseWriter, r *http.Request) {
 	data := struct {
 		Name string
 	}{
-		Name: username,
+		Name: currentUser(r).Username,
 	}
-	app.tmpl.ExecuteTemplate(w, "index.tmpl", data)
+	app.render(w, r, http.StatusOK, "index.tmpl", data)
 }
 
 func (app *App) handleRegister(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet {
-		app.tmpl.ExecuteTemplate(w, "register.tmpl", nil)
+		app.render(w, r, http.StatusOK, "register.tmpl", nil)
 		return
 	}
 
@@ -239,18 +917,40 @@ func (app *App) handleRegister(w http.ResponseWriter, r *http.Request) {
 		username := r.FormValue("username")
 		password := r.FormValue("password")
 
+		renderErrors := func(problems ...string) {
+			app.render(w, r, http.StatusBadRequest, "register.tmpl", map[string]any{
+				"Username": username,
+				"Errors":   problems,
+			})
+		}
+
 		if username == "" || password == "" {
-			http.Error(w, "ユーザー名とパスワードを入力してください", http.StatusBadRequest)
+			renderErrors("ユーザー名とパスワードを入力してください")
+			return
+		}
+
+		normalized, err := repository.NormalizeUsername(username)
+		if err != nil {
+			renderErrors(err.Error())
+			return
+		}
+
+		if problems := app.passwordPolicy.Validate(normalized, password); problems != nil {
+			renderErrors(problems...)
 			return
 		}
 
-		hashedPassword, err := hashPassword(password)
+		hashedPassword, err := auth.HashPassword(password)
 		if err != nil {
 			http.Error(w, "パスワードのハッシュ化に失敗しました", http.StatusInternalServerError)
 			return
 		}
 
-		if err := app.userRepo.Create(username, hashedPassword); err != nil {
+		if err := app.userRepo.Create(normalized, hashedPassword); err != nil {
+			if errors.Is(err, repository.ErrDuplicateUsername) {
+				renderErrors(err.Error())
+				return
+			}
 			http.Error(w, "ユーザー登録に失敗しました", http.StatusInternalServerError)
 			return
 		}
@@ -261,130 +961,590 @@ func (app *App) handleRegister(w http.ResponseWriter, r *http.Request) {
 
 func (app *App) handleLogin(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet {
-		app.tmpl.ExecuteTemplate(w, "login.tmpl", nil)
+		app.render(w, r, http.StatusOK, "login.tmpl", nil)
 		return
 	}
 
 	if r.Method == http.MethodPost {
 		username := r.FormValue("username")
 		password := r.FormValue("password")
+		ip := clientIP(r)
 
-		user, err := app.userRepo.FindByUsername(username)
+		// 全角や前後の空白でロックを回避されないよう、正規化したユーザー名で試行を記録する
+		if normalized, err := repository.NormalizeUsername(username); err == nil {
+			username = normalized
+		}
+
+		// ロック中はパスワードの検証（argon2の計算）自体を行わない
+		lockedUntil, err := app.loginAttemptRepo.LockedUntil(username, ip)
 		if err != nil {
-			http.Error(w, "ログインに失敗しました", http.StatusUnauthorized)
+			http.Error(w, "ログインに失敗しました", http.StatusInternalServerError)
+			return
+		}
+		if !lockedUntil.IsZero() {
+			w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(lockedUntil).Seconds())+1))
+			http.Error(w, "ログインの失敗が続いたため一時的にロックしています。しばらく待ってから再度お試しください", http.StatusTooManyRequests)
 			return
 		}
 
-		if !verifyPassword(password, user.Password) {
+		user, err := app.userRepo.FindByUsername(username)
+		var ok, needsRehash bool
+		if err == nil {
+			ok, needsRehash = auth.VerifyPassword(password, user.Password)
+		}
+		if !ok {
+			if err := app.loginAttemptRepo.Record(username, ip, false); err != nil {
+				log.Printf("ログイン試行の記録に失敗しました: %v", err)
+			}
 			http.Error(w, "ログインに失敗しました", http.StatusUnauthorized)
 			return
 		}
 
-		sessionID := generateSessionID()
-		app.sessionRepo.Set(sessionID, username)
+		// 旧形式や古いパラメータのハッシュは現在の設定で保存し直す
+		if needsRehash {
+			if rehashed, err := auth.HashPassword(password); err == nil {
+				if err := app.userRepo.UpdatePassword(user.ID, rehashed); err != nil {
+					log.Printf("パスワードの再ハッシュに失敗しました: %v", err)
+				}
+			}
+		}
 
-		http.SetCookie(w, &http.Cookie{
-			Name:     "session_id",
-			Value:    sessionID,
-			Path:     "/",
-			Expires:  time.Now().Add(24 * time.Hour),
-			HttpOnly: true,
-		})
+		// 二要素認証が有効な場合はコードの入力を待つ
+		if user.TOTPSecret != "" {
+			token := generateSessionID()
+			app.pendingLogins.Store(token, pendingLogin{
+				UserID:    user.ID,
+				Username:  user.Username,
+				ExpiresAt: time.Now().Add(totpLoginTimeout),
+			})
+			http.SetCookie(w, &http.Cookie{
+				Name:     "totp_token",
+				Value:    token,
+				Path:     "/login/totp",
+				MaxAge:   int(totpLoginTimeout.Seconds()),
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+			http.Redirect(w, r, "/login/totp", http.StatusSeeOther)
+			return
+		}
 
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		app.completeLogin(w, r, user)
 	}
 }
 
-func (app *App) handleLogout(w http.ResponseWriter, r *http.Request) {
-	cookie, err := r.Cookie("session_id")
-	if err == nil {
-		app.sessionRepo.Delete(cookie.Value)
+func (app *App) handleLoginTOTP(w http.ResponseWriter, r *http.Request) {
+	token, pending := app.getPendingLogin(r)
+	if pending == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_id",
-		Value:    "",
-		Path:     "/",
-		MaxAge:   -1,
-		HttpOnly: true,
-	})
-
+	if r.Method == http.MethodGet {
+		app.render(w, r, http.StatusOK, "login_totp.tmpl", nil)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		ip := clientIP(r)
+
+		// コードの総当たりもパスワードと同じ上限で制限する
+		lockedUntil, err := app.loginAttemptRepo.LockedUntil(pending.Username, ip)
+		if err != nil {
+			http.Error(w, "ログインに失敗しました", http.StatusInternalServerError)
+			return
+		}
+		if !lockedUntil.IsZero() {
+			app.pendingLogins.Delete(token)
+			w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(lockedUntil).Seconds())+1))
+			http.Error(w, "ログインの失敗が続いたため一時的にロックしています。しばらく待ってから再度お試しください", http.StatusTooManyRequests)
+			return
+		}
+
+		user, err := app.userRepo.FindByID(pending.UserID)
+		if err != nil {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+
+		code := strings.TrimSpace(r.FormValue("code"))
+		var ok bool
+		if step, valid := auth.ValidateTOTP(user.TOTPSecret, code, time.Now()); valid {
+			ok, err = app.userRepo.UseTOTPStep(user.ID, step)
+		} else {
+			ok, err = app.userRepo.UseRecoveryCode(user.ID, auth.HashRecoveryCode(code))
+		}
+		if err != nil {
+			http.Error(w, "ログインに失敗しました", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			if err := app.loginAttemptRepo.Record(user.Username, ip, false); err != nil {
+				log.Printf("ログイン試行の記録に失敗しました: %v", err)
+			}
+			http.Error(w, "確認コードが正しくありません", http.StatusUnauthorized)
+			return
+		}
+
+		app.pendingLogins.Delete(token)
+		http.SetCookie(w, &http.Cookie{
+			Name:   "totp_token",
+			Value:  "",
+			Path:   "/login/totp",
+			MaxAge: -1,
+		})
+		app.completeLogin(w, r, user)
+	}
+}
+
+func (app *App) handleLogout(w http.ResponseWriter, r *http.Request) {
+	// 他のサイトの画像などから読み込まれてもログアウトしないよう、CSRFトークン付きのPOSTだけを受け付ける
+	if r.Method != http.MethodPost {
+		http.Error(w, "無効なメソッドです", http.StatusMethodNotAllowed)
+		return
+	}
+
+	app.sessionRepo.Delete(currentSession(r).ID)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     "session_id",
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+
 	http.Redirect(w, r, "/login", http.StatusSeeOther)
 }
 
+// talentsPerPage はタレント一覧の1ページに表示する件数。
+const talentsPerPage = 50
+
+// talentSortHeader はタレント一覧の並び替えできる列の見出し。
+type talentSortHeader struct {
+	Label  string
+	URL    string // この列で並び替えた一覧のURL。並び替え中の列では逆順にする
+	Active bool
+	Desc   bool
+}
+
+// parseTalentSort は sort と order の値を並び順に変換する。
+// sort は "name"、"created_at"、"score_<評価軸ID>" のいずれかで、空の場合は既定の並び順にする。
+func parseTalentSort(sort, order string, dimensions []model.ScoreDimension) (repository.TalentSort, bool) {
+	ts := repository.TalentSort{Desc: order == "desc"}
+	switch {
+	case sort == "":
+		return repository.TalentSort{}, true
+	case sort == repository.SortName, sort == repository.SortCreatedAt:
+		ts.Column = sort
+	case strings.HasPrefix(sort, repository.SortScore+"_"):
+		id, err := strconv.Atoi(strings.TrimPrefix(sort, repository.SortScore+"_"))
+		if err != nil || !slices.ContainsFunc(dimensions, func(d model.ScoreDimension) bool { return d.ID == id }) {
+			return ts, false
+		}
+		ts.Column, ts.DimensionID = repository.SortScore, id
+	default:
+		return ts, false
+	}
+	return ts, order == "asc" || order == "desc"
+}
+
+// scoreFilterField は絞り込みフォームの評価軸ごとのスコアの範囲の入力欄。
+type scoreFilterField struct {
+	model.ScoreDimension
+	Min string
+	Max string
+}
+
+// parseTalentFilter はタレント一覧のクエリを絞り込みの条件に変換する。
+// 空の値は指定しなかったものとして扱い、条件に使った値だけを一覧のURLに引き継ぐために返す。
+func parseTalentFilter(params url.Values, dimensions []model.ScoreDimension) (repository.TalentFilter, url.Values, error) {
+	var filter repository.TalentFilter
+	values := url.Values{}
+
+	if q := params.Get("q"); q != "" {
+		filter.Search = q
+		values.Set("q", q)
+	}
+	if params.Get("favorite") == "true" {
+		filter.FavoriteOnly = true
+		values.Set("favorite", "true")
+	}
+	// ?tag=A&tag=B はいずれかのタグ、match=all を付けるとすべてのタグが付いたタレントに絞り込む
+	for _, tag := range params["tag"] {
+		if tag != "" {
+			filter.Tags = append(filter.Tags, tag)
+			values.Add("tag", tag)
+		}
+	}
+	if len(filter.Tags) > 0 && params.Get("match") == "all" {
+		filter.MatchAll = true
+		values.Set("match", "all")
+	}
+	if v := params.Get("affiliation"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			return filter, nil, errors.New("所属の指定が正しくありません")
+		}
+		filter.AffiliationID = id
+		values.Set("affiliation", v)
+	}
+
+	for _, d := range dimensions {
+		r := repository.ScoreRange{DimensionID: d.ID}
+		for _, bound := range []struct {
+			key string
+			dst *sql.NullInt64
+		}{
+			{"min_" + strconv.Itoa(d.ID), &r.Min},
+			{"max_" + strconv.Itoa(d.ID), &r.Max},
+		} {
+			v := params.Get(bound.key)
+			if v == "" {
+				continue
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return filter, nil, fmt.Errorf("%sのスコアの範囲が正しくありません", d.Name)
+			}
+			*bound.dst = sql.NullInt64{Int64: int64(n), Valid: true}
+			values.Set(bound.key, v)
+		}
+		if r.Min.Valid || r.Max.Valid {
+			filter.Scores = append(filter.Scores, r)
+		}
+	}
+
+	// 登録日は画面の日付で指定し、終わりの日も含める
+	if v := params.Get("created_from"); v != "" {
+		from, err := time.ParseInLocation(time.DateOnly, v, time.Local)
+		if err != nil {
+			return filter, nil, errors.New("登録日の指定が正しくありません")
+		}
+		filter.CreatedFrom = from
+		values.Set("created_from", v)
+	}
+	if v := params.Get("created_to"); v != "" {
+		to, err := time.ParseInLocation(time.DateOnly, v, time.Local)
+		if err != nil {
+			return filter, nil, errors.New("登録日の指定が正しくありません")
+		}
+		filter.CreatedBefore = to.AddDate(0, 0, 1)
+		values.Set("created_to", v)
+	}
+
+	return filter, values, nil
+}
+
+// talentListURL は params にクエリを追加した一覧のURLを作る。params は変更しない。
+func talentListURL(params url.Values, pairs ...string) string {
+	values := maps.Clone(params)
+	if values == nil {
+		values = url.Values{}
+	}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		values.Set(pairs[i], pairs[i+1])
+	}
+	if len(values) == 0 {
+		return "/talents"
+	}
+	return "/talents?" + values.Encode()
+}
+
 func (app *App) handleTalents(w http.ResponseWriter, r *http.Request) {
-	if !app.requireAuth(w, r) {
+	userID := currentUser(r).ID
+	params := r.URL.Query()
+
+	dimensions, err := app.dimensionRepo.FindByUserID(userID)
+	if err != nil {
+		http.Error(w, "評価軸の取得に失敗しました", http.StatusInternalServerError)
 		return
 	}
 
-	username := app.getUsername(r)
-	userID, err := app.userRepo.GetID(username)
+	// filter はページや並び順を変えても保つ絞り込みの条件
+	talentFilter, filter, err := parseTalentFilter(params, dimensions)
 	if err != nil {
-		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sort, ok := parseTalentSort(params.Get("sort"), params.Get("order"), dimensions)
+	if !ok {
+		http.Error(w, "並び順の指定が正しくありません", http.StatusBadRequest)
 		return
 	}
+	if sort.Column == repository.SortDefault && talentFilter.Search != "" {
+		// 並び順を指定せずに検索した場合は関連度の高い順にする
+		sort.Column = repository.SortRelevance
+	}
+	query := repository.TalentQuery{Filter: talentFilter, Sort: sort}
 
-	searchQuery := r.URL.Query().Get("q")
-	favoriteOnly := r.URL.Query().Get("favorite") == "true"
-	var talents []model.Talent
+	sorted := maps.Clone(filter)
+	if sort.Column != repository.SortDefault {
+		sorted.Set("sort", params.Get("sort"))
+		sorted.Set("order", params.Get("order"))
+	}
 
-	if favoriteOnly {
-		talents, err = app.talentRepo.FindFavoritesByUserID(userID)
-	} else if searchQuery != "" {
-		talents, err = app.talentRepo.SearchByUserID(userID, searchQuery)
-	} else {
-		talents, err = app.talentRepo.FindByUserID(userID)
+	if format := params.Get("format"); format != "" {
+		// 書き出しはページに分けず、絞り込みに一致するすべてのタレントを対象にする
+		page, err := app.talentRepo.FindPage(userID, query)
+		if err != nil {
+			http.Error(w, "タレント一覧の取得に失敗しました", http.StatusInternalServerError)
+			return
+		}
+		app.writeTalentExport(w, format, dimensions, page.Talents)
+		return
 	}
 
+	query.After, query.Before, query.Limit = params.Get("after"), params.Get("before"), talentsPerPage
+	page, err := app.talentRepo.FindPage(userID, query)
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, "タレント一覧の取得に失敗しました", http.StatusInternalServerError)
 		return
 	}
 
-	app.tmpl.ExecuteTemplate(w, "talents.tmpl", map[string]any{
-		"Talents":      talents,
-		"SearchQuery":  searchQuery,
-		"FavoriteOnly": favoriteOnly,
-	})
+	tags, err := app.tagRepo.FindByUserID(userID)
+	if err != nil {
+		http.Error(w, "タグの取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+	affiliations, err := app.affiliationRepo.FindByUserID(userID)
+	if err != nil {
+		http.Error(w, "所属の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+	savedSearches, err := app.savedSearchItems(userID, dimensions, filter)
+	if err != nil {
+		http.Error(w, "保存した検索の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+	scoreFields := make([]scoreFilterField, len(dimensions))
+	for i, d := range dimensions {
+		id := strconv.Itoa(d.ID)
+		scoreFields[i] = scoreFilterField{ScoreDimension: d, Min: filter.Get("min_" + id), Max: filter.Get("max_" + id)}
+	}
+
+	// 見出しをクリックすると、名前は昇順、スコアと登録日時は降順から並び替える
+	header := func(label, key string, desc bool) talentSortHeader {
+		h := talentSortHeader{Label: label}
+		if params.Get("sort") == key {
+			h.Active, h.Desc = true, sort.Desc
+			desc = !sort.Desc
+		}
+		order := "asc"
+		if desc {
+			order = "desc"
+		}
+		h.URL = talentListURL(filter, "sort", key, "order", order)
+		return h
+	}
+	scoreHeaders := make([]talentSortHeader, len(dimensions))
+	for i, d := range dimensions {
+		scoreHeaders[i] = header(d.Name, repository.SortScore+"_"+strconv.Itoa(d.ID), true)
+	}
+
+	exportURLs := make(map[string]string, len(talentExportFormats))
+	for format := range talentExportFormats {
+		exportURLs[format] = talentListURL(sorted, "format", format)
+	}
+
+	data := map[string]any{
+		"NameHeader":      header("名前", repository.SortName, false),
+		"ScoreHeaders":    scoreHeaders,
+		"CreatedAtHeader": header("登録日時", repository.SortCreatedAt, true),
+		"ColumnCount":     len(dimensions) + 5,
+		"Talents":         page.Talents,
+		"TotalCount":      page.TotalCount,
+		"Snippets":        page.Snippets,
+		"Filtered":        len(filter) > 0,
+		"FilterQuery":     filter.Encode(),
+		"SavedSearches":   savedSearches,
+		"SearchQuery":     talentFilter.Search,
+		"FavoriteOnly":    talentFilter.FavoriteOnly,
+		"TagFields":       tagFilterFields(tags, talentFilter.Tags),
+		"MatchAll":        talentFilter.MatchAll,
+		"Affiliations":    affiliations,
+		"AffiliationID":   talentFilter.AffiliationID,
+		"ScoreFields":     scoreFields,
+		"CreatedFrom":     filter.Get("created_from"),
+		"CreatedTo":       filter.Get("created_to"),
+		"Sort":            params.Get("sort"),
+		"Order":           params.Get("order"),
+		"ExportURLs":      exportURLs,
+	}
+	if page.PrevCursor != "" {
+		data["FirstURL"] = talentListURL(sorted)
+		data["PrevURL"] = talentListURL(sorted, "before", page.PrevCursor)
+	}
+	if page.NextCursor != "" {
+		data["NextURL"] = talentListURL(sorted, "after", page.NextCursor)
+	}
+	app.render(w, r, http.StatusOK, "talents.tmpl", data)
+}
+
+// talentExportFormats は一覧の書き出しに対応する形式と Content-Type。
+var talentExportFormats = map[string]string{
+	"csv":  "text/csv; charset=utf-8",
+	"json": "application/json; charset=utf-8",
+	"xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// writeTalentExport は画面に表示しているタレント一覧を指定の形式で書き出す。
+func (app *App) writeTalentExport(w http.ResponseWriter, format string, dimensions []model.ScoreDimension, talents []model.Talent) {
+	contentType, ok := talentExportFormats[format]
+	if !ok {
+		http.Error(w, "対応していない形式です", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	columns := export.NewDimensions(dimensions)
+	rows := export.NewTalents(talents)
+
+	// 途中で失敗したときにエラーを返せるよう、書き出してからレスポンスを送る
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "csv":
+		err = export.WriteTalentsCSV(&buf, columns, rows)
+	case "json":
+		err = export.WriteTalentsJSON(&buf, rows)
+	case "xlsx":
+		err = export.WriteTalentsXLSX(&buf, columns, rows, now)
+	}
+	if err != nil {
+		http.Error(w, "データの書き出しに失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	filename := "maiyumi-talents-" + now.Format("20060102-150405") + "." + format
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	buf.WriteTo(w)
+}
+
+// scoreField はタレントのフォームの評価軸ごとの入力欄。
+type scoreField struct {
+	model.ScoreDimension
+	Value string
+}
+
+// scoreFields は評価軸ごとの入力欄を作る。talent が nil の場合は範囲の中央の値を初期値にする。
+func scoreFields(dimensions []model.ScoreDimension, talent *model.Talent) []scoreField {
+	fields := make([]scoreField, len(dimensions))
+	for i, d := range dimensions {
+		fields[i] = scoreField{ScoreDimension: d}
+		if talent == nil {
+			fields[i].Value = strconv.Itoa((d.MinScore + d.MaxScore) / 2)
+			continue
+		}
+		for _, s := range talent.Scores {
+			if s.DimensionID == d.ID && s.Rated {
+				fields[i].Value = strconv.Itoa(s.Value)
+			}
+		}
+	}
+	return fields
+}
+
+// scoresFromForm はフォームの score_<評価軸ID> の値からスコアを作る。整数でない評価軸は含めない。
+func scoresFromForm(r *http.Request, dimensions []model.ScoreDimension) []model.Score {
+	var scores []model.Score
+	for _, d := range dimensions {
+		value, err := strconv.Atoi(r.FormValue("score_" + strconv.Itoa(d.ID)))
+		if err != nil {
+			continue
+		}
+		scores = append(scores, model.Score{DimensionID: d.ID, Name: d.Name, Value: value, Rated: true})
+	}
+	return scores
+}
+
+// tagField はタグの選択欄の1項目。
+type tagField struct {
+	model.Tag
+	Checked bool
+}
+
+// tagFields はタグの選択欄を作る。talent が nil でなければ付いているタグを選択済みにする。
+func tagFields(tags []model.Tag, talent *model.Talent) []tagField {
+	fields := make([]tagField, len(tags))
+	for i, tag := range tags {
+		fields[i] = tagField{Tag: tag}
+		if talent == nil {
+			continue
+		}
+		for _, t := range talent.Tags {
+			if t.ID == tag.ID {
+				fields[i].Checked = true
+			}
+		}
+	}
+	return fields
+}
+
+// tagFilterFields は一覧の絞り込み欄を作る。selected に含まれる名前のタグを選択済みにする。
+func tagFilterFields(tags []model.Tag, selected []string) []tagField {
+	fields := make([]tagField, len(tags))
+	for i, tag := range tags {
+		fields[i] = tagField{Tag: tag, Checked: slices.Contains(selected, tag.Name)}
+	}
+	return fields
+}
+
+// tagsFromForm はフォームの tag_id の値のうち、ユーザーのタグに含まれるものを返す。
+func tagsFromForm(r *http.Request, tags []model.Tag) []model.Tag {
+	var selected []model.Tag
+	for _, tag := range tags {
+		if slices.Contains(r.Form["tag_id"], strconv.Itoa(tag.ID)) {
+			selected = append(selected, tag)
+		}
+	}
+	return selected
 }
 
 func (app *App) handleTalentNew(w http.ResponseWriter, r *http.Request) {
-	if !app.requireAuth(w, r) {
+	userID := currentUser(r).ID
+
+	dimensions, err := app.dimensionRepo.FindByUserID(userID)
+	if err != nil {
+		http.Error(w, "評価軸の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	tags, err := app.tagRepo.FindByUserID(userID)
+	if err != nil {
+		http.Error(w, "タグの取得に失敗しました", http.StatusInternalServerError)
 		return
 	}
 
 	if r.Method == http.MethodGet {
-		app.tmpl.ExecuteTemplate(w, "talent_form.tmpl", map[string]any{
-			"IsEdit": false,
+		app.render(w, r, http.StatusOK, "talent_form.tmpl", map[string]any{
+			"IsEdit":      false,
+			"ScoreFields": scoreFields(dimensions, nil),
+			"TagFields":   tagFields(tags, nil),
 		})
 		return
 	}
 
 	if r.Method == http.MethodPost {
-		username := app.getUsername(r)
-		userID, err := app.userRepo.GetID(username)
-		if err != nil {
-			http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusInternalServerError)
-			return
-		}
-
 		name := r.FormValue("name")
 		affiliation := r.FormValue("affiliation")
-		beauty, _ := strconv.Atoi(r.FormValue("beauty"))
-		cuteness, _ := strconv.Atoi(r.FormValue("cuteness"))
-		talent, _ := strconv.Atoi(r.FormValue("talent"))
 
-		if name == "" || beauty < 1 || beauty > 10 || cuteness < 1 || cuteness > 10 || talent < 1 || talent > 10 {
-			http.Error(w, "入力値が不正です", http.StatusBadRequest)
-			return
+		newTalent := &model.Talent{
+			UserID: userID,
+			Name:   name,
+			Scores: scoresFromForm(r, dimensions),
+			Tags:   tagsFromForm(r, tags),
 		}
 
-		newTalent := &model.Talent{
-			UserID:   userID,
-			Name:     name,
-			Beauty:   beauty,
-			Cuteness: cuteness,
-			Talent:   talent,
+		if model.ValidateTalent(newTalent, dimensions) != nil {
+			http.Error(w, "入力値が不正です", http.StatusBadRequest)
+			return
 		}
 
 		if affiliation != "" {
@@ -396,373 +1556,1384 @@ func (app *App) handleTalentNew(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		http.Redirect(w, r, "/talents", http.StatusSeeOther)
+		http.Redirect(w, r, "/talents", http.StatusSeeOther)
+	}
+}
+
+func (app *App) handleTalentImport(w http.ResponseWriter, r *http.Request) {
+	userID := currentUser(r).ID
+
+	dimensions, err := app.dimensionRepo.FindByUserID(userID)
+	if err != nil {
+		http.Error(w, "評価軸の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]any{
+		"Dimensions": dimensions,
+	}
+	renderErrors := func(problems ...string) {
+		data["Errors"] = problems
+		app.render(w, r, http.StatusBadRequest, "talent_import.tmpl", data)
+	}
+
+	if r.Method == http.MethodGet {
+		app.render(w, r, http.StatusOK, "talent_import.tmpl", data)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		// 確認画面から送られた場合はCSVを読み直して検証し、正しい行だけを登録する
+		if r.FormValue("confirm") != "" {
+			rows, err := export.ReadTalentsCSV(strings.NewReader(r.FormValue("csv")), dimensions)
+			if err != nil {
+				renderErrors("CSVを読み込めませんでした: " + err.Error())
+				return
+			}
+
+			var talents []*model.Talent
+			for _, row := range rows {
+				if row.Valid() {
+					talents = append(talents, row.Model(userID))
+				}
+			}
+			if len(talents) == 0 {
+				renderErrors("取り込めるタレントがありません")
+				return
+			}
+
+			if err := app.talentRepo.CreateMany(talents); err != nil {
+				http.Error(w, "タレントの取り込みに失敗しました", http.StatusInternalServerError)
+				return
+			}
+
+			http.Redirect(w, r, "/talents", http.StatusSeeOther)
+			return
+		}
+
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			renderErrors("CSVファイルを選択してください")
+			return
+		}
+		defer file.Close()
+
+		if header.Size > maxImportFileSize {
+			renderErrors(fmt.Sprintf("ファイルサイズは%dKBまでです", maxImportFileSize/1024))
+			return
+		}
+
+		content, err := io.ReadAll(io.LimitReader(file, maxImportFileSize))
+		if err != nil {
+			http.Error(w, "ファイルの読み込みに失敗しました", http.StatusInternalServerError)
+			return
+		}
+		if !utf8.Valid(content) {
+			renderErrors("文字コードをUTF-8にして保存したCSVを選択してください")
+			return
+		}
+
+		rows, err := export.ReadTalentsCSV(bytes.NewReader(content), dimensions)
+		if err != nil {
+			renderErrors("CSVを読み込めませんでした: " + err.Error())
+			return
+		}
+
+		validCount := 0
+		for _, row := range rows {
+			if row.Valid() {
+				validCount++
+			}
+		}
+
+		data["Rows"] = rows
+		data["ValidCount"] = validCount
+		data["InvalidCount"] = len(rows) - validCount
+		data["CSV"] = string(content)
+		app.render(w, r, http.StatusOK, "talent_import.tmpl", data)
+	}
+}
+
+func (app *App) handleTalentEdit(w http.ResponseWriter, r *http.Request) {
+	talentID, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "無効なIDです", http.StatusBadRequest)
+		return
+	}
+
+	userID := currentUser(r).ID
+
+	dimensions, err := app.dimensionRepo.FindByUserID(userID)
+	if err != nil {
+		http.Error(w, "評価軸の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	tags, err := app.tagRepo.FindByUserID(userID)
+	if err != nil {
+		http.Error(w, "タグの取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		talent, err := app.talentRepo.FindByID(talentID, userID)
+		if err != nil {
+			http.Error(w, "タレント情報の取得に失敗しました", http.StatusNotFound)
+			return
+		}
+
+		app.render(w, r, http.StatusOK, "talent_form.tmpl", map[string]any{
+			"IsEdit":      true,
+			"Talent":      talent,
+			"ScoreFields": scoreFields(dimensions, talent),
+			"TagFields":   tagFields(tags, talent),
+		})
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		name := r.FormValue("name")
+		affiliation := r.FormValue("affiliation")
+
+		updateTalent := &model.Talent{
+			ID:     talentID,
+			UserID: userID,
+			Name:   name,
+			Scores: scoresFromForm(r, dimensions),
+			Tags:   tagsFromForm(r, tags),
+		}
+
+		if model.ValidateTalent(updateTalent, dimensions) != nil {
+			http.Error(w, "入力値が不正です", http.StatusBadRequest)
+			return
+		}
+
+		if affiliation != "" {
+			updateTalent.Affiliation = sql.NullString{String: affiliation, Valid: true}
+		}
+
+		if err := app.talentRepo.Update(updateTalent); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "タレント情報が見つかりません", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "タレント情報の更新に失敗しました", http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, "/talents/detail?id="+strconv.Itoa(talentID), http.StatusSeeOther)
+	}
+}
+
+func (app *App) handleTalentDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "無効なメソッドです", http.StatusMethodNotAllowed)
+		return
+	}
+
+	talentID, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "無効なIDです", http.StatusBadRequest)
+		return
+	}
+
+	userID := currentUser(r).ID
+
+	if err := app.talentRepo.Delete(talentID, userID); err != nil {
+		http.Error(w, "タレントをごみ箱に移せませんでした", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/talents", http.StatusSeeOther)
+}
+
+// trashItem はごみ箱のタレントと、自動で削除される日時。
+type trashItem struct {
+	model.Talent
+	PurgeAt time.Time
+}
+
+func (app *App) handleTrash(w http.ResponseWriter, r *http.Request) {
+	talents, err := app.talentRepo.FindTrash(currentUser(r).ID)
+	if err != nil {
+		http.Error(w, "ごみ箱の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	items := make([]trashItem, len(talents))
+	for i, talent := range talents {
+		items[i] = trashItem{Talent: talent, PurgeAt: talent.DeletedAt.Time.Add(app.trashRetention)}
+	}
+
+	app.render(w, r, http.StatusOK, "trash.tmpl", map[string]any{
+		"Talents":       items,
+		"RetentionDays": int(app.trashRetention / (24 * time.Hour)),
+	})
+}
+
+func (app *App) handleTrashRestore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "無効なメソッドです", http.StatusMethodNotAllowed)
+		return
+	}
+
+	talentID, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "無効なIDです", http.StatusBadRequest)
+		return
+	}
+
+	if err := app.talentRepo.Restore(talentID, currentUser(r).ID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "ごみ箱にタレントが見つかりません", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "タレントの復元に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/talents/detail?id="+strconv.Itoa(talentID), http.StatusSeeOther)
+}
+
+func (app *App) handleTrashDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "無効なメソッドです", http.StatusMethodNotAllowed)
+		return
+	}
+
+	talentID, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "無効なIDです", http.StatusBadRequest)
+		return
+	}
+
+	if err := app.talentRepo.DeletePermanently(talentID, currentUser(r).ID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "ごみ箱にタレントが見つかりません", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "タレントの削除に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/talents/trash", http.StatusSeeOther)
+}
+
+func (app *App) handleTalentAdjust(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "無効なメソッドです", http.StatusMethodNotAllowed)
+		return
+	}
+
+	talentID, err := strconv.Atoi(r.FormValue("talent_id"))
+	if err != nil {
+		http.Error(w, "無効なIDです", http.StatusBadRequest)
+		return
+	}
+
+	userID := currentUser(r).ID
+
+	exists, err := app.talentRepo.Exists(talentID, userID)
+	if err != nil || !exists {
+		http.Error(w, "タレント情報が見つかりません", http.StatusNotFound)
+		return
+	}
+
+	dimensionID, _ := strconv.Atoi(r.FormValue("dimension_id"))
+	points, err := strconv.Atoi(r.FormValue("points"))
+	if err != nil {
+		http.Error(w, "点数は整数で入力してください", http.StatusBadRequest)
+		return
+	}
+	if _, err := app.dimensionRepo.FindByID(dimensionID, userID); err != nil {
+		http.Error(w, "入力値が不正です", http.StatusBadRequest)
+		return
+	}
+
+	adjustment := &model.Adjustment{
+		TalentID:    talentID,
+		DimensionID: dimensionID,
+		Points:      points,
+		Reason:      strings.TrimSpace(r.FormValue("reason")),
+	}
+	if problems := model.ValidateAdjustment(adjustment); len(problems) > 0 {
+		http.Error(w, strings.Join(problems, "\n"), http.StatusBadRequest)
+		return
+	}
+
+	if err := app.adjustmentRepo.Create(adjustment); err != nil {
+		http.Error(w, "調整の追加に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/talents/detail?id="+strconv.Itoa(talentID), http.StatusSeeOther)
+}
+
+func (app *App) handleAdjustmentEdit(w http.ResponseWriter, r *http.Request) {
+	adjustmentID, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "無効なIDです", http.StatusBadRequest)
+		return
+	}
+
+	userID := currentUser(r).ID
+
+	adjustment, err := app.adjustmentRepo.FindByID(adjustmentID, userID)
+	if err != nil {
+		http.Error(w, "調整履歴が見つかりません", http.StatusNotFound)
+		return
+	}
+
+	data := map[string]any{
+		"Adjustment":          adjustment,
+		"MinAdjustmentPoints": model.MinAdjustmentPoints,
+		"MaxAdjustmentPoints": model.MaxAdjustmentPoints,
+	}
+	renderErrors := func(problems ...string) {
+		data["Errors"] = problems
+		app.render(w, r, http.StatusBadRequest, "adjustment_form.tmpl", data)
+	}
+
+	if r.Method == http.MethodGet {
+		app.render(w, r, http.StatusOK, "adjustment_form.tmpl", data)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		points, err := strconv.Atoi(r.FormValue("points"))
+		if err != nil {
+			renderErrors("点数は整数で入力してください")
+			return
+		}
+		adjustment.Points = points
+		adjustment.Reason = strings.TrimSpace(r.FormValue("reason"))
+
+		if problems := model.ValidateAdjustment(adjustment); len(problems) > 0 {
+			renderErrors(problems...)
+			return
+		}
+
+		if err := app.adjustmentRepo.Update(adjustment, userID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "調整履歴が見つかりません", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "調整履歴の更新に失敗しました", http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, "/talents/detail?id="+strconv.Itoa(adjustment.TalentID), http.StatusSeeOther)
+	}
+}
+
+func (app *App) handleAdjustmentDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "無効なメソッドです", http.StatusMethodNotAllowed)
+		return
+	}
+
+	adjustmentID, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "無効なIDです", http.StatusBadRequest)
+		return
+	}
+
+	userID := currentUser(r).ID
+
+	// 削除後にタレントの詳細に戻るため、先にタレントを確かめる
+	adjustment, err := app.adjustmentRepo.FindByID(adjustmentID, userID)
+	if err != nil {
+		http.Error(w, "調整履歴が見つかりません", http.StatusNotFound)
+		return
+	}
+
+	if err := app.adjustmentRepo.Delete(adjustmentID, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "調整履歴が見つかりません", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "調整履歴の削除に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/talents/detail?id="+strconv.Itoa(adjustment.TalentID), http.StatusSeeOther)
+}
+
+func (app *App) handleTalentDetail(w http.ResponseWriter, r *http.Request) {
+	talentID, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "無効なIDです", http.StatusBadRequest)
+		return
+	}
+
+	userID := currentUser(r).ID
+
+	talent, err := app.talentRepo.FindByID(talentID, userID)
+	if err != nil {
+		http.Error(w, "タレント情報の取得に失敗しました", http.StatusNotFound)
+		return
+	}
+
+	adjustments, err := app.adjustmentRepo.FindByTalentID(talentID)
+	if err != nil {
+		http.Error(w, "履歴の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	changes, err := app.auditRepo.Find(repository.AuditFilter{
+		UserID:     userID,
+		EntityType: model.AuditEntityAdjustment,
+		TalentID:   talentID,
+	})
+	if err != nil {
+		http.Error(w, "履歴の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+	// 追加した調整は調整履歴の一覧に表示しているため、編集と削除だけを表示する
+	changes = slices.DeleteFunc(changes, func(e model.AuditEvent) bool { return e.Action == model.AuditActionCreate })
+
+	revisions, err := app.talentRepo.FindRevisions(talentID, userID)
+	if err != nil {
+		http.Error(w, "編集履歴の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+	// 新しい版から表示する
+	slices.Reverse(revisions)
+
+	app.render(w, r, http.StatusOK, "talent_detail.tmpl", map[string]any{
+		"Talent":            talent,
+		"Adjustments":       adjustments,
+		"AdjustmentChanges": changes,
+		"ActionLabels":      auditLabels(auditActionOptions),
+		"Revisions":         revisions,
+	})
+}
+
+func (app *App) handleTalentRevert(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "無効なメソッドです", http.StatusMethodNotAllowed)
+		return
+	}
+
+	talentID, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "無効なIDです", http.StatusBadRequest)
+		return
+	}
+	revisionID, err := strconv.Atoi(r.FormValue("revision"))
+	if err != nil {
+		http.Error(w, "無効な版です", http.StatusBadRequest)
+		return
+	}
+
+	userID := currentUser(r).ID
+
+	talent, err := app.talentRepo.FindByID(talentID, userID)
+	if err != nil {
+		http.Error(w, "タレント情報の取得に失敗しました", http.StatusNotFound)
+		return
+	}
+
+	revisions, err := app.talentRepo.FindRevisions(talentID, userID)
+	if err != nil {
+		http.Error(w, "編集履歴の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+	i := slices.IndexFunc(revisions, func(rev model.TalentRevision) bool { return rev.ID == revisionID })
+	if i < 0 {
+		http.Error(w, "版が見つかりません", http.StatusNotFound)
+		return
+	}
+	revision := revisions[i]
+
+	dimensions, err := app.dimensionRepo.FindByUserID(userID)
+	if err != nil {
+		http.Error(w, "評価軸の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	reverted := &model.Talent{
+		ID:     talentID,
+		UserID: userID,
+		Name:   revision.Name,
+		Tags:   revision.Tags,
+	}
+	if revision.Affiliation != "" {
+		reverted.Affiliation = sql.NullString{String: revision.Affiliation, Valid: true}
+	}
+	// 版を記録した後に追加した評価軸は、今のスコアのままにする
+	for _, d := range dimensions {
+		scores := talent.Scores
+		if slices.ContainsFunc(revision.Scores, func(s model.Score) bool { return s.DimensionID == d.ID }) {
+			scores = revision.Scores
+		}
+		if j := slices.IndexFunc(scores, func(s model.Score) bool { return s.DimensionID == d.ID }); j >= 0 {
+			reverted.Scores = append(reverted.Scores, model.Score{DimensionID: d.ID, Name: d.Name, Value: scores[j].Value, Rated: scores[j].Rated})
+		}
+	}
+
+	if problems := model.ValidateTalent(reverted, dimensions); problems != nil {
+		app.renderError(w, r, http.StatusBadRequest,
+			"版#"+strconv.Itoa(revision.Number)+"に戻せません: "+strings.Join(problems, "、"))
+		return
+	}
+
+	if err := app.talentRepo.Revert(reverted, revisionID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "版が見つかりません", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "版に戻せませんでした", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/talents/detail?id="+strconv.Itoa(talentID), http.StatusSeeOther)
+}
+
+func (app *App) handleTalentToggleFavorite(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "無効なメソッドです", http.StatusMethodNotAllowed)
+		return
+	}
+
+	talentID, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "無効なIDです", http.StatusBadRequest)
+		return
+	}
+
+	userID := currentUser(r).ID
+
+	if err := app.talentRepo.ToggleFavorite(talentID, userID); err != nil {
+		http.Error(w, "お気に入りの切り替えに失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/talents", http.StatusSeeOther)
+}
+
+func (app *App) handleMyPage(w http.ResponseWriter, r *http.Request) {
+	app.render(w, r, http.StatusOK, "mypage.tmpl", map[string]any{
+		"User": currentUser(r),
+	})
+}
+
+func (app *App) handleUpdateUsername(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		app.render(w, r, http.StatusOK, "username_form.tmpl", map[string]any{
+			"Username": currentUser(r).Username,
+		})
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		userID := currentUser(r).ID
+
+		newUsername := r.FormValue("username")
+
+		renderErrors := func(problems ...string) {
+			app.render(w, r, http.StatusBadRequest, "username_form.tmpl", map[string]any{
+				"Username": newUsername,
+				"Errors":   problems,
+			})
+		}
+
+		if newUsername == "" {
+			renderErrors("ユーザー名を入力してください")
+			return
+		}
+
+		if err := app.userRepo.UpdateUsername(userID, newUsername); err != nil {
+			var usernameErr *repository.UsernameError
+			if errors.Is(err, repository.ErrDuplicateUsername) || errors.As(err, &usernameErr) {
+				renderErrors(err.Error())
+				return
+			}
+			http.Error(w, "ユーザー名の更新に失敗しました", http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, "/mypage", http.StatusSeeOther)
+	}
+}
+
+func (app *App) handleUpdatePassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		app.render(w, r, http.StatusOK, "password_form.tmpl", nil)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		user := currentUser(r)
+
+		currentPassword := r.FormValue("current_password")
+		newPassword := r.FormValue("new_password")
+		confirmPassword := r.FormValue("confirm_password")
+
+		renderErrors := func(status int, problems ...string) {
+			app.render(w, r, status, "password_form.tmpl", map[string]any{
+				"Errors": problems,
+			})
+		}
+
+		if currentPassword == "" || newPassword == "" || confirmPassword == "" {
+			renderErrors(http.StatusBadRequest, "すべてのフィールドを入力してください")
+			return
+		}
+
+		if newPassword != confirmPassword {
+			renderErrors(http.StatusBadRequest, "新しいパスワードが一致しません")
+			return
+		}
+
+		if ok, _ := auth.VerifyPassword(currentPassword, user.Password); !ok {
+			renderErrors(http.StatusUnauthorized, "現在のパスワードが正しくありません")
+			return
+		}
+
+		if problems := app.passwordPolicy.Validate(user.Username, newPassword); problems != nil {
+			renderErrors(http.StatusBadRequest, problems...)
+			return
+		}
+
+		hashedPassword, err := auth.HashPassword(newPassword)
+		if err != nil {
+			http.Error(w, "パスワードのハッシュ化に失敗しました", http.StatusInternalServerError)
+			return
+		}
+
+		if err := app.userRepo.UpdatePassword(user.ID, hashedPassword); err != nil {
+			http.Error(w, "パスワードの更新に失敗しました", http.StatusInternalServerError)
+			return
+		}
+
+		// パスワード変更時は他の端末のセッションを無効にする
+		if err := app.sessionRepo.DeleteByUserID(user.ID, currentSession(r).ID); err != nil {
+			log.Printf("他のセッションの削除に失敗しました: %v", err)
+		}
+
+		http.Redirect(w, r, "/mypage", http.StatusSeeOther)
+	}
+}
+
+func (app *App) handleTOTP(w http.ResponseWriter, r *http.Request) {
+	user := currentUser(r)
+
+	if r.Method == http.MethodGet {
+		if user.TOTPSecret != "" {
+			remaining, err := app.userRepo.CountRecoveryCodes(user.ID)
+			if err != nil {
+				http.Error(w, "リカバリーコードの取得に失敗しました", http.StatusInternalServerError)
+				return
+			}
+			app.render(w, r, http.StatusOK, "totp.tmpl", map[string]any{
+				"Enabled":        true,
+				"RemainingCodes": remaining,
+			})
+			return
+		}
+
+		secret, err := auth.GenerateTOTPSecret()
+		if err != nil {
+			http.Error(w, "秘密鍵の生成に失敗しました", http.StatusInternalServerError)
+			return
+		}
+		app.render(w, r, http.StatusOK, "totp.tmpl", map[string]any{
+			"Secret": secret,
+			"URI":    template.URL(auth.TOTPURI(totpIssuer, user.Username, secret)),
+		})
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		// 有効なまま登録し直すと、無効化に必要なパスワードの確認を経ずに秘密鍵とリカバリーコードを入れ替えられてしまう
+		if user.TOTPSecret != "" {
+			http.Error(w, "二要素認証は既に有効です。設定し直す場合は一度無効にしてください", http.StatusConflict)
+			return
+		}
+
+		secret := r.FormValue("secret")
+		code := strings.TrimSpace(r.FormValue("code"))
+
+		// 認証アプリに正しく登録できたことをコードで確認してから有効にする
+		step, ok := auth.ValidateTOTP(secret, code, time.Now())
+		if !ok {
+			http.Error(w, "確認コードが正しくありません", http.StatusBadRequest)
+			return
+		}
+
+		codes, err := auth.GenerateRecoveryCodes()
+		if err != nil {
+			http.Error(w, "リカバリーコードの生成に失敗しました", http.StatusInternalServerError)
+			return
+		}
+		hashes := make([]string, len(codes))
+		for i, c := range codes {
+			hashes[i] = auth.HashRecoveryCode(c)
+		}
+
+		if err := app.userRepo.EnableTOTP(user.ID, secret, hashes); err != nil {
+			http.Error(w, "二要素認証の設定に失敗しました", http.StatusInternalServerError)
+			return
+		}
+		if _, err := app.userRepo.UseTOTPStep(user.ID, step); err != nil {
+			log.Printf("使用済みステップの記録に失敗しました: %v", err)
+		}
+
+		app.render(w, r, http.StatusOK, "totp.tmpl", map[string]any{
+			"Enabled":        true,
+			"RemainingCodes": len(codes),
+			"RecoveryCodes":  codes,
+		})
+	}
+}
+
+func (app *App) handleTOTPDisable(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "無効なメソッドです", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := currentUser(r)
+	if ok, _ := auth.VerifyPassword(r.FormValue("current_password"), user.Password); !ok {
+		http.Error(w, "現在のパスワードが正しくありません", http.StatusUnauthorized)
+		return
+	}
+
+	if err := app.userRepo.DisableTOTP(user.ID); err != nil {
+		http.Error(w, "二要素認証の無効化に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/mypage/totp", http.StatusSeeOther)
+}
+
+func (app *App) handleExport(w http.ResponseWriter, r *http.Request) {
+	user := currentUser(r)
+
+	talents, err := app.talentRepo.FindByUserID(user.ID)
+	if err != nil {
+		http.Error(w, "タレント一覧の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	adjustments, err := app.adjustmentRepo.FindByUserID(user.ID)
+	if err != nil {
+		http.Error(w, "調整履歴の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	dimensions, err := app.dimensionRepo.FindByUserID(user.ID)
+	if err != nil {
+		http.Error(w, "評価軸の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	archive := export.NewArchive(user, dimensions, talents, adjustments, now)
+
+	// 途中で失敗したときにエラーを返せるよう、書き出してからレスポンスを送る
+	var buf bytes.Buffer
+	if err := export.WriteZIP(&buf, archive); err != nil {
+		http.Error(w, "データの書き出しに失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	filename := "maiyumi-export-" + now.Format("20060102-150405") + ".zip"
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	buf.WriteTo(w)
+}
+
+// dimensionFromForm はフォームの値から評価軸を作る。整数として読めない項目があれば問題点を返す。
+func dimensionFromForm(r *http.Request, userID int) (*model.ScoreDimension, []string) {
+	d := &model.ScoreDimension{
+		UserID: userID,
+		Name:   strings.TrimSpace(r.FormValue("name")),
+	}
+
+	var problems []string
+	fields := []struct {
+		key   string
+		label string
+		dest  *int
+	}{
+		{"min_score", "最小値", &d.MinScore},
+		{"max_score", "最大値", &d.MaxScore},
+		{"display_order", "表示順", &d.DisplayOrder},
+	}
+	for _, f := range fields {
+		v, err := strconv.Atoi(r.FormValue(f.key))
+		if err != nil {
+			problems = append(problems, f.label+"は整数で入力してください")
+			continue
+		}
+		*f.dest = v
+	}
+	if problems != nil {
+		return nil, problems
 	}
+	return d, model.ValidateScoreDimension(d)
 }
 
-func (app *App) handleTalentEdit(w http.ResponseWriter, r *http.Request) {
-	if !app.requireAuth(w, r) {
-		return
-	}
+// handleDimensions は評価軸の一覧と追加フォームを表示し、POSTでは評価軸を追加する。
+func (app *App) handleDimensions(w http.ResponseWriter, r *http.Request) {
+	userID := currentUser(r).ID
 
-	talentID, err := strconv.Atoi(r.URL.Query().Get("id"))
+	dimensions, err := app.dimensionRepo.FindByUserID(userID)
 	if err != nil {
-		http.Error(w, "無効なIDです", http.StatusBadRequest)
+		http.Error(w, "評価軸の取得に失敗しました", http.StatusInternalServerError)
 		return
 	}
 
-	username := app.getUsername(r)
-	userID, err := app.userRepo.GetID(username)
-	if err != nil {
-		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusInternalServerError)
-		return
+	// 追加フォームの表示順の初期値は、今ある評価軸の後ろにする
+	nextDisplayOrder := 1
+	for _, d := range dimensions {
+		nextDisplayOrder = max(nextDisplayOrder, d.DisplayOrder+1)
 	}
 
-	if r.Method == http.MethodGet {
-		talent, err := app.talentRepo.FindByID(talentID, userID)
-		if err != nil {
-			http.Error(w, "タレント情報の取得に失敗しました", http.StatusNotFound)
-			return
-		}
+	data := map[string]any{
+		"Dimensions":       dimensions,
+		"NextDisplayOrder": nextDisplayOrder,
+	}
+	renderErrors := func(problems ...string) {
+		data["Errors"] = problems
+		app.render(w, r, http.StatusBadRequest, "dimensions.tmpl", data)
+	}
 
-		app.tmpl.ExecuteTemplate(w, "talent_form.tmpl", map[string]any{
-			"IsEdit": true,
-			"Talent": talent,
-		})
+	if r.Method == http.MethodGet {
+		app.render(w, r, http.StatusOK, "dimensions.tmpl", data)
 		return
 	}
 
 	if r.Method == http.MethodPost {
-		name := r.FormValue("name")
-		affiliation := r.FormValue("affiliation")
-		beauty, _ := strconv.Atoi(r.FormValue("beauty"))
-		cuteness, _ := strconv.Atoi(r.FormValue("cuteness"))
-		talent, _ := strconv.Atoi(r.FormValue("talent"))
+		// 更新フォームから送られた場合は既存の評価軸を更新する
+		var dimensionID int
+		if id := r.FormValue("id"); id != "" {
+			dimensionID, err = strconv.Atoi(id)
+			if err != nil {
+				http.Error(w, "無効なIDです", http.StatusBadRequest)
+				return
+			}
+		}
 
-		if name == "" || beauty < 1 || beauty > 10 || cuteness < 1 || cuteness > 10 || talent < 1 || talent > 10 {
-			http.Error(w, "入力値が不正です", http.StatusBadRequest)
+		d, problems := dimensionFromForm(r, userID)
+		if problems != nil {
+			renderErrors(problems...)
 			return
 		}
 
-		updateTalent := &model.Talent{
-			ID:       talentID,
-			UserID:   userID,
-			Name:     name,
-			Beauty:   beauty,
-			Cuteness: cuteness,
-			Talent:   talent,
+		if dimensionID == 0 {
+			err = app.dimensionRepo.Create(d)
+		} else {
+			d.ID = dimensionID
+			err = app.dimensionRepo.Update(d)
 		}
-
-		if affiliation != "" {
-			updateTalent.Affiliation = sql.NullString{String: affiliation, Valid: true}
+		if errors.Is(err, repository.ErrDuplicateDimensionName) || errors.Is(err, repository.ErrScoresOutOfRange) {
+			renderErrors(err.Error())
+			return
 		}
-
-		if err := app.talentRepo.Update(updateTalent); err != nil {
-			http.Error(w, "タレント情報の更新に失敗しました", http.StatusInternalServerError)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "評価軸が見つかりません", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "評価軸の保存に失敗しました", http.StatusInternalServerError)
 			return
 		}
 
-		http.Redirect(w, r, "/talents/detail?id="+strconv.Itoa(talentID), http.StatusSeeOther)
+		http.Redirect(w, r, "/mypage/dimensions", http.StatusSeeOther)
 	}
 }
 
-func (app *App) handleTalentDelete(w http.ResponseWriter, r *http.Request) {
-	if !app.requireAuth(w, r) {
-		return
-	}
-
+func (app *App) handleDimensionDelete(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "無効なメソッドです", http.StatusMethodNotAllowed)
 		return
 	}
 
-	talentID, err := strconv.Atoi(r.FormValue("id"))
+	dimensionID, err := strconv.Atoi(r.FormValue("id"))
 	if err != nil {
 		http.Error(w, "無効なIDです", http.StatusBadRequest)
 		return
 	}
 
-	username := app.getUsername(r)
-	userID, err := app.userRepo.GetID(username)
-	if err != nil {
-		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusInternalServerError)
+	if err := app.dimensionRepo.Delete(dimensionID, currentUser(r).ID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "評価軸が見つかりません", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "評価軸の削除に失敗しました", http.StatusInternalServerError)
 		return
 	}
 
-	if err := app.talentRepo.Delete(talentID, userID); err != nil {
-		http.Error(w, "タレントの削除に失敗しました", http.StatusInternalServerError)
+	http.Redirect(w, r, "/mypage/dimensions", http.StatusSeeOther)
+}
+
+func (app *App) handleAffiliations(w http.ResponseWriter, r *http.Request) {
+	affiliations, err := app.affiliationRepo.FindByUserID(currentUser(r).ID)
+	if err != nil {
+		http.Error(w, "所属一覧の取得に失敗しました", http.StatusInternalServerError)
 		return
 	}
 
-	http.Redirect(w, r, "/talents", http.StatusSeeOther)
+	app.render(w, r, http.StatusOK, "affiliations.tmpl", map[string]any{
+		"Affiliations": affiliations,
+	})
 }
 
-func (app *App) handleTalentAdjust(w http.ResponseWriter, r *http.Request) {
-	if !app.requireAuth(w, r) {
+// handleAffiliationDetail は所属するタレントと、評価軸ごとのスコアの集計を表示する。
+func (app *App) handleAffiliationDetail(w http.ResponseWriter, r *http.Request) {
+	affiliationID, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "無効なIDです", http.StatusBadRequest)
 		return
 	}
 
-	if r.Method != http.MethodPost {
-		http.Error(w, "無効なメソッドです", http.StatusMethodNotAllowed)
-		return
-	}
+	userID := currentUser(r).ID
 
-	talentID, err := strconv.Atoi(r.FormValue("talent_id"))
+	affiliation, err := app.affiliationRepo.FindByID(affiliationID, userID)
 	if err != nil {
-		http.Error(w, "無効なIDです", http.StatusBadRequest)
+		http.Error(w, "所属が見つかりません", http.StatusNotFound)
 		return
 	}
 
-	username := app.getUsername(r)
-	userID, err := app.userRepo.GetID(username)
+	stats, err := app.affiliationRepo.ScoreStats(affiliationID, userID)
 	if err != nil {
-		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusInternalServerError)
+		http.Error(w, "スコアの集計に失敗しました", http.StatusInternalServerError)
 		return
 	}
 
-	exists, err := app.talentRepo.Exists(talentID, userID)
-	if err != nil || !exists {
-		http.Error(w, "タレント情報が見つかりません", http.StatusNotFound)
+	talents, err := app.talentRepo.FindByAffiliationID(userID, affiliationID)
+	if err != nil {
+		http.Error(w, "タレント一覧の取得に失敗しました", http.StatusInternalServerError)
 		return
 	}
 
-	adjustmentType := r.FormValue("adjustment_type")
-	points, _ := strconv.Atoi(r.FormValue("points"))
-	reason := r.FormValue("reason")
+	app.render(w, r, http.StatusOK, "affiliation_detail.tmpl", map[string]any{
+		"Affiliation": affiliation,
+		"Stats":       stats,
+		"Talents":     talents,
+	})
+}
 
-	if (adjustmentType != "beauty" && adjustmentType != "cuteness" && adjustmentType != "talent") ||
-		points < -10 || points > 10 || reason == "" {
-		http.Error(w, "入力値が不正です", http.StatusBadRequest)
+// handleTags はタグの一覧と追加フォームを表示し、POSTではタグを追加する。
+// id が送られた場合は既存のタグの名前を変更する。
+func (app *App) handleTags(w http.ResponseWriter, r *http.Request) {
+	userID := currentUser(r).ID
+
+	tags, err := app.tagRepo.FindByUserID(userID)
+	if err != nil {
+		http.Error(w, "タグの取得に失敗しました", http.StatusInternalServerError)
 		return
 	}
 
-	adjustment := &model.Adjustment{
-		TalentID:       talentID,
-		AdjustmentType: adjustmentType,
-		Points:         points,
-		Reason:         reason,
+	data := map[string]any{
+		"Tags": tags,
+	}
+	renderErrors := func(problems ...string) {
+		data["Errors"] = problems
+		app.render(w, r, http.StatusBadRequest, "tags.tmpl", data)
 	}
 
-	if err := app.adjustmentRepo.Create(adjustment); err != nil {
-		http.Error(w, "調整の追加に失敗しました", http.StatusInternalServerError)
+	if r.Method == http.MethodGet {
+		app.render(w, r, http.StatusOK, "tags.tmpl", data)
 		return
 	}
 
-	http.Redirect(w, r, "/talents/detail?id="+strconv.Itoa(talentID), http.StatusSeeOther)
+	if r.Method == http.MethodPost {
+		var tagID int
+		if id := r.FormValue("id"); id != "" {
+			tagID, err = strconv.Atoi(id)
+			if err != nil {
+				http.Error(w, "無効なIDです", http.StatusBadRequest)
+				return
+			}
+		}
+
+		tag := &model.Tag{ID: tagID, UserID: userID, Name: strings.TrimSpace(r.FormValue("name"))}
+		if problems := model.ValidateTag(tag); problems != nil {
+			renderErrors(problems...)
+			return
+		}
+
+		if tagID == 0 {
+			err = app.tagRepo.Create(tag)
+		} else {
+			err = app.tagRepo.Update(tag)
+		}
+		if errors.Is(err, repository.ErrDuplicateTagName) {
+			renderErrors(err.Error())
+			return
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "タグが見つかりません", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "タグの保存に失敗しました", http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, "/tags", http.StatusSeeOther)
+	}
 }
 
-func (app *App) handleTalentDetail(w http.ResponseWriter, r *http.Request) {
-	if !app.requireAuth(w, r) {
+func (app *App) handleTagDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "無効なメソッドです", http.StatusMethodNotAllowed)
 		return
 	}
 
-	talentID, err := strconv.Atoi(r.URL.Query().Get("id"))
+	tagID, err := strconv.Atoi(r.FormValue("id"))
 	if err != nil {
 		http.Error(w, "無効なIDです", http.StatusBadRequest)
 		return
 	}
 
-	username := app.getUsername(r)
-	userID, err := app.userRepo.GetID(username)
-	if err != nil {
-		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusInternalServerError)
+	if err := app.tagRepo.Delete(tagID, currentUser(r).ID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "タグが見つかりません", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "タグの削除に失敗しました", http.StatusInternalServerError)
 		return
 	}
 
-	talent, err := app.talentRepo.FindByID(talentID, userID)
-	if err != nil {
-		http.Error(w, "タレント情報の取得に失敗しました", http.StatusNotFound)
-		return
-	}
+	http.Redirect(w, r, "/tags", http.StatusSeeOther)
+}
 
-	adjustments, err := app.adjustmentRepo.FindByTalentID(talentID)
+// savedSearchItem はタレント一覧のサイドバーに表示する保存した検索。
+type savedSearchItem struct {
+	model.SavedSearch
+	URL    string
+	Count  int  // 条件に一致するタレントの現在の数
+	Active bool // 表示中の一覧の条件と同じ
+}
+
+// savedSearchItems は保存した検索を、条件に一致するタレントの数と合わせて返す。
+// current は表示中の一覧の絞り込みの条件。
+func (app *App) savedSearchItems(userID int, dimensions []model.ScoreDimension, current url.Values) ([]savedSearchItem, error) {
+	searches, err := app.savedSearchRepo.FindByUserID(userID)
 	if err != nil {
-		http.Error(w, "履歴の取得に失敗しました", http.StatusInternalServerError)
-		return
+		return nil, err
 	}
 
-	app.tmpl.ExecuteTemplate(w, "talent_detail.tmpl", map[string]any{
-		"Talent":      talent,
-		"Adjustments": adjustments,
-	})
-}
-
-func (app *App) handleTalentToggleFavorite(w http.ResponseWriter, r *http.Request) {
-	if !app.requireAuth(w, r) {
-		return
+	items := make([]savedSearchItem, len(searches))
+	for i, s := range searches {
+		items[i] = savedSearchItem{SavedSearch: s, URL: "/talents?" + s.Query, Active: s.Query == current.Encode()}
+		params, err := url.ParseQuery(s.Query)
+		if err != nil {
+			continue
+		}
+		// 保存した後に削除した評価軸の条件は無視する
+		filter, _, err := parseTalentFilter(params, dimensions)
+		if err != nil {
+			continue
+		}
+		if items[i].Count, err = app.talentRepo.Count(userID, filter); err != nil {
+			return nil, err
+		}
 	}
+	return items, nil
+}
 
+func (app *App) handleSavedSearchCreate(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "無効なメソッドです", http.StatusMethodNotAllowed)
 		return
 	}
+	userID := currentUser(r).ID
 
-	talentID, err := strconv.Atoi(r.FormValue("id"))
+	dimensions, err := app.dimensionRepo.FindByUserID(userID)
 	if err != nil {
-		http.Error(w, "無効なIDです", http.StatusBadRequest)
+		http.Error(w, "評価軸の取得に失敗しました", http.StatusInternalServerError)
 		return
 	}
 
-	username := app.getUsername(r)
-	userID, err := app.userRepo.GetID(username)
+	// 絞り込みの条件だけを、一覧のURLと同じ形にそろえて保存する
+	params, err := url.ParseQuery(r.FormValue("query"))
+	if err != nil {
+		http.Error(w, "絞り込みの条件が正しくありません", http.StatusBadRequest)
+		return
+	}
+	_, filter, err := parseTalentFilter(params, dimensions)
 	if err != nil {
-		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if err := app.talentRepo.ToggleFavorite(talentID, userID); err != nil {
-		http.Error(w, "お気に入りの切り替えに失敗しました", http.StatusInternalServerError)
+	search := &model.SavedSearch{UserID: userID, Name: strings.TrimSpace(r.FormValue("name")), Query: filter.Encode()}
+	if problems := model.ValidateSavedSearch(search); problems != nil {
+		app.renderError(w, r, http.StatusBadRequest, strings.Join(problems, " "))
 		return
 	}
 
-	http.Redirect(w, r, "/talents", http.StatusSeeOther)
+	err = app.savedSearchRepo.Create(search)
+	if errors.Is(err, repository.ErrDuplicateSavedSearchName) {
+		app.renderError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		http.Error(w, "検索の保存に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/talents?"+search.Query, http.StatusSeeOther)
 }
 
-func (app *App) handleMyPage(w http.ResponseWriter, r *http.Request) {
-	if !app.requireAuth(w, r) {
+func (app *App) handleSavedSearchDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "無効なメソッドです", http.StatusMethodNotAllowed)
 		return
 	}
 
-	username := app.getUsername(r)
-	userID, err := app.userRepo.GetID(username)
+	searchID, err := strconv.Atoi(r.FormValue("id"))
 	if err != nil {
-		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusInternalServerError)
+		http.Error(w, "無効なIDです", http.StatusBadRequest)
 		return
 	}
 
-	user, err := app.userRepo.FindByID(userID)
-	if err != nil {
-		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusInternalServerError)
+	if err := app.savedSearchRepo.Delete(searchID, currentUser(r).ID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "保存した検索が見つかりません", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "保存した検索の削除に失敗しました", http.StatusInternalServerError)
 		return
 	}
 
-	app.tmpl.ExecuteTemplate(w, "mypage.tmpl", map[string]any{
-		"User": user,
-	})
+	http.Redirect(w, r, "/talents", http.StatusSeeOther)
 }
 
-func (app *App) handleUpdateUsername(w http.ResponseWriter, r *http.Request) {
-	if !app.requireAuth(w, r) {
-		return
-	}
-
+func (app *App) handleDeleteAccount(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet {
-		username := app.getUsername(r)
-		app.tmpl.ExecuteTemplate(w, "username_form.tmpl", map[string]any{
-			"Username": username,
-		})
+		app.render(w, r, http.StatusOK, "account_delete.tmpl", nil)
 		return
 	}
 
 	if r.Method == http.MethodPost {
-		username := app.getUsername(r)
-		userID, err := app.userRepo.GetID(username)
-		if err != nil {
-			http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusInternalServerError)
-			return
-		}
+		user := currentUser(r)
 
-		newUsername := r.FormValue("username")
-		if newUsername == "" {
-			http.Error(w, "ユーザー名を入力してください", http.StatusBadRequest)
+		if ok, _ := auth.VerifyPassword(r.FormValue("current_password"), user.Password); !ok {
+			app.render(w, r, http.StatusUnauthorized, "account_delete.tmpl", map[string]any{
+				"Errors": []string{"パスワードが正しくありません"},
+			})
 			return
 		}
 
-		if err := app.userRepo.UpdateUsername(userID, newUsername); err != nil {
-			http.Error(w, "ユーザー名の更新に失敗しました", http.StatusInternalServerError)
+		// 他の端末も含めたすべてのセッションも、ユーザーと同じトランザクションで削除する
+		if err := app.userRepo.Delete(user.ID); err != nil {
+			http.Error(w, "アカウントの削除に失敗しました", http.StatusInternalServerError)
 			return
 		}
 
-		sessionID := generateSessionID()
-		cookie, _ := r.Cookie("session_id")
-		if cookie != nil {
-			app.sessionRepo.Delete(cookie.Value)
-		}
-		app.sessionRepo.Set(sessionID, newUsername)
-
 		http.SetCookie(w, &http.Cookie{
 			Name:     "session_id",
-			Value:    sessionID,
+			Value:    "",
 			Path:     "/",
-			Expires:  time.Now().Add(24 * time.Hour),
+			MaxAge:   -1,
 			HttpOnly: true,
 		})
 
-		http.Redirect(w, r, "/mypage", http.StatusSeeOther)
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
 	}
 }
 
-func (app *App) handleUpdatePassword(w http.ResponseWriter, r *http.Request) {
-	if !app.requireAuth(w, r) {
-		return
-	}
+// auditPerPage は監査ログの1ページの件数。
+const auditPerPage = 50
 
-	if r.Method == http.MethodGet {
-		app.tmpl.ExecuteTemplate(w, "password_form.tmpl", nil)
-		return
-	}
+// auditOption は監査ログの絞り込みの選択肢。
+type auditOption struct {
+	Value string
+	Label string
+}
 
-	if r.Method == http.MethodPost {
-		username := app.getUsername(r)
-		userID, err := app.userRepo.GetID(username)
-		if err != nil {
-			http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusInternalServerError)
-			return
-		}
+var auditEntityOptions = []auditOption{
+	{model.AuditEntityUser, "ユーザー"},
+	{model.AuditEntityTalent, "タレント"},
+	{model.AuditEntityAdjustment, "調整履歴"},
+}
 
-		currentPassword := r.FormValue("current_password")
-		newPassword := r.FormValue("new_password")
-		confirmPassword := r.FormValue("confirm_password")
+var auditActionOptions = []auditOption{
+	{model.AuditActionCreate, "作成"},
+	{model.AuditActionUpdate, "更新"},
+	{model.AuditActionTrash, "ごみ箱に移動"},
+	{model.AuditActionRestore, "ごみ箱から復元"},
+	{model.AuditActionDelete, "削除"},
+}
 
-		if currentPassword == "" || newPassword == "" || confirmPassword == "" {
-			http.Error(w, "すべてのフィールドを入力してください", http.StatusBadRequest)
-			return
-		}
+// auditLabels は選択肢の値から表示名を引けるようにする。
+func auditLabels(options []auditOption) map[string]string {
+	labels := make(map[string]string, len(options))
+	for _, o := range options {
+		labels[o.Value] = o.Label
+	}
+	return labels
+}
 
-		if newPassword != confirmPassword {
-			http.Error(w, "新しいパスワードが一致しません", http.StatusBadRequest)
-			return
-		}
+// parseAuditFilter は監査ログのクエリを絞り込みの条件に変換する。
+// 空の値は指定しなかったものとして扱い、条件に使った値だけを次のページのURLに引き継ぐために返す。
+func parseAuditFilter(params url.Values, userID int) (repository.AuditFilter, url.Values, error) {
+	filter := repository.AuditFilter{UserID: userID}
+	values := url.Values{}
 
-		user, err := app.userRepo.FindByID(userID)
+	if v := params.Get("entity"); v != "" {
+		if !slices.ContainsFunc(auditEntityOptions, func(o auditOption) bool { return o.Value == v }) {
+			return filter, nil, errors.New("対象の種類の指定が正しくありません")
+		}
+		filter.EntityType = v
+		values.Set("entity", v)
+	}
+	if v := params.Get("id"); v != "" {
+		id, err := strconv.Atoi(v)
 		if err != nil {
-			http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusInternalServerError)
-			return
+			return filter, nil, errors.New("対象のIDの指定が正しくありません")
 		}
-
-		if !verifyPassword(currentPassword, user.Password) {
-			http.Error(w, "現在のパスワードが正しくありません", http.StatusUnauthorized)
-			return
+		filter.EntityID = id
+		values.Set("id", v)
+	}
+	if v := params.Get("action"); v != "" {
+		if !slices.ContainsFunc(auditActionOptions, func(o auditOption) bool { return o.Value == v }) {
+			return filter, nil, errors.New("操作の指定が正しくありません")
 		}
+		filter.Action = v
+		values.Set("action", v)
+	}
 
-		hashedPassword, err := hashPassword(newPassword)
+	// 期間は画面の日付で指定し、終わりの日も含める
+	if v := params.Get("from"); v != "" {
+		from, err := time.ParseInLocation(time.DateOnly, v, time.Local)
 		if err != nil {
-			http.Error(w, "パスワードのハッシュ化に失敗しました", http.StatusInternalServerError)
-			return
+			return filter, nil, errors.New("期間の指定が正しくありません")
+		}
+		filter.CreatedFrom = from
+		values.Set("from", v)
+	}
+	if v := params.Get("to"); v != "" {
+		to, err := time.ParseInLocation(time.DateOnly, v, time.Local)
+		if err != nil {
+			return filter, nil, errors.New("期間の指定が正しくありません")
 		}
+		filter.CreatedBefore = to.AddDate(0, 0, 1)
+		values.Set("to", v)
+	}
 
-		if err := app.userRepo.UpdatePassword(userID, hashedPassword); err != nil {
-			http.Error(w, "パスワードの更新に失敗しました", http.StatusInternalServerError)
-			return
+	if v := params.Get("before"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			return filter, nil, errors.New("ページの指定が正しくありません")
 		}
+		filter.BeforeID = id
+	}
 
-		http.Redirect(w, r, "/mypage", http.StatusSeeOther)
+	return filter, values, nil
+}
+
+func (app *App) handleAudit(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	filter, values, err := parseAuditFilter(params, currentUser(r).ID)
+	if err != nil {
+		app.renderError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// 1件多く取得して、続きのページがあるか判定する
+	filter.Limit = auditPerPage + 1
+	events, err := app.auditRepo.Find(filter)
+	if err != nil {
+		http.Error(w, "監査ログの取得に失敗しました", http.StatusInternalServerError)
+		return
 	}
+
+	var nextURL string
+	if len(events) > auditPerPage {
+		events = events[:auditPerPage]
+		next := maps.Clone(values)
+		next.Set("before", strconv.Itoa(events[len(events)-1].ID))
+		nextURL = "/audit?" + next.Encode()
+	}
+	var firstURL string
+	if filter.BeforeID != 0 {
+		firstURL = "/audit?" + values.Encode()
+	}
+
+	app.render(w, r, http.StatusOK, "audit.tmpl", map[string]any{
+		"Events":        events,
+		"EntityOptions": auditEntityOptions,
+		"ActionOptions": auditActionOptions,
+		"EntityLabels":  auditLabels(auditEntityOptions),
+		"ActionLabels":  auditLabels(auditActionOptions),
+		"Entity":        params.Get("entity"),
+		"EntityID":      params.Get("id"),
+		"Action":        params.Get("action"),
+		"From":          params.Get("from"),
+		"To":            params.Get("to"),
+		"FirstURL":      firstURL,
+		"NextURL":       nextURL,
+	})
 }
 
-func (app *App) handlePlaygroundIndex(w http.ResponseWriter, r *http.Request) {
-	if !app.requireAuth(w, r) {
+func (app *App) handleSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, err := app.sessionRepo.FindByUserID(currentUser(r).ID)
+	if err != nil {
+		http.Error(w, "セッション一覧の取得に失敗しました", http.StatusInternalServerError)
 		return
 	}
 
-	app.tmpl.ExecuteTemplate(w, "playground_index.tmpl", nil)
+	app.render(w, r, http.StatusOK, "sessions.tmpl", map[string]any{
+		"Sessions":         sessions,
+		"CurrentSessionID": currentSession(r).ID,
+	})
 }
 
-func (app *App) handlePlaygroundNogiName(w http.ResponseWriter, r *http.Request) {
-	if !app.requireAuth(w, r) {
+func (app *App) handleSessionRevoke(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "無効なメソッドです", http.StatusMethodNotAllowed)
 		return
 	}
 
+	sessionID := r.FormValue("id")
+	sessions, err := app.sessionRepo.FindByUserID(currentUser(r).ID)
+	if err != nil {
+		http.Error(w, "セッション一覧の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	// 他人のセッションを削除できないよう、自分のセッションに含まれるか確認する
+	for _, session := range sessions {
+		if session.ID == sessionID {
+			if err := app.sessionRepo.Delete(sessionID); err != nil {
+				http.Error(w, "セッションの削除に失敗しました", http.StatusInternalServerError)
+				return
+			}
+			break
+		}
+	}
+
+	http.Redirect(w, r, "/mypage/sessions", http.StatusSeeOther)
+}
+
+func (app *App) handleSessionRevokeOthers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "無効なメソッドです", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := app.sessionRepo.DeleteByUserID(currentUser(r).ID, currentSession(r).ID); err != nil {
+		http.Error(w, "セッションの削除に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/mypage/sessions", http.StatusSeeOther)
+}
+
+func (app *App) handlePlaygroundIndex(w http.ResponseWriter, r *http.Request) {
+	app.render(w, r, http.StatusOK, "playground_index.tmpl", nil)
+}
+
+func (app *App) handlePlaygroundNogiName(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet {
 		resultID := r.URL.Query().Get("id")
 		errorType := r.URL.Query().Get("error")
@@ -786,7 +2957,7 @@ func (app *App) handlePlaygroundNogiName(w http.ResponseWriter, r *http.Request)
 			NogiName:     result,
 			ErrorMessage: errorMessage,
 		}
-		app.tmpl.ExecuteTemplate(w, "playground_noginame.tmpl", data)
+		app.render(w, r, http.StatusOK, "playground_noginame.tmpl", data)
 		return
 	}
 
@@ -817,6 +2988,12 @@ func (app *App) handlePlaygroundNogiName(w http.ResponseWriter, r *http.Request)
 }
 
 func main() {
+	trashRetention := flag.Duration("trash-retention", defaultTrashRetention, "ごみ箱のタレントを自動で削除するまでの期間（例: 720h）")
+	flag.Parse()
+	if *trashRetention <= 0 {
+		log.Fatal("-trash-retention には正の期間を指定してください")
+	}
+
 	db, err := initDB()
 	if err != nil {
 		log.Fatal(err)
@@ -824,47 +3001,109 @@ func main() {
 	defer db.Close()
 
 	adjustmentRepo := repository.NewAdjustmentRepository(db)
-	talentRepo := repository.NewTalentRepository(db, adjustmentRepo)
+	dimensionRepo := repository.NewScoreDimensionRepository(db)
+	talentRepo := repository.NewTalentRepository(db, adjustmentRepo, dimensionRepo)
+	sessionRepo := repository.NewSQLiteSessionRepository(db, repository.SessionOptions{
+		IdleTimeout: sessionIdleTimeout,
+		MaxLifetime: sessionMaxLifetime,
+		Sliding:     true,
+	})
+
+	passwordPolicy, err := auth.NewPasswordPolicy(passwordMinLength, commonPasswordsFile)
+	if err != nil {
+		log.Fatalf("パスワードポリシーの読み込みに失敗しました: %v", err)
+	}
 
 	app := &App{
-		userRepo:       repository.NewUserRepository(db),
-		talentRepo:     talentRepo,
-		adjustmentRepo: adjustmentRepo,
-		sessionRepo:    repository.NewSessionRepository(),
-		resultStore:    &sync.Map{},
-		tmpl: template.Must(template.ParseFiles(
+		userRepo:         repository.NewUserRepository(db),
+		talentRepo:       talentRepo,
+		adjustmentRepo:   adjustmentRepo,
+		dimensionRepo:    dimensionRepo,
+		tagRepo:          repository.NewTagRepository(db),
+		affiliationRepo:  repository.NewAffiliationRepository(db),
+		savedSearchRepo:  repository.NewSavedSearchRepository(db),
+		auditRepo:        repository.NewAuditRepository(db),
+		sessionRepo:      sessionRepo,
+		loginAttemptRepo: repository.NewLoginAttemptRepository(db, loginThrottlePolicy),
+		resultStore:      &sync.Map{},
+		pendingLogins:    &sync.Map{},
+		passwordPolicy:   passwordPolicy,
+		trashRetention:   *trashRetention,
+		tmpl: template.Must(template.New("").Funcs(template.FuncMap{
+			"csrfField": func() template.HTML { return "" },
+		}).ParseFiles(
 			"templates/index.tmpl",
 			"templates/login.tmpl",
+			"templates/login_totp.tmpl",
 			"templates/register.tmpl",
 			"templates/talents.tmpl",
 			"templates/talent_detail.tmpl",
 			"templates/talent_form.tmpl",
+			"templates/talent_import.tmpl",
+			"templates/adjustment_form.tmpl",
+			"templates/trash.tmpl",
 			"templates/mypage.tmpl",
+			"templates/dimensions.tmpl",
+			"templates/tags.tmpl",
+			"templates/affiliations.tmpl",
+			"templates/affiliation_detail.tmpl",
 			"templates/username_form.tmpl",
 			"templates/password_form.tmpl",
+			"templates/sessions.tmpl",
+			"templates/audit.tmpl",
+			"templates/account_delete.tmpl",
+			"templates/totp.tmpl",
+			"templates/error.tmpl",
 			"templates/playground_index.tmpl",
 			"templates/playground_noginame.tmpl",
 		)),
 	}
 
+	app.startSessionSweeper(sessionSweepInterval)
+	app.startTrashPurger(trashPurgeInterval, app.trashRetention)
+
 	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
 
-	http.HandleFunc("/", app.handleIndex)
-	http.HandleFunc("/register", app.handleRegister)
-	http.HandleFunc("/login", app.handleLogin)
-	http.HandleFunc("/logout", app.handleLogout)
-	http.HandleFunc("/talents", app.handleTalents)
-	http.HandleFunc("/talents/new", app.handleTalentNew)
-	http.HandleFunc("/talents/edit", app.handleTalentEdit)
-	http.HandleFunc("/talents/delete", app.handleTalentDelete)
-	http.HandleFunc("/talents/adjust", app.handleTalentAdjust)
-	http.HandleFunc("/talents/detail", app.handleTalentDetail)
-	http.HandleFunc("/talents/toggle-favorite", app.handleTalentToggleFavorite)
-	http.HandleFunc("/mypage", app.handleMyPage)
-	http.HandleFunc("/mypage/username", app.handleUpdateUsername)
-	http.HandleFunc("/mypage/password", app.handleUpdatePassword)
-	http.HandleFunc("/playground", app.handlePlaygroundIndex)
-	http.HandleFunc("/playground/noginame", app.handlePlaygroundNogiName)
+	http.HandleFunc("/", app.withAuth(app.withCSRF(app.handleIndex)))
+	http.HandleFunc("/register", app.withCSRF(app.handleRegister))
+	http.HandleFunc("/login", app.withCSRF(app.handleLogin))
+	http.HandleFunc("/login/totp", app.withCSRF(app.handleLoginTOTP))
+	http.HandleFunc("/logout", app.withAuth(app.withCSRF(app.handleLogout)))
+	http.HandleFunc("/talents", app.withAuth(app.withCSRF(app.handleTalents)))
+	http.HandleFunc("/talents/new", app.withAuth(app.withCSRF(app.handleTalentNew)))
+	http.HandleFunc("/talents/import", app.withAuth(app.withBodyLimit(maxImportRequestSize, app.withCSRF(app.handleTalentImport))))
+	http.HandleFunc("/talents/edit", app.withAuth(app.withCSRF(app.handleTalentEdit)))
+	http.HandleFunc("/talents/delete", app.withAuth(app.withCSRF(app.handleTalentDelete)))
+	http.HandleFunc("/talents/trash", app.withAuth(app.withCSRF(app.handleTrash)))
+	http.HandleFunc("/talents/trash/restore", app.withAuth(app.withCSRF(app.handleTrashRestore)))
+	http.HandleFunc("/talents/trash/delete", app.withAuth(app.withCSRF(app.handleTrashDelete)))
+	http.HandleFunc("/talents/adjust", app.withAuth(app.withCSRF(app.handleTalentAdjust)))
+	http.HandleFunc("/talents/adjust/edit", app.withAuth(app.withCSRF(app.handleAdjustmentEdit)))
+	http.HandleFunc("/talents/adjust/delete", app.withAuth(app.withCSRF(app.handleAdjustmentDelete)))
+	http.HandleFunc("/talents/detail", app.withAuth(app.withCSRF(app.handleTalentDetail)))
+	http.HandleFunc("/talents/revert", app.withAuth(app.withCSRF(app.handleTalentRevert)))
+	http.HandleFunc("/talents/toggle-favorite", app.withAuth(app.withCSRF(app.handleTalentToggleFavorite)))
+	http.HandleFunc("/talents/saved", app.withAuth(app.withCSRF(app.handleSavedSearchCreate)))
+	http.HandleFunc("/talents/saved/delete", app.withAuth(app.withCSRF(app.handleSavedSearchDelete)))
+	http.HandleFunc("/tags", app.withAuth(app.withCSRF(app.handleTags)))
+	http.HandleFunc("/affiliations", app.withAuth(app.withCSRF(app.handleAffiliations)))
+	http.HandleFunc("/affiliations/detail", app.withAuth(app.withCSRF(app.handleAffiliationDetail)))
+	http.HandleFunc("/tags/delete", app.withAuth(app.withCSRF(app.handleTagDelete)))
+	http.HandleFunc("/mypage", app.withAuth(app.withCSRF(app.handleMyPage)))
+	http.HandleFunc("/mypage/username", app.withAuth(app.withCSRF(app.handleUpdateUsername)))
+	http.HandleFunc("/mypage/password", app.withAuth(app.withCSRF(app.handleUpdatePassword)))
+	http.HandleFunc("/mypage/totp", app.withAuth(app.withCSRF(app.handleTOTP)))
+	http.HandleFunc("/mypage/totp/disable", app.withAuth(app.withCSRF(app.handleTOTPDisable)))
+	http.HandleFunc("/mypage/dimensions", app.withAuth(app.withCSRF(app.handleDimensions)))
+	http.HandleFunc("/mypage/dimensions/delete", app.withAuth(app.withCSRF(app.handleDimensionDelete)))
+	http.HandleFunc("/mypage/export", app.withAuth(app.handleExport))
+	http.HandleFunc("/mypage/delete", app.withAuth(app.withCSRF(app.handleDeleteAccount)))
+	http.HandleFunc("/audit", app.withAuth(app.withCSRF(app.handleAudit)))
+	http.HandleFunc("/mypage/sessions", app.withAuth(app.withCSRF(app.handleSessions)))
+	http.HandleFunc("/mypage/sessions/revoke", app.withAuth(app.withCSRF(app.handleSessionRevoke)))
+	http.HandleFunc("/mypage/sessions/revoke-others", app.withAuth(app.withCSRF(app.handleSessionRevokeOthers)))
+	http.HandleFunc("/playground", app.withAuth(app.withCSRF(app.handlePlaygroundIndex)))
+	http.HandleFunc("/playground/noginame", app.withAuth(app.withCSRF(app.handlePlaygroundNogiName)))
 
 	log.Println("Server started at http://localhost:8080")
 	log.Fatal(http.ListenAndServe(":8080", nil))
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"database/sql"
+	"maps"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+// setupMigrationTestDB は schema で移行前のテーブルを作ったメモリ上のDBを返す。
+func setupMigrationTestDB(t *testing.T, schema string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// :memory: は接続ごとに別のDBになるため、1つの接続だけを使う
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.Exec(schema); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// queryStrings は2列の文字列を返すクエリの結果を、1列目をキーにした map にする。
+func queryStrings(t *testing.T, db *sql.DB, query string) map[string]string {
+	t.Helper()
+	rows, err := db.Query(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	got := map[string]string{}
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			t.Fatal(err)
+		}
+		got[key] = value
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestMigrateDuplicateUsernames(t *testing.T) {
+	// 移行前の users は大文字小文字を区別して一意にしていた
+	db := setupMigrationTestDB(t, `
+		CREATE TABLE users (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			username TEXT UNIQUE NOT NULL,
+			password TEXT NOT NULL
+		);
+		INSERT INTO users (id, username, password) VALUES
+			(1, 'Alice', 'x'),
+			(2, 'alice', 'x'),
+			(3, 'bob', 'x'),
+			(4, 'alice_2', 'x'),
+			(5, 'ALICE', 'x'),
+			(6, 'abcdefghijklmnopqrstuvwxyz012345', 'x'),
+			(7, 'ABCDEFGHIJKLMNOPQRSTUVWXYZ012345', 'x');
+	`)
+
+	want := map[string]string{
+		"1": "Alice",
+		"2": "alice_2_2", // alice_2 は既に使われているため連番を足す
+		"3": "bob",
+		"4": "alice_2",
+		"5": "ALICE_5",
+		"6": "abcdefghijklmnopqrstuvwxyz012345",
+		"7": "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123_7", // 最大文字数に収まるよう切り詰める
+	}
+	// 2回目は重複がないため何も変えない
+	for run := 1; run <= 2; run++ {
+		if err := migrateDuplicateUsernames(db); err != nil {
+			t.Fatalf("migrateDuplicateUsernames() run %d error = %v", run, err)
+		}
+		got := queryStrings(t, db, "SELECT id, username FROM users")
+		if !maps.Equal(got, want) {
+			t.Errorf("usernames after run %d = %v, want %v", run, got, want)
+		}
+	}
+}
+
+func TestMigrateScoreColumns(t *testing.T) {
+	// 移行前は talents に固定のスコア列があり、adjustments は列名で評価軸を指していた
+	db := setupMigrationTestDB(t, `
+		CREATE TABLE users (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			username TEXT UNIQUE NOT NULL
+		);
+		CREATE TABLE talents (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			beauty INTEGER NOT NULL,
+			cuteness INTEGER NOT NULL,
+			talent INTEGER NOT NULL
+		);
+		CREATE TABLE adjustments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			talent_id INTEGER NOT NULL,
+			adjustment_type TEXT NOT NULL,
+			points INTEGER NOT NULL,
+			reason TEXT NOT NULL
+		);
+		CREATE INDEX idx_adjustments_talent_id_type ON adjustments(talent_id, adjustment_type);
+		CREATE TABLE score_dimensions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			min_score INTEGER NOT NULL,
+			max_score INTEGER NOT NULL,
+			display_order INTEGER NOT NULL DEFAULT 0,
+			UNIQUE (user_id, name)
+		);
+		CREATE TABLE talent_scores (
+			talent_id INTEGER NOT NULL,
+			dimension_id INTEGER NOT NULL,
+			score INTEGER NOT NULL,
+			PRIMARY KEY (talent_id, dimension_id)
+		);
+		INSERT INTO users (id, username) VALUES (1, 'alice'), (2, 'bob');
+		INSERT INTO talents (id, user_id, name, beauty, cuteness, talent) VALUES
+			(1, 1, '山田花子', 8, 5, 3),
+			(2, 2, '佐藤美咲', 1, 10, 7);
+		INSERT INTO adjustments (talent_id, adjustment_type, points, reason) VALUES
+			(1, 'beauty', 2, '写真集'),
+			(2, 'talent', -1, '歌唱力');
+	`)
+
+	wantScores := map[string]string{
+		"1:美しさ": "8", "1:可愛さ": "5", "1:才能": "3",
+		"2:美しさ": "1", "2:可愛さ": "10", "2:才能": "7",
+	}
+	// 調整履歴は同じユーザーの評価軸を指す
+	wantAdjustments := map[string]string{
+		"写真集": "1:美しさ",
+		"歌唱力": "2:才能",
+	}
+	// 2回目は移行済みのため何も変えない
+	for run := 1; run <= 2; run++ {
+		if err := migrateScoreColumns(db); err != nil {
+			t.Fatalf("migrateScoreColumns() run %d error = %v", run, err)
+		}
+
+		var dimensions int
+		if err := db.QueryRow("SELECT COUNT(*) FROM score_dimensions").Scan(&dimensions); err != nil {
+			t.Fatal(err)
+		}
+		if dimensions != 6 {
+			t.Errorf("score_dimensions after run %d = %d rows, want 6", run, dimensions)
+		}
+		scores := queryStrings(t, db, `
+			SELECT s.talent_id || ':' || d.name, s.score FROM talent_scores s
+			JOIN score_dimensions d ON d.id = s.dimension_id`)
+		if !maps.Equal(scores, wantScores) {
+			t.Errorf("talent_scores after run %d = %v, want %v", run, scores, wantScores)
+		}
+		adjustments := queryStrings(t, db, `
+			SELECT a.reason, d.user_id || ':' || d.name FROM adjustments a
+			JOIN score_dimensions d ON d.id = a.dimension_id`)
+		if !maps.Equal(adjustments, wantAdjustments) {
+			t.Errorf("adjustments after run %d = %v, want %v", run, adjustments, wantAdjustments)
+		}
+
+		var legacyColumns int
+		err := db.QueryRow(`
+			SELECT (SELECT COUNT(*) FROM pragma_table_info('talents') WHERE name IN ('beauty', 'cuteness', 'talent'))
+				+ (SELECT COUNT(*) FROM pragma_table_info('adjustments') WHERE name = 'adjustment_type')`).Scan(&legacyColumns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if legacyColumns != 0 {
+			t.Errorf("legacy score columns after run %d = %d, want 0", run, legacyColumns)
+		}
+	}
+}
+
+func TestMigrateAffiliations(t *testing.T) {
+	// 移行前は talents に所属名をそのまま入れていた
+	db := setupMigrationTestDB(t, `
+		CREATE TABLE talents (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			affiliation TEXT
+		);
+		CREATE TABLE affiliations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			name_key TEXT NOT NULL,
+			UNIQUE (user_id, name_key)
+		);
+		INSERT INTO talents (id, user_id, name, affiliation) VALUES
+			(1, 1, '山田花子', 'ABCプロ'),
+			(2, 1, '佐藤美咲', ' ＡＢＣ プロ'),
+			(3, 1, '鈴木愛', '乃木坂46'),
+			(4, 1, '田中優子', NULL),
+			(5, 1, '高橋舞', '   '),
+			(6, 2, '伊藤彩', 'abcプロ');
+	`)
+
+	// 表記ゆれは最初に登録された表記にまとめ、ユーザーごとに別の所属にする。空白だけの所属名は所属なしにする
+	wantTalents := map[string]string{
+		"山田花子": "1:ABCプロ",
+		"佐藤美咲": "1:ABCプロ",
+		"鈴木愛":  "1:乃木坂46",
+		"田中優子": "",
+		"高橋舞":  "",
+		"伊藤彩":  "2:abcプロ",
+	}
+	// 2回目は移行済みのため何も変えない
+	for run := 1; run <= 2; run++ {
+		if err := migrateAffiliations(db); err != nil {
+			t.Fatalf("migrateAffiliations() run %d error = %v", run, err)
+		}
+
+		var affiliations int
+		if err := db.QueryRow("SELECT COUNT(*) FROM affiliations").Scan(&affiliations); err != nil {
+			t.Fatal(err)
+		}
+		if affiliations != 3 {
+			t.Errorf("affiliations after run %d = %d rows, want 3", run, affiliations)
+		}
+		talents := queryStrings(t, db, `
+			SELECT t.name, COALESCE(a.user_id || ':' || a.name, '') FROM talents t
+			LEFT JOIN affiliations a ON a.id = t.affiliation_id`)
+		if !maps.Equal(talents, wantTalents) {
+			t.Errorf("talents after run %d = %v, want %v", run, talents, wantTalents)
+		}
+
+		var legacyColumn int
+		if err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('talents') WHERE name = 'affiliation'").Scan(&legacyColumn); err != nil {
+			t.Fatal(err)
+		}
+		if legacyColumn != 0 {
+			t.Errorf("talents.affiliation after run %d still exists", run)
+		}
+	}
+}
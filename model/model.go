@@ -1,34 +1,265 @@
 package model
 
-import "database/sql"
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
 
 type User struct {
-	ID        int
-	Username  string
-	Password  string
-	CreatedAt string
+	ID         int
+	Username   string
+	Password   string
+	TOTPSecret string // 空文字列の場合は二要素認証が無効
+	CreatedAt  string
+}
+
+type Session struct {
+	ID         string
+	UserID     int
+	UserAgent  string
+	IPAddress  string
+	CSRFToken  string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
 }
 
 type Talent struct {
 	ID            int
 	UserID        int
 	Name          string
-	Affiliation   sql.NullString
-	Beauty        int
-	Cuteness      int
-	Talent        int
+	AffiliationID sql.NullInt64  // 保存時は Affiliation の名前から決まる
+	Affiliation   sql.NullString // 所属名
 	IsFavorite    bool
-	TotalBeauty   int
-	TotalCuteness int
-	TotalTalent   int
+	Scores        []Score // ユーザーの評価軸の表示順
+	Tags          []Tag   // 名前順
 	CreatedAt     string
+	DeletedAt     sql.NullTime // ごみ箱に移した日時
+}
+
+// Affiliation はタレントの所属。表記ゆれのある所属名は同じ所属にまとめる。
+type Affiliation struct {
+	ID          int
+	UserID      int
+	Name        string
+	TalentCount int
+}
+
+// Tag はタレントに複数付けられるラベル。
+type Tag struct {
+	ID          int
+	UserID      int
+	Name        string
+	TalentCount int // タグが付いているタレントの数（一覧で取得した場合のみ）
+}
+
+// SavedSearch はユーザーが名前を付けて保存したタレント一覧の絞り込みの条件。
+type SavedSearch struct {
+	ID     int
+	UserID int
+	Name   string
+	Query  string // タレント一覧のURLのクエリ文字列
+}
+
+// ScoreDimension はユーザーごとに定義するタレントの評価軸。
+type ScoreDimension struct {
+	ID           int
+	UserID       int
+	Name         string
+	MinScore     int
+	MaxScore     int
+	DisplayOrder int
+}
+
+// Score はタレントの評価軸ごとのスコア。
+// 評価軸を後から追加した場合など、まだ評価していない軸は Rated が false になる。
+type Score struct {
+	DimensionID int
+	Name        string
+	Value       int // 初期値
+	Total       int // 初期値に調整を合計した値
+	Rated       bool
+}
+
+// ScoreStats は評価軸ごとのスコアの集計。Count が0の場合、他の値は0になる。
+type ScoreStats struct {
+	DimensionID int
+	Name        string
+	Count       int // 評価済みのタレントの数
+	Average     float64
+	Max         int
+	Min         int
 }
 
 type Adjustment struct {
-	ID             int
-	TalentID       int
-	AdjustmentType string
-	Points         int
-	Reason         string
-	CreatedAt      string
+	ID            int
+	TalentID      int
+	DimensionID   int
+	DimensionName string
+	Points        int
+	Reason        string
+	CreatedAt     string
+}
+
+// TalentRevision はタレントを登録・編集した時点の名前・所属・スコア・タグ。
+type TalentRevision struct {
+	ID           int
+	TalentID     int
+	Number       int // タレントごとの版の番号。登録した時点が1
+	Name         string
+	Affiliation  string
+	Scores       []Score          // 評価済みのスコア。Name は記録した時点の評価軸の名前
+	Tags         []Tag            // 名前順
+	RevertedFrom int              // 過去の版に戻した場合の、戻した版の番号。0 の場合は通常の編集
+	Changes      []RevisionChange // 1つ前の版からの変更
+	CreatedAt    time.Time
+}
+
+// RevisionChange は版の間の1項目の変更。
+type RevisionChange struct {
+	Field  string
+	Before string
+	After  string
+}
+
+// 監査ログの対象の種類。
+const (
+	AuditEntityUser       = "user"
+	AuditEntityTalent     = "talent"
+	AuditEntityAdjustment = "adjustment"
+)
+
+// 監査ログの操作の種類。
+const (
+	AuditActionCreate  = "create"
+	AuditActionUpdate  = "update"
+	AuditActionTrash   = "trash"   // ごみ箱に移した
+	AuditActionRestore = "restore" // ごみ箱から戻した
+	AuditActionDelete  = "delete"
+)
+
+// AuditEvent はデータを作成・変更・削除した記録。
+type AuditEvent struct {
+	ID         int
+	UserID     int           // 変更されたデータの持ち主
+	ActorID    sql.NullInt64 // 変更したユーザー。保存期間を過ぎて自動で削除した場合は無効
+	EntityType string
+	EntityID   int
+	Action     string
+	Changes    []AuditChange // 項目名順
+	CreatedAt  time.Time
+}
+
+// AuditChange は監査ログの1項目の変更前と変更後の値。作成した場合の Before と削除した場合の After は空になる。
+type AuditChange struct {
+	Field  string
+	Before string
+	After  string
+}
+
+// DefaultScoreDimensions は新規ユーザーに最初から用意する評価軸。
+var DefaultScoreDimensions = []ScoreDimension{
+	{Name: "美しさ", MinScore: 1, MaxScore: 10, DisplayOrder: 1},
+	{Name: "可愛さ", MinScore: 1, MaxScore: 10, DisplayOrder: 2},
+	{Name: "才能", MinScore: 1, MaxScore: 10, DisplayOrder: 3},
+}
+
+// 評価軸の名前の最大文字数。
+const MaxDimensionNameLength = 20
+
+// タグの名前の最大文字数。
+const MaxTagNameLength = 20
+
+// 保存した検索の名前の最大文字数。
+const MaxSavedSearchNameLength = 30
+
+// 1回の加点・減点の点数の範囲。
+const (
+	MinAdjustmentPoints = -10
+	MaxAdjustmentPoints = 10
+)
+
+// ValidateScoreDimension は評価軸の入力値を検証し、問題があれば内容を返す。
+func ValidateScoreDimension(d *ScoreDimension) []string {
+	var problems []string
+	name := strings.TrimSpace(d.Name)
+	if name == "" {
+		problems = append(problems, "評価軸の名前を入力してください")
+	} else if utf8.RuneCountInString(name) > MaxDimensionNameLength {
+		problems = append(problems, fmt.Sprintf("評価軸の名前は%d文字以内で入力してください", MaxDimensionNameLength))
+	}
+	if d.MinScore >= d.MaxScore {
+		problems = append(problems, "最大値は最小値より大きくしてください")
+	}
+	return problems
+}
+
+// ValidateTag はタグの入力値を検証し、問題があれば内容を返す。
+func ValidateTag(t *Tag) []string {
+	var problems []string
+	name := strings.TrimSpace(t.Name)
+	if name == "" {
+		problems = append(problems, "タグの名前を入力してください")
+	} else if utf8.RuneCountInString(name) > MaxTagNameLength {
+		problems = append(problems, fmt.Sprintf("タグの名前は%d文字以内で入力してください", MaxTagNameLength))
+	}
+	return problems
+}
+
+// ValidateSavedSearch は保存する検索の入力値を検証し、問題があれば内容を返す。
+func ValidateSavedSearch(s *SavedSearch) []string {
+	var problems []string
+	name := strings.TrimSpace(s.Name)
+	if name == "" {
+		problems = append(problems, "保存する検索の名前を入力してください")
+	} else if utf8.RuneCountInString(name) > MaxSavedSearchNameLength {
+		problems = append(problems, fmt.Sprintf("保存する検索の名前は%d文字以内で入力してください", MaxSavedSearchNameLength))
+	}
+	if s.Query == "" {
+		problems = append(problems, "絞り込みの条件を指定してください")
+	}
+	return problems
+}
+
+// ValidateAdjustment は加点・減点の点数と理由を検証し、問題があれば内容を返す。
+func ValidateAdjustment(a *Adjustment) []string {
+	var problems []string
+	if a.Points < MinAdjustmentPoints || a.Points > MaxAdjustmentPoints {
+		problems = append(problems, fmt.Sprintf("点数は%d〜%dで入力してください", MinAdjustmentPoints, MaxAdjustmentPoints))
+	}
+	if strings.TrimSpace(a.Reason) == "" {
+		problems = append(problems, "理由を入力してください")
+	}
+	return problems
+}
+
+// ValidateTalent はタレントの入力値を評価軸の定義に照らして検証し、問題があれば内容を返す。
+// すべての評価軸にスコアが必要で、定義にない評価軸のスコアは受け付けない。
+func ValidateTalent(t *Talent, dimensions []ScoreDimension) []string {
+	var problems []string
+	if strings.TrimSpace(t.Name) == "" {
+		problems = append(problems, "名前を入力してください")
+	}
+
+	scores := make(map[int]Score, len(t.Scores))
+	for _, s := range t.Scores {
+		scores[s.DimensionID] = s
+	}
+	for _, d := range dimensions {
+		s, ok := scores[d.ID]
+		if !ok || !s.Rated {
+			problems = append(problems, d.Name+"を入力してください")
+			continue
+		}
+		if s.Value < d.MinScore || s.Value > d.MaxScore {
+			problems = append(problems, fmt.Sprintf("%sは%d〜%dで入力してください", d.Name, d.MinScore, d.MaxScore))
+		}
+		delete(scores, d.ID)
+	}
+	if len(scores) > 0 {
+		problems = append(problems, "評価軸の指定が正しくありません")
+	}
+	return problems
 }
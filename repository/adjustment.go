@@ -8,9 +8,12 @@ import (
 
 type AdjustmentRepository interface {
 	Create(adj *model.Adjustment) error
+	Update(adj *model.Adjustment, userID int) error
+	Delete(id, userID int) error
+	FindByID(id, userID int) (*model.Adjustment, error)
 	FindByTalentID(talentID int) ([]model.Adjustment, error)
-	CalculateTotalScore(talentID, baseScore int, adjustmentType string) (int, error)
-	CalculateTotalScores(talentIDs []int) (map[int]map[string]int, error)
+	FindByUserID(userID int) ([]model.Adjustment, error)
+	CalculateTotalScores(talentIDs []int) (map[int]map[int]int, error)
 }
 
 type adjustmentRepository struct {
@@ -21,20 +24,187 @@ func NewAdjustmentRepository(db *sql.DB) AdjustmentRepository {
 	return &adjustmentRepository{db: db}
 }
 
+// Create は調整を追加し、adj.ID に追加したIDを設定する。監査ログにはタレントの持ち主が追加したものとして記録する。
 func (r *adjustmentRepository) Create(adj *model.Adjustment) error {
-	_, err := r.db.Exec(`
-		INSERT INTO adjustments (talent_id, adjustment_type, points, reason)
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var userID int
+	if err := tx.QueryRow("SELECT user_id FROM talents WHERE id = ?", adj.TalentID).Scan(&userID); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO adjustments (talent_id, dimension_id, points, reason)
 		VALUES (?, ?, ?, ?)`,
-		adj.TalentID, adj.AdjustmentType, adj.Points, adj.Reason)
-	return err
+		adj.TalentID, adj.DimensionID, adj.Points, adj.Reason)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	adj.ID = int(id)
+
+	created, err := findOwnedAdjustment(tx, adj.ID, userID)
+	if err != nil {
+		return err
+	}
+	err = recordAudit(tx, auditEntry{
+		userID: userID, actorID: actor(userID),
+		entityType: model.AuditEntityAdjustment, entityID: adj.ID, action: model.AuditActionCreate,
+		after: adjustmentSnapshot(created),
+	})
+	if err != nil {
+		return err
+	}
+	if err := indexPendingTalents(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ownedAdjustment は調整履歴を、ユーザーのごみ箱にないタレントのものに限って絞り込む条件。
+// 調整履歴はユーザーIDを持たないため、タレントを経由して持ち主を確かめる。
+const ownedAdjustment = "talent_id IN (SELECT id FROM talents WHERE user_id = ? AND deleted_at IS NULL)"
+
+// Update は調整履歴の点数と理由を書き換え、変更前後の内容を監査ログに記録する。
+// ユーザーのタレントの調整履歴でない場合は sql.ErrNoRows を返す。
+func (r *adjustmentRepository) Update(adj *model.Adjustment, userID int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	old, err := findOwnedAdjustment(tx, adj.ID, userID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE adjustments SET points = ?, reason = ? WHERE id = ?", adj.Points, adj.Reason, adj.ID)
+	if err != nil {
+		return err
+	}
+	updated := *old
+	updated.Points, updated.Reason = adj.Points, adj.Reason
+	err = recordAudit(tx, auditEntry{
+		userID: userID, actorID: actor(userID),
+		entityType: model.AuditEntityAdjustment, entityID: adj.ID, action: model.AuditActionUpdate,
+		before: adjustmentSnapshot(old), after: adjustmentSnapshot(&updated),
+	})
+	if err != nil {
+		return err
+	}
+	if err := indexPendingTalents(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Delete は調整履歴を削除し、削除した内容を監査ログに記録する。
+// ユーザーのタレントの調整履歴でない場合は sql.ErrNoRows を返す。
+func (r *adjustmentRepository) Delete(id, userID int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	old, err := findOwnedAdjustment(tx, id, userID)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM adjustments WHERE id = ?", id); err != nil {
+		return err
+	}
+	err = recordAudit(tx, auditEntry{
+		userID: userID, actorID: actor(userID),
+		entityType: model.AuditEntityAdjustment, entityID: id, action: model.AuditActionDelete,
+		before: adjustmentSnapshot(old),
+	})
+	if err != nil {
+		return err
+	}
+	if err := indexPendingTalents(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// findOwnedAdjustment はユーザーのタレントの調整履歴を返す。見つからない場合は sql.ErrNoRows を返す。
+func findOwnedAdjustment(db queryer, id, userID int) (*model.Adjustment, error) {
+	var a model.Adjustment
+	err := db.QueryRow(`
+		SELECT a.id, a.talent_id, a.dimension_id, d.name, a.points, a.reason, a.created_at
+		FROM adjustments a
+		JOIN score_dimensions d ON d.id = a.dimension_id
+		WHERE a.id = ? AND a.`+ownedAdjustment, id, userID).Scan(
+		&a.ID, &a.TalentID, &a.DimensionID, &a.DimensionName, &a.Points, &a.Reason, &a.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// recordAdjustmentDeletes は condition に一致する調整履歴ごとに、削除前の内容を actorID が削除したものとして監査ログに記録する。
+// タレントや評価軸と合わせて調整履歴をまとめて削除する前に呼ぶ。condition では adjustments を a として参照する。
+func recordAdjustmentDeletes(tx *sql.Tx, actorID sql.NullInt64, condition string, args ...any) error {
+	rows, err := tx.Query(`
+		SELECT a.id, a.talent_id, a.dimension_id, d.name, a.points, a.reason, a.created_at, t.user_id
+		FROM adjustments a
+		JOIN score_dimensions d ON d.id = a.dimension_id
+		JOIN talents t ON t.id = a.talent_id
+		WHERE `+condition+`
+		ORDER BY a.id`, args...)
+	if err != nil {
+		return err
+	}
+	var entries []auditEntry
+	for rows.Next() {
+		var a model.Adjustment
+		var userID int
+		if err := rows.Scan(&a.ID, &a.TalentID, &a.DimensionID, &a.DimensionName, &a.Points, &a.Reason, &a.CreatedAt, &userID); err != nil {
+			rows.Close()
+			return err
+		}
+		entries = append(entries, auditEntry{
+			userID: userID, actorID: actorID,
+			entityType: model.AuditEntityAdjustment, entityID: a.ID, action: model.AuditActionDelete,
+			before: adjustmentSnapshot(&a),
+		})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if err := recordAudit(tx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FindByID はユーザーのタレントの調整履歴を返す。
+func (r *adjustmentRepository) FindByID(id, userID int) (*model.Adjustment, error) {
+	return findOwnedAdjustment(r.db, id, userID)
 }
 
 func (r *adjustmentRepository) FindByTalentID(talentID int) ([]model.Adjustment, error) {
 	rows, err := r.db.Query(`
-		SELECT id, talent_id, adjustment_type, points, reason, created_at
-		FROM adjustments
-		WHERE talent_id = ?
-		ORDER BY created_at DESC`, talentID)
+		SELECT a.id, a.talent_id, a.dimension_id, d.name, a.points, a.reason, a.created_at
+		FROM adjustments a
+		JOIN score_dimensions d ON d.id = a.dimension_id
+		WHERE a.talent_id = ?
+		ORDER BY a.created_at DESC`, talentID)
 	if err != nil {
 		return nil, err
 	}
@@ -43,7 +213,7 @@ func (r *adjustmentRepository) FindByTalentID(talentID int) ([]model.Adjustment,
 	var adjustments []model.Adjustment
 	for rows.Next() {
 		var a model.Adjustment
-		if err := rows.Scan(&a.ID, &a.TalentID, &a.AdjustmentType, &a.Points, &a.Reason, &a.CreatedAt); err == nil {
+		if err := rows.Scan(&a.ID, &a.TalentID, &a.DimensionID, &a.DimensionName, &a.Points, &a.Reason, &a.CreatedAt); err == nil {
 			adjustments = append(adjustments, a)
 		}
 	}
@@ -51,57 +221,58 @@ func (r *adjustmentRepository) FindByTalentID(talentID int) ([]model.Adjustment,
 	return adjustments, nil
 }
 
-func (r *adjustmentRepository) CalculateTotalScore(talentID, baseScore int, adjustmentType string) (int, error) {
-	var total int
-	err := r.db.QueryRow(`
-		SELECT COALESCE(SUM(points), 0)
-		FROM adjustments
-		WHERE talent_id = ? AND adjustment_type = ?`,
-		talentID, adjustmentType).Scan(&total)
-	return baseScore + total, err
-}
-
-func (r *adjustmentRepository) CalculateTotalScores(talentIDs []int) (map[int]map[string]int, error) {
-	if len(talentIDs) == 0 {
-		return make(map[int]map[string]int), nil
+// FindByUserID はユーザーのごみ箱にないすべてのタレントの調整履歴を古い順に返す。
+func (r *adjustmentRepository) FindByUserID(userID int) ([]model.Adjustment, error) {
+	rows, err := r.db.Query(`
+		SELECT a.id, a.talent_id, a.dimension_id, d.name, a.points, a.reason, a.created_at
+		FROM adjustments a
+		JOIN talents t ON t.id = a.talent_id
+		JOIN score_dimensions d ON d.id = a.dimension_id
+		WHERE t.user_id = ? AND t.deleted_at IS NULL
+		ORDER BY a.created_at, a.id`, userID)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	result := make(map[int]map[string]int)
-	for _, id := range talentIDs {
-		result[id] = map[string]int{
-			"beauty":   0,
-			"cuteness": 0,
-			"talent":   0,
+	var adjustments []model.Adjustment
+	for rows.Next() {
+		var a model.Adjustment
+		if err := rows.Scan(&a.ID, &a.TalentID, &a.DimensionID, &a.DimensionName, &a.Points, &a.Reason, &a.CreatedAt); err != nil {
+			return nil, err
 		}
+		adjustments = append(adjustments, a)
 	}
 
-	query := `
-		SELECT talent_id, adjustment_type, COALESCE(SUM(points), 0)
-		FROM adjustments
-		WHERE talent_id IN (`
+	return adjustments, rows.Err()
+}
 
-	args := make([]any, len(talentIDs))
-	for i, id := range talentIDs {
-		if i > 0 {
-			query += ","
-		}
-		query += "?"
-		args[i] = id
+// CalculateTotalScores はタレントごと・評価軸ごとの調整の合計を返す。
+// 結果のキーはタレントIDと評価軸IDで、調整のない組み合わせは含まない。
+func (r *adjustmentRepository) CalculateTotalScores(talentIDs []int) (map[int]map[int]int, error) {
+	result := make(map[int]map[int]int)
+	if len(talentIDs) == 0 {
+		return result, nil
 	}
-	query += `) GROUP BY talent_id, adjustment_type`
 
-	rows, err := r.db.Query(query, args...)
+	placeholders, args := idPlaceholders(talentIDs)
+	rows, err := r.db.Query(`
+		SELECT talent_id, dimension_id, COALESCE(SUM(points), 0)
+		FROM adjustments
+		WHERE talent_id IN (`+placeholders+`)
+		GROUP BY talent_id, dimension_id`, args...)
 	if err != nil {
 		return result, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var talentID int
-		var adjType string
-		var points int
-		if err := rows.Scan(&talentID, &adjType, &points); err == nil {
-			result[talentID][adjType] = points
+		var talentID, dimensionID, points int
+		if err := rows.Scan(&talentID, &dimensionID, &points); err == nil {
+			if result[talentID] == nil {
+				result[talentID] = make(map[int]int)
+			}
+			result[talentID][dimensionID] = points
 		}
 	}
 
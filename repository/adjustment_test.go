@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"database/sql"
+	"slices"
+	"testing"
+
+	"github.com/Kamekure-Maisuke/maiyumi/model"
+)
+
+func TestAdjustmentRepository_FindByUserID(t *testing.T) {
+	db, adjRepo := setupTalentTestDB(t)
+	defer db.Close()
+
+	talentRepo := NewTalentRepository(db, adjRepo, NewScoreDimensionRepository(db))
+
+	for _, talent := range []*model.Talent{
+		{UserID: 1, Name: "タレント1", Scores: testScores(1, 5, 5, 5)},
+		{UserID: 1, Name: "タレント2", Scores: testScores(1, 5, 5, 5)},
+		{UserID: 2, Name: "他のユーザーのタレント", Scores: testScores(4, 5, 5, 5)},
+	} {
+		if err := talentRepo.Create(talent); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, adj := range []*model.Adjustment{
+		{TalentID: 1, DimensionID: 1, Points: 1, Reason: "1件目"},
+		{TalentID: 2, DimensionID: 2, Points: -2, Reason: "2件目"},
+		{TalentID: 3, DimensionID: 6, Points: 3, Reason: "他のユーザー"},
+		{TalentID: 1, DimensionID: 3, Points: 4, Reason: "3件目"},
+	} {
+		if err := adjRepo.Create(adj); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		userID      int
+		wantReasons []string
+		wantNames   []string
+	}{
+		{name: "複数タレントの履歴を古い順に取得", userID: 1, wantReasons: []string{"1件目", "2件目", "3件目"}, wantNames: []string{"美しさ", "可愛さ", "才能"}},
+		{name: "他のユーザーの履歴は含まない", userID: 2, wantReasons: []string{"他のユーザー"}, wantNames: []string{"才能"}},
+		{name: "履歴がないユーザー", userID: 3, wantReasons: nil, wantNames: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adjustments, err := adjRepo.FindByUserID(tt.userID)
+			if err != nil {
+				t.Fatalf("FindByUserID() error = %v", err)
+			}
+			if len(adjustments) != len(tt.wantReasons) {
+				t.Fatalf("FindByUserID() len = %v, want %v", len(adjustments), len(tt.wantReasons))
+			}
+			for i, adj := range adjustments {
+				if adj.Reason != tt.wantReasons[i] {
+					t.Errorf("FindByUserID()[%d].Reason = %v, want %v", i, adj.Reason, tt.wantReasons[i])
+				}
+				if adj.DimensionName != tt.wantNames[i] {
+					t.Errorf("FindByUserID()[%d].DimensionName = %v, want %v", i, adj.DimensionName, tt.wantNames[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAdjustmentRepository_UpdateDelete(t *testing.T) {
+	db, adjRepo := setupTalentTestDB(t)
+	defer db.Close()
+
+	talentRepo := NewTalentRepository(db, adjRepo, NewScoreDimensionRepository(db))
+
+	talents := []*model.Talent{
+		{UserID: 1, Name: "タレント", Scores: testScores(1, 5, 5, 5)},
+		{UserID: 2, Name: "他のユーザーのタレント", Scores: testScores(4, 5, 5, 5)},
+	}
+	for _, talent := range talents {
+		if err := talentRepo.Create(talent); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, adj := range []*model.Adjustment{
+		{TalentID: talents[0].ID, DimensionID: 1, Points: 3, Reason: "かしょうりょく"},
+		{TalentID: talents[0].ID, DimensionID: 2, Points: 2, Reason: "削除する"},
+		{TalentID: talents[1].ID, DimensionID: 4, Points: 1, Reason: "他のユーザー"},
+	} {
+		if err := adjRepo.Create(adj); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := adjRepo.Update(&model.Adjustment{ID: 3, Points: -5, Reason: "書き換え"}, 1); err != sql.ErrNoRows {
+		t.Errorf("Update() other user's adjustment error = %v, want sql.ErrNoRows", err)
+	}
+	if err := adjRepo.Delete(3, 1); err != sql.ErrNoRows {
+		t.Errorf("Delete() other user's adjustment error = %v, want sql.ErrNoRows", err)
+	}
+	if _, err := adjRepo.FindByID(3, 1); err != sql.ErrNoRows {
+		t.Errorf("FindByID() other user's adjustment error = %v, want sql.ErrNoRows", err)
+	}
+
+	if err := adjRepo.Update(&model.Adjustment{ID: 1, Points: -3, Reason: "歌唱力"}, 1); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	got, err := adjRepo.FindByID(1, 1)
+	if err != nil {
+		t.Fatalf("FindByID() error = %v", err)
+	}
+	if got.Points != -3 || got.Reason != "歌唱力" || got.DimensionID != 1 {
+		t.Errorf("FindByID() after Update() = %+v", got)
+	}
+
+	if err := adjRepo.Delete(2, 1); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := adjRepo.FindByID(2, 1); err != sql.ErrNoRows {
+		t.Errorf("FindByID() after Delete() error = %v, want sql.ErrNoRows", err)
+	}
+
+	// タレントの調整履歴の監査ログは、作成以外を新しい順に辿れる
+	events, err := NewAuditRepository(db).Find(AuditFilter{UserID: 1, EntityType: model.AuditEntityAdjustment, TalentID: talents[0].ID})
+	if err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+	want := []struct {
+		entityID int
+		action   string
+		changes  []model.AuditChange
+	}{
+		{entityID: 2, action: model.AuditActionDelete, changes: []model.AuditChange{
+			{Field: "dimension", Before: "可愛さ"},
+			{Field: "points", Before: "2"},
+			{Field: "reason", Before: "削除する"},
+			{Field: "talent_id", Before: "1"},
+		}},
+		{entityID: 1, action: model.AuditActionUpdate, changes: []model.AuditChange{
+			{Field: "points", Before: "3", After: "-3"},
+			{Field: "reason", Before: "かしょうりょく", After: "歌唱力"},
+		}},
+		{entityID: 2, action: model.AuditActionCreate},
+		{entityID: 1, action: model.AuditActionCreate},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("Find() = %+v, want %d events", events, len(want))
+	}
+	for i, w := range want {
+		if events[i].EntityID != w.entityID || events[i].Action != w.action {
+			t.Errorf("Find()[%d] = %s #%d, want %s #%d", i, events[i].Action, events[i].EntityID, w.action, w.entityID)
+		}
+		if w.changes != nil && !slices.Equal(events[i].Changes, w.changes) {
+			t.Errorf("Find()[%d].Changes = %+v, want %+v", i, events[i].Changes, w.changes)
+		}
+	}
+
+	// ごみ箱のタレントの調整履歴は変更できない
+	if err := talentRepo.Delete(talents[0].ID, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := adjRepo.Delete(1, 1); err != sql.ErrNoRows {
+		t.Errorf("Delete() adjustment of trashed talent error = %v, want sql.ErrNoRows", err)
+	}
+}
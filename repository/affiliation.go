@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"database/sql"
+	"strings"
+	"unicode"
+
+	"github.com/Kamekure-Maisuke/maiyumi/model"
+	"golang.org/x/text/unicode/norm"
+)
+
+type AffiliationRepository interface {
+	FindByID(id, userID int) (*model.Affiliation, error)
+	FindByUserID(userID int) ([]model.Affiliation, error)
+	ScoreStats(id, userID int) ([]model.ScoreStats, error)
+}
+
+type affiliationRepository struct {
+	db *sql.DB
+}
+
+func NewAffiliationRepository(db *sql.DB) AffiliationRepository {
+	return &affiliationRepository{db: db}
+}
+
+// AffiliationKey は所属名の表記ゆれを吸収した比較用のキーを返す。
+// NFKC正規化で全角英数字を半角にし、空白を取り除いて小文字にするため「乃木坂46」と「乃木坂 ４６」は同じキーになる。
+func AffiliationKey(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, norm.NFKC.String(name))
+}
+
+// queryer は *sql.DB と *sql.Tx の共通部分。
+type queryer interface {
+	execer
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// ensureAffiliation は所属名に対応する所属のIDを返す。同じキーの所属がなければ作成する。
+// 所属名が空の場合は NULL を返す。
+func ensureAffiliation(db queryer, userID int, name string) (sql.NullInt64, error) {
+	name = strings.TrimSpace(name)
+	key := AffiliationKey(name)
+	if key == "" {
+		return sql.NullInt64{}, nil
+	}
+
+	_, err := db.Exec(`
+		INSERT INTO affiliations (user_id, name, name_key)
+		VALUES (?, ?, ?)
+		ON CONFLICT (user_id, name_key) DO NOTHING`,
+		userID, name, key)
+	if err != nil {
+		return sql.NullInt64{}, err
+	}
+
+	var id sql.NullInt64
+	err = db.QueryRow("SELECT id FROM affiliations WHERE user_id = ? AND name_key = ?", userID, key).Scan(&id)
+	return id, err
+}
+
+// deleteUnusedAffiliations はどのタレントからも参照されなくなった所属を削除する。
+func deleteUnusedAffiliations(db execer, userID int) error {
+	_, err := db.Exec(`
+		DELETE FROM affiliations
+		WHERE user_id = ? AND id NOT IN (
+			SELECT affiliation_id FROM talents WHERE user_id = ? AND affiliation_id IS NOT NULL
+		)`, userID, userID)
+	return err
+}
+
+func (r *affiliationRepository) FindByID(id, userID int) (*model.Affiliation, error) {
+	var a model.Affiliation
+	err := r.db.QueryRow(`
+		SELECT a.id, a.user_id, a.name, COUNT(t.id)
+		FROM affiliations a
+		LEFT JOIN talents t ON t.affiliation_id = a.id AND t.deleted_at IS NULL
+		WHERE a.id = ? AND a.user_id = ?
+		GROUP BY a.id`, id, userID).Scan(&a.ID, &a.UserID, &a.Name, &a.TalentCount)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// FindByUserID はユーザーの所属を、所属するタレントの数と合わせて名前順に返す。
+// ごみ箱のタレントだけが所属する所属は含めない。
+func (r *affiliationRepository) FindByUserID(userID int) ([]model.Affiliation, error) {
+	rows, err := r.db.Query(`
+		SELECT a.id, a.user_id, a.name, COUNT(t.id)
+		FROM affiliations a
+		LEFT JOIN talents t ON t.affiliation_id = a.id AND t.deleted_at IS NULL
+		WHERE a.user_id = ?
+		GROUP BY a.id
+		HAVING COUNT(t.id) > 0
+		ORDER BY a.name, a.id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var affiliations []model.Affiliation
+	for rows.Next() {
+		var a model.Affiliation
+		if err := rows.Scan(&a.ID, &a.UserID, &a.Name, &a.TalentCount); err != nil {
+			return nil, err
+		}
+		affiliations = append(affiliations, a)
+	}
+	return affiliations, rows.Err()
+}
+
+// ScoreStats は所属するタレントのスコアを評価軸ごとに集計する。
+// 調整を合計した値を対象にし、未評価のタレントは件数に含めない。
+func (r *affiliationRepository) ScoreStats(id, userID int) ([]model.ScoreStats, error) {
+	rows, err := r.db.Query(`
+		SELECT d.id, d.name,
+			COUNT(s.score),
+			COALESCE(AVG(s.score + COALESCE(adj.points, 0)), 0),
+			COALESCE(MAX(s.score + COALESCE(adj.points, 0)), 0),
+			COALESCE(MIN(s.score + COALESCE(adj.points, 0)), 0)
+		FROM score_dimensions d
+		LEFT JOIN talents t ON t.user_id = d.user_id AND t.affiliation_id = ? AND t.deleted_at IS NULL
+		LEFT JOIN talent_scores s ON s.talent_id = t.id AND s.dimension_id = d.id
+		LEFT JOIN (
+			SELECT talent_id, dimension_id, SUM(points) AS points
+			FROM adjustments
+			GROUP BY talent_id, dimension_id
+		) adj ON adj.talent_id = s.talent_id AND adj.dimension_id = s.dimension_id
+		WHERE d.user_id = ?
+		GROUP BY d.id
+		ORDER BY d.display_order, d.id`, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []model.ScoreStats
+	for rows.Next() {
+		var s model.ScoreStats
+		if err := rows.Scan(&s.DimensionID, &s.Name, &s.Count, &s.Average, &s.Max, &s.Min); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/Kamekure-Maisuke/maiyumi/model"
+)
+
+func TestAffiliationKey(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "そのまま", in: "乃木坂46", want: "乃木坂46"},
+		{name: "半角スペース", in: "乃木坂 46", want: "乃木坂46"},
+		{name: "全角数字と全角スペース", in: "乃木坂　４６", want: "乃木坂46"},
+		{name: "大文字小文字", in: "AKB48", want: "akb48"},
+		{name: "前後の空白", in: "  櫻坂46 ", want: "櫻坂46"},
+		{name: "空白だけ", in: "　 ", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AffiliationKey(tt.in); got != tt.want {
+				t.Errorf("AffiliationKey(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTalentRepository_Affiliation(t *testing.T) {
+	db, adjRepo := setupTalentTestDB(t)
+	defer db.Close()
+
+	repo := NewTalentRepository(db, adjRepo, NewScoreDimensionRepository(db))
+	affRepo := NewAffiliationRepository(db)
+
+	talents := []*model.Talent{
+		{UserID: 1, Name: "A", Affiliation: sql.NullString{String: "乃木坂46", Valid: true}},
+		{UserID: 1, Name: "B", Affiliation: sql.NullString{String: "乃木坂 ４６", Valid: true}},
+		{UserID: 1, Name: "C", Affiliation: sql.NullString{String: "櫻坂46", Valid: true}},
+		{UserID: 1, Name: "D"},
+		{UserID: 2, Name: "E", Affiliation: sql.NullString{String: "乃木坂46", Valid: true}},
+	}
+	for _, talent := range talents {
+		talent.Scores = testScores(1, 5, 5, 5)
+		if err := repo.Create(talent); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if talents[0].AffiliationID != talents[1].AffiliationID {
+		t.Errorf("Create() affiliation_id = %v, %v, want same for similar names", talents[0].AffiliationID, talents[1].AffiliationID)
+	}
+	if talents[0].AffiliationID == talents[4].AffiliationID {
+		t.Errorf("Create() shares affiliation between users")
+	}
+	if talents[3].AffiliationID.Valid {
+		t.Errorf("Create() affiliation_id = %v, want NULL", talents[3].AffiliationID)
+	}
+
+	found, err := repo.FindByID(talents[1].ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Affiliation.String != "乃木坂46" {
+		t.Errorf("FindByID() affiliation = %v, want the first registered name %v", found.Affiliation.String, "乃木坂46")
+	}
+
+	members, err := repo.FindByAffiliationID(1, int(talents[0].AffiliationID.Int64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 2 {
+		t.Errorf("FindByAffiliationID() returned %d talents, want 2", len(members))
+	}
+
+	// 最後の所属タレントが移ると所属も消える
+	talents[2].Affiliation = sql.NullString{String: "乃木坂46", Valid: true}
+	if err := repo.Update(talents[2]); err != nil {
+		t.Fatal(err)
+	}
+	affiliations, err := affRepo.FindByUserID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(affiliations) != 1 || affiliations[0].Name != "乃木坂46" || affiliations[0].TalentCount != 3 {
+		t.Errorf("FindByUserID() = %+v, want only 乃木坂46 with 3 talents", affiliations)
+	}
+
+	if _, err := affRepo.FindByID(affiliations[0].ID, 2); err != sql.ErrNoRows {
+		t.Errorf("FindByID() with wrong user_id error = %v, want %v", err, sql.ErrNoRows)
+	}
+}
+
+func TestAffiliationRepository_ScoreStats(t *testing.T) {
+	db, adjRepo := setupTalentTestDB(t)
+	defer db.Close()
+
+	repo := NewTalentRepository(db, adjRepo, NewScoreDimensionRepository(db))
+	affRepo := NewAffiliationRepository(db)
+
+	group := sql.NullString{String: "グループ", Valid: true}
+	unrated := testScores(1, 4, 9)
+	talents := []*model.Talent{
+		{UserID: 1, Name: "A", Affiliation: group, Scores: testScores(1, 6, 8, 3)},
+		{UserID: 1, Name: "B", Affiliation: group, Scores: unrated},
+		{UserID: 1, Name: "他の所属", Affiliation: sql.NullString{String: "別", Valid: true}, Scores: testScores(1, 10, 10, 10)},
+	}
+	for _, talent := range talents {
+		if err := repo.Create(talent); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := adjRepo.Create(&model.Adjustment{TalentID: talents[0].ID, DimensionID: 1, Points: 2, Reason: "加点"}); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := affRepo.ScoreStats(int(talents[0].AffiliationID.Int64), 1)
+	if err != nil {
+		t.Fatalf("ScoreStats() error = %v", err)
+	}
+
+	want := []model.ScoreStats{
+		{DimensionID: 1, Name: "美しさ", Count: 2, Average: 6, Max: 8, Min: 4},
+		{DimensionID: 2, Name: "可愛さ", Count: 2, Average: 8.5, Max: 9, Min: 8},
+		{DimensionID: 3, Name: "才能", Count: 1, Average: 3, Max: 3, Min: 3},
+	}
+	if len(stats) != len(want) {
+		t.Fatalf("ScoreStats() len = %d, want %d", len(stats), len(want))
+	}
+	for i := range want {
+		if stats[i] != want[i] {
+			t.Errorf("ScoreStats()[%d] = %+v, want %+v", i, stats[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package repository
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Kamekure-Maisuke/maiyumi/model"
+)
+
+type AuditRepository interface {
+	Find(filter AuditFilter) ([]model.AuditEvent, error)
+}
+
+type auditRepository struct {
+	db *sql.DB
+}
+
+func NewAuditRepository(db *sql.DB) AuditRepository {
+	return &auditRepository{db: db}
+}
+
+// auditRedacted は監査ログに値を残さない項目。変更があったことだけを redactedValue で記録する。
+var auditRedacted = map[string]bool{"password": true}
+
+const redactedValue = "[非表示]"
+
+// auditSnapshot は監査ログで比べる、ある時点のデータの項目と値。
+type auditSnapshot map[string]any
+
+// auditValue は監査ログに記録する1項目の変更前と変更後の値。作成では before、削除では after を省く。
+type auditValue struct {
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+// auditEntry は記録する監査ログの1件。
+type auditEntry struct {
+	userID     int           // 変更されたデータの持ち主
+	actorID    sql.NullInt64 // 変更したユーザー。自動で削除した場合は無効にする
+	entityType string
+	entityID   int
+	action     string
+	before     auditSnapshot // 作成した場合は nil
+	after      auditSnapshot // 削除した場合は nil
+}
+
+// actor は userID のユーザー自身が変更した場合の actorID。
+func actor(userID int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(userID), Valid: true}
+}
+
+// auditDiff は変更前と変更後で値が異なる項目をJSONにする。変更がなければ空を返す。
+func auditDiff(before, after auditSnapshot) (string, error) {
+	encode := func(snapshot auditSnapshot, field string) (json.RawMessage, error) {
+		v, ok := snapshot[field]
+		if !ok {
+			return nil, nil
+		}
+		return json.Marshal(v)
+	}
+
+	fields := slices.Concat(slices.Collect(maps.Keys(before)), slices.Collect(maps.Keys(after)))
+	slices.Sort(fields)
+
+	diff := make(map[string]auditValue)
+	for _, field := range slices.Compact(fields) {
+		b, err := encode(before, field)
+		if err != nil {
+			return "", err
+		}
+		a, err := encode(after, field)
+		if err != nil {
+			return "", err
+		}
+		if bytes.Equal(b, a) {
+			continue
+		}
+		if auditRedacted[field] {
+			redacted, _ := json.Marshal(redactedValue)
+			if b != nil {
+				b = redacted
+			}
+			if a != nil {
+				a = redacted
+			}
+		}
+		diff[field] = auditValue{Before: b, After: a}
+	}
+	if len(diff) == 0 {
+		return "", nil
+	}
+	b, err := json.Marshal(diff)
+	return string(b), err
+}
+
+// recordAudit は監査ログを1件記録する。更新で値が変わった項目がない場合は記録しない。
+func recordAudit(db execer, e auditEntry) error {
+	changes, err := auditDiff(e.before, e.after)
+	if err != nil {
+		return err
+	}
+	if changes == "" {
+		if e.action == model.AuditActionUpdate {
+			return nil
+		}
+		changes = "{}"
+	}
+	_, err = db.Exec(`
+		INSERT INTO audit_events (user_id, actor_id, entity_type, entity_id, action, changes)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		e.userID, e.actorID, e.entityType, e.entityID, e.action, changes)
+	return err
+}
+
+// userSnapshot はユーザーの監査ログで比べる項目を返す。password は auditRedacted で値を伏せる。
+func userSnapshot(db queryer, userID int) (auditSnapshot, error) {
+	var username, password string
+	var totpEnabled bool
+	err := db.QueryRow("SELECT username, password, totp_secret != '' FROM users WHERE id = ?", userID).
+		Scan(&username, &password, &totpEnabled)
+	if err != nil {
+		return nil, err
+	}
+	return auditSnapshot{"username": username, "password": password, "totp_enabled": totpEnabled}, nil
+}
+
+// talentSnapshot はタレントの監査ログで比べる項目を返す。スコアは評価軸ごとに scores.<評価軸の名前> の項目にする。
+func talentSnapshot(db queryer, talentID int) (auditSnapshot, error) {
+	var name, affiliation, scores, tags string
+	var isFavorite bool
+	err := db.QueryRow(`
+		SELECT t.name, COALESCE(a.name, ''), t.is_favorite,
+			(SELECT json_group_object(d.name, s.score)
+				FROM talent_scores s JOIN score_dimensions d ON d.id = s.dimension_id
+				WHERE s.talent_id = t.id),
+			(SELECT json_group_array(name) FROM (
+				SELECT g.name FROM talent_tags tt JOIN tags g ON g.id = tt.tag_id
+				WHERE tt.talent_id = t.id ORDER BY g.name))
+		FROM talents t
+		LEFT JOIN affiliations a ON a.id = t.affiliation_id
+		WHERE t.id = ?`, talentID).Scan(&name, &affiliation, &isFavorite, &scores, &tags)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := auditSnapshot{"name": name, "affiliation": affiliation, "is_favorite": isFavorite}
+	var scoreValues map[string]int
+	if err := json.Unmarshal([]byte(scores), &scoreValues); err != nil {
+		return nil, err
+	}
+	for dimension, score := range scoreValues {
+		snapshot["scores."+dimension] = score
+	}
+	var tagNames []string
+	if err := json.Unmarshal([]byte(tags), &tagNames); err != nil {
+		return nil, err
+	}
+	snapshot["tags"] = tagNames
+	return snapshot, nil
+}
+
+// adjustmentSnapshot は調整履歴の監査ログで比べる項目を返す。
+func adjustmentSnapshot(a *model.Adjustment) auditSnapshot {
+	return auditSnapshot{
+		"talent_id": a.TalentID,
+		"dimension": a.DimensionName,
+		"points":    a.Points,
+		"reason":    a.Reason,
+	}
+}
+
+// AuditFilter は監査ログの絞り込みの条件。
+type AuditFilter struct {
+	UserID        int    // このユーザーのデータの監査ログ
+	EntityType    string // 空の場合は絞り込まない
+	EntityID      int    // 0 の場合は絞り込まない
+	TalentID      int    // 0 以外の場合は、このタレントの調整履歴の監査ログ
+	Action        string // 空の場合は絞り込まない
+	CreatedFrom   time.Time
+	CreatedBefore time.Time
+	BeforeID      int // 0 以外の場合は、このIDより前の監査ログ
+	Limit         int
+}
+
+// Find は絞り込みに一致する監査ログを新しい順に返す。
+func (r *auditRepository) Find(filter AuditFilter) ([]model.AuditEvent, error) {
+	where := []string{"user_id = ?"}
+	args := []any{filter.UserID}
+	if filter.EntityType != "" {
+		where = append(where, "entity_type = ?")
+		args = append(args, filter.EntityType)
+	}
+	if filter.EntityID != 0 {
+		where = append(where, "entity_id = ?")
+		args = append(args, filter.EntityID)
+	}
+	if filter.TalentID != 0 {
+		// 更新の記録には変わらない talent_id が残らないため、今ある調整履歴か、作成・削除の記録からタレントを辿る
+		where = append(where, `entity_type = '`+model.AuditEntityAdjustment+`' AND (
+			entity_id IN (SELECT id FROM adjustments WHERE talent_id = ?)
+			OR entity_id IN (
+				SELECT entity_id FROM audit_events
+				WHERE entity_type = '`+model.AuditEntityAdjustment+`'
+					AND ? IN (json_extract(changes, '$.talent_id.before'), json_extract(changes, '$.talent_id.after'))
+			))`)
+		args = append(args, filter.TalentID, filter.TalentID)
+	}
+	if filter.Action != "" {
+		where = append(where, "action = ?")
+		args = append(args, filter.Action)
+	}
+	if !filter.CreatedFrom.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, filter.CreatedFrom.UTC().Format(sqliteTimeLayout))
+	}
+	if !filter.CreatedBefore.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, filter.CreatedBefore.UTC().Format(sqliteTimeLayout))
+	}
+	if filter.BeforeID != 0 {
+		where = append(where, "id < ?")
+		args = append(args, filter.BeforeID)
+	}
+
+	stmt := `
+		SELECT id, user_id, actor_id, entity_type, entity_id, action, changes, created_at
+		FROM audit_events
+		WHERE ` + strings.Join(where, " AND ") + `
+		ORDER BY id DESC`
+	if filter.Limit > 0 {
+		stmt += " LIMIT " + strconv.Itoa(filter.Limit)
+	}
+
+	rows, err := r.db.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []model.AuditEvent
+	for rows.Next() {
+		var e model.AuditEvent
+		var changes string
+		if err := rows.Scan(&e.ID, &e.UserID, &e.ActorID, &e.EntityType, &e.EntityID, &e.Action, &changes, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if e.Changes, err = parseAuditChanges(changes); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// parseAuditChanges は記録した変更のJSONを項目名順の一覧にする。
+func parseAuditChanges(changes string) ([]model.AuditChange, error) {
+	var diff map[string]auditValue
+	if err := json.Unmarshal([]byte(changes), &diff); err != nil {
+		return nil, err
+	}
+	var parsed []model.AuditChange
+	for _, field := range slices.Sorted(maps.Keys(diff)) {
+		v := diff[field]
+		parsed = append(parsed, model.AuditChange{
+			Field:  field,
+			Before: formatAuditValue(v.Before),
+			After:  formatAuditValue(v.After),
+		})
+	}
+	return parsed, nil
+}
+
+// formatAuditValue は記録した値を表示用の文字列にする。文字列は引用符を外し、値がない場合は空にする。
+func formatAuditValue(raw json.RawMessage) string {
+	if raw == nil {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(raw)
+}
@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"database/sql"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kamekure-Maisuke/maiyumi/model"
+)
+
+func TestAuditDiff(t *testing.T) {
+	tests := []struct {
+		name   string
+		before auditSnapshot
+		after  auditSnapshot
+		want   string
+	}{
+		{
+			name:  "作成は変更後の値だけを記録する",
+			after: auditSnapshot{"name": "山田", "points": 3},
+			want:  `{"name":{"after":"山田"},"points":{"after":3}}`,
+		},
+		{
+			name:   "変わった項目だけを記録する",
+			before: auditSnapshot{"name": "山田", "tags": []string{"推し"}},
+			after:  auditSnapshot{"name": "山田", "tags": []string{"推し", "センター"}},
+			want:   `{"tags":{"before":["推し"],"after":["推し","センター"]}}`,
+		},
+		{
+			name:   "パスワードは値を伏せる",
+			before: auditSnapshot{"username": "user", "password": "$argon2id$old"},
+			after:  auditSnapshot{"username": "user", "password": "$argon2id$new"},
+			want:   `{"password":{"before":"[非表示]","after":"[非表示]"}}`,
+		},
+		{
+			name:   "削除は変更前の値だけを記録する",
+			before: auditSnapshot{"reason": "加点"},
+			want:   `{"reason":{"before":"加点"}}`,
+		},
+		{
+			name:   "変更なし",
+			before: auditSnapshot{"name": "山田"},
+			after:  auditSnapshot{"name": "山田"},
+			want:   "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := auditDiff(tt.before, tt.after)
+			if err != nil {
+				t.Fatalf("auditDiff() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("auditDiff() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserRepository_Audit(t *testing.T) {
+	db := setupUserTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db)
+	auditRepo := NewAuditRepository(db)
+
+	if err := repo.Create("auditor", "$argon2id$first-hash"); err != nil {
+		t.Fatal(err)
+	}
+	userID, err := repo.GetID("auditor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.UpdatePassword(userID, "$argon2id$second-hash"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.UpdateUsername(userID, "auditor2"); err != nil {
+		t.Fatal(err)
+	}
+
+	var leaked int
+	err = db.QueryRow("SELECT COUNT(*) FROM audit_events WHERE changes LIKE '%hash%'").Scan(&leaked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaked != 0 {
+		t.Errorf("audit_events contains %d password hashes", leaked)
+	}
+
+	events, err := auditRepo.Find(AuditFilter{UserID: userID, EntityType: model.AuditEntityUser})
+	if err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+	want := []struct {
+		action  string
+		changes []model.AuditChange
+	}{
+		{action: model.AuditActionUpdate, changes: []model.AuditChange{{Field: "username", Before: "auditor", After: "auditor2"}}},
+		{action: model.AuditActionUpdate, changes: []model.AuditChange{{Field: "password", Before: redactedValue, After: redactedValue}}},
+		{action: model.AuditActionCreate, changes: []model.AuditChange{
+			{Field: "password", After: redactedValue},
+			{Field: "totp_enabled", After: "false"},
+			{Field: "username", After: "auditor"},
+		}},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("Find() = %+v, want %d events", events, len(want))
+	}
+	for i, w := range want {
+		if events[i].Action != w.action || !slices.Equal(events[i].Changes, w.changes) {
+			t.Errorf("Find()[%d] = %s %+v, want %s %+v", i, events[i].Action, events[i].Changes, w.action, w.changes)
+		}
+		if events[i].ActorID != (sql.NullInt64{Int64: int64(userID), Valid: true}) {
+			t.Errorf("Find()[%d].ActorID = %v, want %d", i, events[i].ActorID, userID)
+		}
+	}
+}
+
+func TestTalentRepository_Audit(t *testing.T) {
+	db, adjRepo := setupTalentTestDB(t)
+	defer db.Close()
+
+	repo := NewTalentRepository(db, adjRepo, NewScoreDimensionRepository(db))
+	auditRepo := NewAuditRepository(db)
+
+	talent := &model.Talent{UserID: 1, Name: "監査", Scores: testScores(1, 5, 6, 7)}
+	if err := repo.Create(talent); err != nil {
+		t.Fatal(err)
+	}
+	talent.Name = "監査テスト"
+	talent.Scores = testScores(1, 5, 6, 8)
+	if err := repo.Update(talent); err != nil {
+		t.Fatal(err)
+	}
+	// 変更のない更新と、他のユーザーによる変更は記録しない
+	if err := repo.Update(talent); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.ToggleFavorite(talent.ID, 2); err != nil {
+		t.Fatal(err)
+	}
+	adj := &model.Adjustment{TalentID: talent.ID, DimensionID: 1, Points: 2, Reason: "加点"}
+	if err := adjRepo.Create(adj); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Delete(talent.ID, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.PurgeTrash(time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := auditRepo.Find(AuditFilter{UserID: 1})
+	if err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+	var got []string
+	for _, e := range events {
+		got = append(got, e.EntityType+" "+e.Action)
+	}
+	want := []string{"talent delete", "adjustment delete", "talent trash", "adjustment create", "talent update", "talent create"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("Find() = %v, want %v", got, want)
+	}
+
+	for _, e := range events[:2] {
+		if e.ActorID.Valid {
+			t.Errorf("PurgeTrash() %s ActorID = %v, want null", e.EntityType, e.ActorID)
+		}
+	}
+	if i := slices.IndexFunc(events[0].Changes, func(c model.AuditChange) bool { return c.Field == "name" }); i < 0 || events[0].Changes[i].Before != "監査テスト" {
+		t.Errorf("PurgeTrash() Changes = %+v, want the name before deleting", events[0].Changes)
+	}
+	wantUpdate := []model.AuditChange{
+		{Field: "name", Before: "監査", After: "監査テスト"},
+		{Field: "scores.才能", Before: "7", After: "8"},
+	}
+	if !slices.Equal(events[4].Changes, wantUpdate) {
+		t.Errorf("Update() Changes = %+v, want %+v", events[4].Changes, wantUpdate)
+	}
+
+	t.Run("絞り込み", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			filter AuditFilter
+			want   int
+		}{
+			{name: "種類", filter: AuditFilter{UserID: 1, EntityType: model.AuditEntityAdjustment}, want: 2},
+			{name: "タレントの調整履歴", filter: AuditFilter{UserID: 1, TalentID: talent.ID}, want: 2},
+			{name: "対象のID", filter: AuditFilter{UserID: 1, EntityType: model.AuditEntityTalent, EntityID: talent.ID}, want: 4},
+			{name: "操作", filter: AuditFilter{UserID: 1, Action: model.AuditActionCreate}, want: 2},
+			{name: "期間", filter: AuditFilter{UserID: 1, CreatedBefore: time.Now().Add(-time.Hour)}, want: 0},
+			{name: "件数とIDで続きを取得", filter: AuditFilter{UserID: 1, BeforeID: events[1].ID, Limit: 2}, want: 2},
+			{name: "他のユーザー", filter: AuditFilter{UserID: 2}, want: 0},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				events, err := auditRepo.Find(tt.filter)
+				if err != nil {
+					t.Fatalf("Find() error = %v", err)
+				}
+				if len(events) != tt.want {
+					t.Errorf("Find(%+v) = %d events, want %d", tt.filter, len(events), tt.want)
+				}
+			})
+		}
+	})
+
+	var raw string
+	if err := db.QueryRow("SELECT changes FROM audit_events WHERE entity_type = 'adjustment' ORDER BY id LIMIT 1").Scan(&raw); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(raw, `"reason":{"after":"加点"}`) {
+		t.Errorf("adjustment changes = %s", raw)
+	}
+}
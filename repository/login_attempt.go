@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+)
+
+type LoginAttemptRepository interface {
+	Record(username, ipAddress string, succeeded bool) error
+	LockedUntil(username, ipAddress string) (time.Time, error)
+	Unlock(username string) error
+	UnlockIP(ipAddress string) error
+}
+
+// LoginThrottlePolicy はログイン失敗によるロックの設定。
+// 失敗回数が上限に達するとロックされ、以降1回失敗するごとにロック時間が倍になる。
+type LoginThrottlePolicy struct {
+	Window        time.Duration // 失敗回数を数える期間
+	UserThreshold int           // ユーザー名ごとの失敗回数の上限
+	IPThreshold   int           // IPアドレスごとの失敗回数の上限
+	BaseLockout   time.Duration // 上限に達したときのロック時間
+	MaxLockout    time.Duration // ロック時間の上限
+}
+
+func (p LoginThrottlePolicy) lockout(failures, threshold int) time.Duration {
+	if threshold <= 0 || failures < threshold {
+		return 0
+	}
+	lockout := p.BaseLockout
+	for i := threshold; i < failures && lockout < p.MaxLockout; i++ {
+		lockout *= 2
+	}
+	return min(lockout, p.MaxLockout)
+}
+
+type loginAttemptRepository struct {
+	db     *sql.DB
+	policy LoginThrottlePolicy
+	now    func() time.Time
+}
+
+func NewLoginAttemptRepository(db *sql.DB, policy LoginThrottlePolicy) LoginAttemptRepository {
+	return &loginAttemptRepository{db: db, policy: policy, now: time.Now}
+}
+
+func (r *loginAttemptRepository) Record(username, ipAddress string, succeeded bool) error {
+	_, err := r.db.Exec(`
+		INSERT INTO login_attempts (username, ip_address, succeeded, created_at)
+		VALUES (?, ?, ?, ?)`,
+		username, ipAddress, succeeded, r.now().UTC().Format(sqliteTimeLayout))
+	return err
+}
+
+// failures は期間内に記録された失敗回数と最後の失敗時刻を返す。
+// resetOnSuccess の場合は最後に成功した後の失敗だけを数える。
+// column には固定の列名だけを渡すこと。ユーザー名は大文字小文字を区別しない。
+func (r *loginAttemptRepository) failures(column, value string, since time.Time, resetOnSuccess bool) (int, time.Time, error) {
+	query := `
+		SELECT COUNT(*), COALESCE(MAX(created_at), '')
+		FROM login_attempts
+		WHERE ` + column + ` = ? COLLATE NOCASE AND succeeded = 0 AND cleared = 0 AND created_at >= ?`
+	args := []any{value, since.UTC().Format(sqliteTimeLayout)}
+	if resetOnSuccess {
+		query += `
+			AND created_at > COALESCE((
+				SELECT MAX(created_at) FROM login_attempts WHERE ` + column + ` = ? COLLATE NOCASE AND succeeded = 1
+			), '')`
+		args = append(args, value)
+	}
+
+	var count int
+	var last string
+	err := r.db.QueryRow(query, args...).Scan(&count, &last)
+	if err != nil || count == 0 {
+		return 0, time.Time{}, err
+	}
+
+	lastFailure, err := time.Parse(sqliteTimeLayout, last)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return count, lastFailure, nil
+}
+
+// LockedUntil はユーザー名またはIPアドレスがロックされている場合に解除時刻を返す。
+// ロックされていない場合はゼロ値を返す。
+// ログインに成功するとユーザー名の失敗回数はリセットされるが、IPアドレスの失敗回数はリセットしない。
+// 攻撃者が自分のアカウントにログインしてIPアドレスのロックを回避できないようにするため。
+func (r *loginAttemptRepository) LockedUntil(username, ipAddress string) (time.Time, error) {
+	now := r.now()
+	since := now.Add(-r.policy.Window)
+
+	var until time.Time
+	checks := []struct {
+		column         string
+		value          string
+		threshold      int
+		resetOnSuccess bool
+	}{
+		{column: "username", value: username, threshold: r.policy.UserThreshold, resetOnSuccess: true},
+		{column: "ip_address", value: ipAddress, threshold: r.policy.IPThreshold},
+	}
+	for _, c := range checks {
+		count, lastFailure, err := r.failures(c.column, c.value, since, c.resetOnSuccess)
+		if err != nil {
+			return time.Time{}, err
+		}
+		lockout := r.policy.lockout(count, c.threshold)
+		if lockout == 0 {
+			continue
+		}
+		if t := lastFailure.Add(lockout); t.After(now) && t.After(until) {
+			until = t
+		}
+	}
+	return until, nil
+}
+
+// Unlock はユーザー名に対する失敗記録を無効にしてロックを解除する。記録自体は残す。
+func (r *loginAttemptRepository) Unlock(username string) error {
+	_, err := r.db.Exec("UPDATE login_attempts SET cleared = 1 WHERE username = ? COLLATE NOCASE AND succeeded = 0", username)
+	return err
+}
+
+// UnlockIP はIPアドレスに対する失敗記録を無効にしてロックを解除する。記録自体は残す。
+func (r *loginAttemptRepository) UnlockIP(ipAddress string) error {
+	_, err := r.db.Exec("UPDATE login_attempts SET cleared = 1 WHERE ip_address = ? AND succeeded = 0", ipAddress)
+	return err
+}
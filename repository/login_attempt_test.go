@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+func setupLoginAttemptTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`
+		CREATE TABLE login_attempts (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			username TEXT NOT NULL,
+			ip_address TEXT NOT NULL,
+			succeeded BOOLEAN NOT NULL,
+			cleared BOOLEAN NOT NULL DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+var testLoginThrottlePolicy = LoginThrottlePolicy{
+	Window:        time.Hour,
+	UserThreshold: 3,
+	IPThreshold:   5,
+	BaseLockout:   time.Minute,
+	MaxLockout:    10 * time.Minute,
+}
+
+func newTestLoginAttemptRepository(t *testing.T, clock *fakeClock) (*sql.DB, LoginAttemptRepository) {
+	db := setupLoginAttemptTestDB(t)
+	repo := NewLoginAttemptRepository(db, testLoginThrottlePolicy).(*loginAttemptRepository)
+	repo.now = clock.Now
+	return db, repo
+}
+
+func TestLoginThrottlePolicy_Lockout(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		want     time.Duration
+	}{
+		{name: "上限未満", failures: 2, want: 0},
+		{name: "上限ちょうど", failures: 3, want: time.Minute},
+		{name: "上限を1回超過", failures: 4, want: 2 * time.Minute},
+		{name: "上限を2回超過", failures: 5, want: 4 * time.Minute},
+		{name: "最大値で打ち止め", failures: 20, want: 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := testLoginThrottlePolicy.lockout(tt.failures, testLoginThrottlePolicy.UserThreshold)
+			if got != tt.want {
+				t.Errorf("lockout(%d) = %v, want %v", tt.failures, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoginAttemptRepository_LockedUntil(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		record     func(repo LoginAttemptRepository, clock *fakeClock)
+		wantLocked bool
+	}{
+		{
+			name: "失敗回数が上限未満",
+			record: func(repo LoginAttemptRepository, clock *fakeClock) {
+				repo.Record("alice", "192.0.2.1", false)
+				repo.Record("alice", "192.0.2.1", false)
+			},
+			wantLocked: false,
+		},
+		{
+			name: "ユーザー名ごとの上限に到達",
+			record: func(repo LoginAttemptRepository, clock *fakeClock) {
+				for range 3 {
+					repo.Record("alice", "192.0.2.1", false)
+				}
+			},
+			wantLocked: true,
+		},
+		{
+			name: "大文字小文字が違っても同じユーザー名として数える",
+			record: func(repo LoginAttemptRepository, clock *fakeClock) {
+				for _, username := range []string{"Alice", "ALICE", "alice"} {
+					repo.Record(username, "198.51.100.1", false)
+				}
+			},
+			wantLocked: true,
+		},
+		{
+			name: "IPアドレスごとの上限に到達",
+			record: func(repo LoginAttemptRepository, clock *fakeClock) {
+				for _, username := range []string{"a", "b", "c", "d", "e"} {
+					repo.Record(username, "192.0.2.1", false)
+				}
+			},
+			wantLocked: true,
+		},
+		{
+			name: "ロック時間経過後は解除",
+			record: func(repo LoginAttemptRepository, clock *fakeClock) {
+				for range 3 {
+					repo.Record("alice", "192.0.2.1", false)
+				}
+				clock.Advance(2 * time.Minute)
+			},
+			wantLocked: false,
+		},
+		{
+			name: "成功すると失敗回数がリセットされる",
+			record: func(repo LoginAttemptRepository, clock *fakeClock) {
+				repo.Record("alice", "192.0.2.1", false)
+				repo.Record("alice", "192.0.2.1", false)
+				clock.Advance(time.Second)
+				repo.Record("alice", "192.0.2.1", true)
+				clock.Advance(time.Second)
+				repo.Record("alice", "192.0.2.1", false)
+			},
+			wantLocked: false,
+		},
+		{
+			name: "別のアカウントで成功してもIPアドレスの失敗回数はリセットされない",
+			record: func(repo LoginAttemptRepository, clock *fakeClock) {
+				for _, username := range []string{"a", "b", "c", "d"} {
+					repo.Record(username, "192.0.2.1", false)
+				}
+				clock.Advance(time.Second)
+				repo.Record("mallory", "192.0.2.1", true)
+				clock.Advance(time.Second)
+				repo.Record("e", "192.0.2.1", false)
+			},
+			wantLocked: true,
+		},
+		{
+			name: "管理者による解除",
+			record: func(repo LoginAttemptRepository, clock *fakeClock) {
+				for range 3 {
+					repo.Record("alice", "192.0.2.1", false)
+				}
+				repo.Unlock("alice")
+			},
+			wantLocked: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &fakeClock{t: start}
+			db, repo := newTestLoginAttemptRepository(t, clock)
+			defer db.Close()
+
+			tt.record(repo, clock)
+
+			until, err := repo.LockedUntil("alice", "192.0.2.1")
+			if err != nil {
+				t.Fatalf("LockedUntil() error = %v", err)
+			}
+			if locked := !until.IsZero(); locked != tt.wantLocked {
+				t.Errorf("LockedUntil() locked = %v (until %v), want %v", locked, until, tt.wantLocked)
+			}
+		})
+	}
+}
+
+func TestLoginAttemptRepository_UnlockKeepsRecords(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	db, repo := newTestLoginAttemptRepository(t, clock)
+	defer db.Close()
+
+	for range 3 {
+		repo.Record("alice", "192.0.2.1", false)
+	}
+	if err := repo.UnlockIP("192.0.2.1"); err != nil {
+		t.Fatalf("UnlockIP() error = %v", err)
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM login_attempts").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("Failed attempts should be kept after unlock, count = %d", count)
+	}
+}
@@ -0,0 +1,235 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Kamekure-Maisuke/maiyumi/model"
+)
+
+// revisionScore は版に記録するスコア。評価軸を後から改名・削除しても読めるよう名前も残す。
+type revisionScore struct {
+	DimensionID int    `json:"dimension_id"`
+	Name        string `json:"name"`
+	Score       int    `json:"score"`
+}
+
+// revisionTag は版に記録するタグ。
+type revisionTag struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// insertRevision はタレントの現在の名前・所属・スコア・タグを新しい版として記録する。
+// revertedFrom は過去の版に戻した場合の、戻した版のID。
+func insertRevision(tx *sql.Tx, talentID int, revertedFrom sql.NullInt64) error {
+	var userID int
+	var name, affiliation string
+	err := tx.QueryRow(`
+		SELECT t.user_id, t.name, COALESCE(a.name, '')
+		FROM talents t
+		LEFT JOIN affiliations a ON a.id = t.affiliation_id
+		WHERE t.id = ?`, talentID).Scan(&userID, &name, &affiliation)
+	if err != nil {
+		return err
+	}
+
+	scores := []revisionScore{}
+	rows, err := tx.Query(`
+		SELECT d.id, d.name, s.score
+		FROM talent_scores s
+		JOIN score_dimensions d ON d.id = s.dimension_id
+		WHERE s.talent_id = ?
+		ORDER BY d.display_order, d.id`, talentID)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var s revisionScore
+		if err := rows.Scan(&s.DimensionID, &s.Name, &s.Score); err != nil {
+			rows.Close()
+			return err
+		}
+		scores = append(scores, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	tags := []revisionTag{}
+	rows, err = tx.Query(`
+		SELECT g.id, g.name
+		FROM talent_tags tt
+		JOIN tags g ON g.id = tt.tag_id
+		WHERE tt.talent_id = ?
+		ORDER BY g.name, g.id`, talentID)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var t revisionTag
+		if err := rows.Scan(&t.ID, &t.Name); err != nil {
+			rows.Close()
+			return err
+		}
+		tags = append(tags, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	scoresJSON, err := json.Marshal(scores)
+	if err != nil {
+		return err
+	}
+	tagsJSON, err := json.Marshal(tags)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO talent_revisions (talent_id, user_id, name, affiliation, scores, tags, reverted_from)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		talentID, userID, name, affiliation, string(scoresJSON), string(tagsJSON), revertedFrom)
+	return err
+}
+
+// BackfillTalentRevisions は版が1つもないタレントについて、現在の内容を最初の版として記録する。
+func BackfillTalentRevisions(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT id FROM talents WHERE id NOT IN (SELECT talent_id FROM talent_revisions)")
+	if err != nil {
+		return err
+	}
+	var talentIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		talentIDs = append(talentIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range talentIDs {
+		if err := insertRevision(tx, id, sql.NullInt64{}); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// FindRevisions はユーザーのタレントの版を古い順に、1つ前の版からの変更と合わせて返す。
+func (r *talentRepository) FindRevisions(talentID, userID int) ([]model.TalentRevision, error) {
+	rows, err := r.db.Query(`
+		SELECT id, talent_id, name, affiliation, scores, tags, reverted_from, created_at
+		FROM talent_revisions
+		WHERE talent_id = ? AND user_id = ?
+		ORDER BY id`, talentID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []model.TalentRevision
+	numbers := make(map[int64]int) // 版のID → 版の番号
+	for rows.Next() {
+		var rev model.TalentRevision
+		var scores, tags string
+		var revertedFrom sql.NullInt64
+		err := rows.Scan(&rev.ID, &rev.TalentID, &rev.Name, &rev.Affiliation, &scores, &tags, &revertedFrom, &rev.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		var revScores []revisionScore
+		if err := json.Unmarshal([]byte(scores), &revScores); err != nil {
+			return nil, err
+		}
+		for _, s := range revScores {
+			rev.Scores = append(rev.Scores, model.Score{DimensionID: s.DimensionID, Name: s.Name, Value: s.Score, Total: s.Score, Rated: true})
+		}
+		var revTags []revisionTag
+		if err := json.Unmarshal([]byte(tags), &revTags); err != nil {
+			return nil, err
+		}
+		for _, t := range revTags {
+			rev.Tags = append(rev.Tags, model.Tag{ID: t.ID, UserID: userID, Name: t.Name})
+		}
+
+		rev.Number = len(revisions) + 1
+		numbers[int64(rev.ID)] = rev.Number
+		if revertedFrom.Valid {
+			rev.RevertedFrom = numbers[revertedFrom.Int64]
+		}
+		if len(revisions) > 0 {
+			rev.Changes = diffRevisions(&revisions[len(revisions)-1], &rev)
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, rows.Err()
+}
+
+// diffRevisions は2つの版の間で変わった項目を、名前・所属・スコア・タグの順に返す。
+// 値がない項目は、所属とタグは (なし)、スコアは 未評価 と表示する。
+func diffRevisions(before, after *model.TalentRevision) []model.RevisionChange {
+	var changes []model.RevisionChange
+	add := func(field, b, a string) {
+		if b != a {
+			changes = append(changes, model.RevisionChange{Field: field, Before: b, After: a})
+		}
+	}
+
+	orNone := func(s string) string {
+		if s == "" {
+			return "(なし)"
+		}
+		return s
+	}
+
+	add("名前", before.Name, after.Name)
+	add("所属", orNone(before.Affiliation), orNone(after.Affiliation))
+
+	// 評価軸は変更後の版の順に並べ、変更後の版にない評価軸を最後に加える
+	dimensions := slices.Clone(after.Scores)
+	for _, s := range before.Scores {
+		if !slices.ContainsFunc(dimensions, func(d model.Score) bool { return d.DimensionID == s.DimensionID }) {
+			dimensions = append(dimensions, s)
+		}
+	}
+	score := func(scores []model.Score, dimensionID int) string {
+		for _, s := range scores {
+			if s.DimensionID == dimensionID {
+				return strconv.Itoa(s.Value)
+			}
+		}
+		return "未評価"
+	}
+	for _, d := range dimensions {
+		add(d.Name, score(before.Scores, d.DimensionID), score(after.Scores, d.DimensionID))
+	}
+
+	tagNames := func(tags []model.Tag) string {
+		names := make([]string, len(tags))
+		for i, t := range tags {
+			names[i] = t.Name
+		}
+		return strings.Join(names, "、")
+	}
+	add("タグ", orNone(tagNames(before.Tags)), orNone(tagNames(after.Tags)))
+
+	return changes
+}
@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"database/sql"
+	"slices"
+	"testing"
+
+	"github.com/Kamekure-Maisuke/maiyumi/model"
+)
+
+func TestTalentRepository_Revisions(t *testing.T) {
+	db, adjRepo := setupTalentTestDB(t)
+	defer db.Close()
+
+	repo := NewTalentRepository(db, adjRepo, NewScoreDimensionRepository(db))
+	tags := createTestTags(t, db, 1, "推し", "センター")
+
+	talent := &model.Talent{
+		UserID:      1,
+		Name:        "版テスト",
+		Affiliation: sql.NullString{String: "事務所A", Valid: true},
+		Scores:      testScores(1, 5, 6, 7),
+		Tags:        []model.Tag{tags["推し"]},
+	}
+	if err := repo.Create(talent); err != nil {
+		t.Fatal(err)
+	}
+	edited := &model.Talent{
+		ID:     talent.ID,
+		UserID: 1,
+		Name:   "版テスト改",
+		Scores: testScores(1, 5, 6, 9),
+		Tags:   []model.Tag{tags["推し"], tags["センター"]},
+	}
+	if err := repo.Update(edited); err != nil {
+		t.Fatal(err)
+	}
+	// 変更のない更新と、他のユーザーによる更新は版を増やさない
+	if err := repo.Update(edited); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Update(&model.Talent{ID: talent.ID, UserID: 2, Name: "他人"}); err != sql.ErrNoRows {
+		t.Fatalf("Update() with wrong user_id error = %v, want sql.ErrNoRows", err)
+	}
+
+	revisions, err := repo.FindRevisions(talent.ID, 1)
+	if err != nil {
+		t.Fatalf("FindRevisions() error = %v", err)
+	}
+	if len(revisions) != 2 {
+		t.Fatalf("FindRevisions() = %d revisions, want 2", len(revisions))
+	}
+	first := revisions[0]
+	if first.Number != 1 || first.Name != "版テスト" || first.Affiliation != "事務所A" || first.Changes != nil {
+		t.Errorf("FindRevisions()[0] = %+v", first)
+	}
+	if len(first.Scores) != 3 || first.Scores[2].Name != "才能" || first.Scores[2].Value != 7 {
+		t.Errorf("FindRevisions()[0].Scores = %+v", first.Scores)
+	}
+	wantChanges := []model.RevisionChange{
+		{Field: "名前", Before: "版テスト", After: "版テスト改"},
+		{Field: "所属", Before: "事務所A", After: "(なし)"},
+		{Field: "才能", Before: "7", After: "9"},
+		{Field: "タグ", Before: "推し", After: "センター、推し"},
+	}
+	if !slices.Equal(revisions[1].Changes, wantChanges) {
+		t.Errorf("FindRevisions()[1].Changes = %+v, want %+v", revisions[1].Changes, wantChanges)
+	}
+
+	if got, err := repo.FindRevisions(talent.ID, 2); err != nil || len(got) != 0 {
+		t.Errorf("FindRevisions() with wrong user_id = %+v, %v, want none", got, err)
+	}
+
+	t.Run("過去の版に戻す", func(t *testing.T) {
+		reverted := &model.Talent{
+			ID:          talent.ID,
+			UserID:      1,
+			Name:        first.Name,
+			Affiliation: sql.NullString{String: first.Affiliation, Valid: true},
+			Scores:      first.Scores,
+			Tags:        first.Tags,
+		}
+		if err := repo.Revert(reverted, first.ID); err != nil {
+			t.Fatalf("Revert() error = %v", err)
+		}
+
+		found, err := repo.FindByID(talent.ID, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found.Name != "版テスト" || found.Affiliation.String != "事務所A" || found.Scores[2].Value != 7 || len(found.Tags) != 1 {
+			t.Errorf("FindByID() after Revert() = %+v", found)
+		}
+
+		revisions, err := repo.FindRevisions(talent.ID, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(revisions) != 3 || revisions[2].RevertedFrom != 1 {
+			t.Errorf("FindRevisions() after Revert() = %+v, want 3 revisions reverted from #1", revisions)
+		}
+	})
+
+	t.Run("他のタレントの版には戻せない", func(t *testing.T) {
+		other := &model.Talent{UserID: 1, Name: "別のタレント", Scores: testScores(1, 1, 1, 1)}
+		if err := repo.Create(other); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.Revert(other, first.ID); err != sql.ErrNoRows {
+			t.Errorf("Revert() error = %v, want sql.ErrNoRows", err)
+		}
+	})
+}
+
+func TestBackfillTalentRevisions(t *testing.T) {
+	db, adjRepo := setupTalentTestDB(t)
+	defer db.Close()
+
+	repo := NewTalentRepository(db, adjRepo, NewScoreDimensionRepository(db))
+	talent := &model.Talent{UserID: 1, Name: "既存", Scores: testScores(1, 5, 5, 5)}
+	if err := repo.Create(talent); err != nil {
+		t.Fatal(err)
+	}
+	// 版を記録する前から登録されていたタレント
+	if _, err := db.Exec("DELETE FROM talent_revisions"); err != nil {
+		t.Fatal(err)
+	}
+
+	for range 2 {
+		if err := BackfillTalentRevisions(db); err != nil {
+			t.Fatalf("BackfillTalentRevisions() error = %v", err)
+		}
+	}
+
+	revisions, err := repo.FindRevisions(talent.ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 1 || revisions[0].Name != "既存" || len(revisions[0].Scores) != 3 {
+		t.Errorf("FindRevisions() = %+v, want 1 revision of the current talent", revisions)
+	}
+}
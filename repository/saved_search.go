@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/Kamekure-Maisuke/maiyumi/model"
+)
+
+type SavedSearchRepository interface {
+	Create(search *model.SavedSearch) error
+	Delete(id, userID int) error
+	FindByUserID(userID int) ([]model.SavedSearch, error)
+}
+
+var ErrDuplicateSavedSearchName = errors.New("同じ名前の保存した検索があります")
+
+type savedSearchRepository struct {
+	db *sql.DB
+}
+
+func NewSavedSearchRepository(db *sql.DB) SavedSearchRepository {
+	return &savedSearchRepository{db: db}
+}
+
+// Create は検索を保存する。名前が重複する場合は ErrDuplicateSavedSearchName を返す。
+func (r *savedSearchRepository) Create(search *model.SavedSearch) error {
+	result, err := r.db.Exec("INSERT INTO saved_searches (user_id, name, query) VALUES (?, ?, ?)",
+		search.UserID, search.Name, search.Query)
+	if isUniqueViolation(err) {
+		return ErrDuplicateSavedSearchName
+	}
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	search.ID = int(id)
+	return nil
+}
+
+func (r *savedSearchRepository) Delete(id, userID int) error {
+	result, err := r.db.Exec("DELETE FROM saved_searches WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// FindByUserID はユーザーの保存した検索を名前順に返す。
+func (r *savedSearchRepository) FindByUserID(userID int) ([]model.SavedSearch, error) {
+	rows, err := r.db.Query(`
+		SELECT id, user_id, name, query
+		FROM saved_searches
+		WHERE user_id = ?
+		ORDER BY name, id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var searches []model.SavedSearch
+	for rows.Next() {
+		var s model.SavedSearch
+		if err := rows.Scan(&s.ID, &s.UserID, &s.Name, &s.Query); err != nil {
+			return nil, err
+		}
+		searches = append(searches, s)
+	}
+	return searches, rows.Err()
+}
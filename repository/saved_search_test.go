@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/Kamekure-Maisuke/maiyumi/model"
+)
+
+func TestSavedSearchRepository(t *testing.T) {
+	db, _ := setupTalentTestDB(t)
+	defer db.Close()
+
+	repo := NewSavedSearchRepository(db)
+
+	tests := []struct {
+		name    string
+		search  model.SavedSearch
+		wantErr error
+	}{
+		{name: "新しい検索", search: model.SavedSearch{UserID: 1, Name: "才能8以上の推し", Query: "favorite=true&min_3=8"}, wantErr: nil},
+		{name: "別の名前の検索", search: model.SavedSearch{UserID: 1, Name: "乃木坂", Query: "affiliation=1"}, wantErr: nil},
+		{name: "同じユーザーで名前が重複", search: model.SavedSearch{UserID: 1, Name: "乃木坂", Query: "q=乃木坂"}, wantErr: ErrDuplicateSavedSearchName},
+		{name: "他のユーザーとは重複してよい", search: model.SavedSearch{UserID: 2, Name: "乃木坂", Query: "affiliation=2"}, wantErr: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			search := tt.search
+			err := repo.Create(&search)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Create() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && search.ID == 0 {
+				t.Errorf("Create() did not set ID")
+			}
+		})
+	}
+
+	searches, err := repo.FindByUserID(1)
+	if err != nil {
+		t.Fatalf("FindByUserID() error = %v", err)
+	}
+	want := []model.SavedSearch{
+		{ID: 2, UserID: 1, Name: "乃木坂", Query: "affiliation=1"},
+		{ID: 1, UserID: 1, Name: "才能8以上の推し", Query: "favorite=true&min_3=8"},
+	}
+	if len(searches) != len(want) {
+		t.Fatalf("FindByUserID() len = %d, want %d", len(searches), len(want))
+	}
+	for i := range want {
+		if searches[i] != want[i] {
+			t.Errorf("FindByUserID()[%d] = %+v, want %+v", i, searches[i], want[i])
+		}
+	}
+
+	if err := repo.Delete(1, 2); err != sql.ErrNoRows {
+		t.Errorf("Delete() with wrong user_id error = %v, want %v", err, sql.ErrNoRows)
+	}
+	if err := repo.Delete(1, 1); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	searches, err = repo.FindByUserID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(searches) != 1 || searches[0].ID != 2 {
+		t.Errorf("Delete() remaining = %+v, want only ID 2", searches)
+	}
+}
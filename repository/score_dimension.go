@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/Kamekure-Maisuke/maiyumi/model"
+)
+
+type ScoreDimensionRepository interface {
+	Create(d *model.ScoreDimension) error
+	Update(d *model.ScoreDimension) error
+	Delete(id, userID int) error
+	FindByID(id, userID int) (*model.ScoreDimension, error)
+	FindByUserID(userID int) ([]model.ScoreDimension, error)
+}
+
+var (
+	ErrDuplicateDimensionName = errors.New("同じ名前の評価軸があります")
+	ErrScoresOutOfRange       = errors.New("新しい範囲に収まらないスコアがあります")
+)
+
+type scoreDimensionRepository struct {
+	db *sql.DB
+}
+
+func NewScoreDimensionRepository(db *sql.DB) ScoreDimensionRepository {
+	return &scoreDimensionRepository{db: db}
+}
+
+// execer は *sql.DB と *sql.Tx の共通部分。
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// createDefaultScoreDimensions は model.DefaultScoreDimensions をユーザーの評価軸として登録する。
+func createDefaultScoreDimensions(db execer, userID int) error {
+	for _, d := range model.DefaultScoreDimensions {
+		_, err := db.Exec(`
+			INSERT INTO score_dimensions (user_id, name, min_score, max_score, display_order)
+			VALUES (?, ?, ?, ?, ?)`,
+			userID, d.Name, d.MinScore, d.MaxScore, d.DisplayOrder)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Create は評価軸を登録する。名前が重複する場合は ErrDuplicateDimensionName を返す。
+func (r *scoreDimensionRepository) Create(d *model.ScoreDimension) error {
+	result, err := r.db.Exec(`
+		INSERT INTO score_dimensions (user_id, name, min_score, max_score, display_order)
+		VALUES (?, ?, ?, ?, ?)`,
+		d.UserID, d.Name, d.MinScore, d.MaxScore, d.DisplayOrder)
+	if isUniqueViolation(err) {
+		return ErrDuplicateDimensionName
+	}
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	d.ID = int(id)
+	return nil
+}
+
+// Update は評価軸を更新する。評価済みのスコアが新しい範囲に収まらない場合は ErrScoresOutOfRange を返す。
+// 他のユーザーの評価軸の場合は、スコアの範囲を漏らさないよう範囲を確かめる前に sql.ErrNoRows を返す。
+func (r *scoreDimensionRepository) Update(d *model.ScoreDimension) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists int
+	err = tx.QueryRow("SELECT 1 FROM score_dimensions WHERE id = ? AND user_id = ?", d.ID, d.UserID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	var outOfRange int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM talent_scores
+		WHERE dimension_id = ? AND (score < ? OR score > ?)`,
+		d.ID, d.MinScore, d.MaxScore).Scan(&outOfRange)
+	if err != nil {
+		return err
+	}
+	if outOfRange > 0 {
+		return ErrScoresOutOfRange
+	}
+
+	result, err := tx.Exec(`
+		UPDATE score_dimensions
+		SET name = ?, min_score = ?, max_score = ?, display_order = ?
+		WHERE id = ? AND user_id = ?`,
+		d.Name, d.MinScore, d.MaxScore, d.DisplayOrder, d.ID, d.UserID)
+	if isUniqueViolation(err) {
+		return ErrDuplicateDimensionName
+	}
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return tx.Commit()
+}
+
+// Delete は評価軸と、その評価軸のスコア・調整履歴を削除する。削除した調整履歴は1件ずつ監査ログに記録する。
+func (r *scoreDimensionRepository) Delete(id, userID int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists int
+	err = tx.QueryRow("SELECT 1 FROM score_dimensions WHERE id = ? AND user_id = ?", id, userID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if err := recordAdjustmentDeletes(tx, actor(userID), "a.dimension_id = ?", id); err != nil {
+		return err
+	}
+
+	statements := []string{
+		"DELETE FROM talent_scores WHERE dimension_id = ?",
+		"DELETE FROM adjustments WHERE dimension_id = ?",
+		"DELETE FROM score_dimensions WHERE id = ?",
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt, id); err != nil {
+			return err
+		}
+	}
+	if err := indexPendingTalents(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *scoreDimensionRepository) FindByID(id, userID int) (*model.ScoreDimension, error) {
+	var d model.ScoreDimension
+	err := r.db.QueryRow(`
+		SELECT id, user_id, name, min_score, max_score, display_order
+		FROM score_dimensions
+		WHERE id = ? AND user_id = ?`, id, userID).Scan(
+		&d.ID, &d.UserID, &d.Name, &d.MinScore, &d.MaxScore, &d.DisplayOrder)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// FindByUserID はユーザーの評価軸を表示順に返す。
+func (r *scoreDimensionRepository) FindByUserID(userID int) ([]model.ScoreDimension, error) {
+	rows, err := r.db.Query(`
+		SELECT id, user_id, name, min_score, max_score, display_order
+		FROM score_dimensions
+		WHERE user_id = ?
+		ORDER BY display_order, id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var dimensions []model.ScoreDimension
+	for rows.Next() {
+		var d model.ScoreDimension
+		if err := rows.Scan(&d.ID, &d.UserID, &d.Name, &d.MinScore, &d.MaxScore, &d.DisplayOrder); err != nil {
+			return nil, err
+		}
+		dimensions = append(dimensions, d)
+	}
+	return dimensions, rows.Err()
+}
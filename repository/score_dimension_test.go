@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/Kamekure-Maisuke/maiyumi/model"
+)
+
+func TestScoreDimensionRepository_CreateAndFind(t *testing.T) {
+	db, _ := setupTalentTestDB(t)
+	defer db.Close()
+
+	repo := NewScoreDimensionRepository(db)
+
+	tests := []struct {
+		name      string
+		dimension model.ScoreDimension
+		wantErr   error
+	}{
+		{name: "新しい評価軸", dimension: model.ScoreDimension{UserID: 1, Name: "歌唱力", MinScore: 0, MaxScore: 100, DisplayOrder: 0}, wantErr: nil},
+		{name: "同じユーザーで名前が重複", dimension: model.ScoreDimension{UserID: 1, Name: "美しさ", MinScore: 1, MaxScore: 5}, wantErr: ErrDuplicateDimensionName},
+		{name: "他のユーザーとは重複してよい", dimension: model.ScoreDimension{UserID: 2, Name: "歌唱力", MinScore: 1, MaxScore: 5}, wantErr: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := tt.dimension
+			err := repo.Create(&d)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Create() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && d.ID == 0 {
+				t.Errorf("Create() did not set ID")
+			}
+		})
+	}
+
+	dimensions, err := repo.FindByUserID(1)
+	if err != nil {
+		t.Fatalf("FindByUserID() error = %v", err)
+	}
+	wantNames := []string{"歌唱力", "美しさ", "可愛さ", "才能"}
+	if len(dimensions) != len(wantNames) {
+		t.Fatalf("FindByUserID() len = %d, want %d", len(dimensions), len(wantNames))
+	}
+	for i, d := range dimensions {
+		if d.Name != wantNames[i] {
+			t.Errorf("FindByUserID()[%d].Name = %v, want %v", i, d.Name, wantNames[i])
+		}
+	}
+
+	if _, err := repo.FindByID(1, 2); err != sql.ErrNoRows {
+		t.Errorf("FindByID() with wrong user_id error = %v, want %v", err, sql.ErrNoRows)
+	}
+}
+
+func TestScoreDimensionRepository_Update(t *testing.T) {
+	db, adjRepo := setupTalentTestDB(t)
+	defer db.Close()
+
+	repo := NewScoreDimensionRepository(db)
+	talentRepo := NewTalentRepository(db, adjRepo, repo)
+	if err := talentRepo.Create(&model.Talent{UserID: 1, Name: "タレント", Scores: testScores(1, 8, 5, 5)}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		dimension model.ScoreDimension
+		wantErr   error
+	}{
+		{name: "範囲を広げる", dimension: model.ScoreDimension{ID: 1, UserID: 1, Name: "ビジュアル", MinScore: 0, MaxScore: 100, DisplayOrder: 5}, wantErr: nil},
+		{name: "評価済みのスコアが範囲外になる", dimension: model.ScoreDimension{ID: 1, UserID: 1, Name: "ビジュアル", MinScore: 1, MaxScore: 5}, wantErr: ErrScoresOutOfRange},
+		{name: "名前が他の評価軸と重複", dimension: model.ScoreDimension{ID: 1, UserID: 1, Name: "可愛さ", MinScore: 1, MaxScore: 10}, wantErr: ErrDuplicateDimensionName},
+		{name: "他のユーザーの評価軸", dimension: model.ScoreDimension{ID: 1, UserID: 2, Name: "乗っ取り", MinScore: 1, MaxScore: 10}, wantErr: sql.ErrNoRows},
+		{name: "他のユーザーの評価軸はスコアが範囲外でも見つからない", dimension: model.ScoreDimension{ID: 1, UserID: 2, Name: "乗っ取り", MinScore: 1, MaxScore: 5}, wantErr: sql.ErrNoRows},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := tt.dimension
+			if err := repo.Update(&d); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Update() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	got, err := repo.FindByID(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "ビジュアル" || got.MinScore != 0 || got.MaxScore != 100 || got.DisplayOrder != 5 {
+		t.Errorf("Update() result = %+v", got)
+	}
+}
+
+func TestScoreDimensionRepository_Delete(t *testing.T) {
+	db, adjRepo := setupTalentTestDB(t)
+	defer db.Close()
+
+	repo := NewScoreDimensionRepository(db)
+	talentRepo := NewTalentRepository(db, adjRepo, repo)
+	talent := &model.Talent{UserID: 1, Name: "タレント", Scores: testScores(1, 5, 5, 5)}
+	if err := talentRepo.Create(talent); err != nil {
+		t.Fatal(err)
+	}
+	for _, dimensionID := range []int{1, 2} {
+		if err := adjRepo.Create(&model.Adjustment{TalentID: talent.ID, DimensionID: dimensionID, Points: 1, Reason: "加点"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := repo.Delete(1, 2); err != sql.ErrNoRows {
+		t.Errorf("Delete() with wrong user_id error = %v, want %v", err, sql.ErrNoRows)
+	}
+	if err := repo.Delete(1, 1); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	counts := []struct {
+		name  string
+		query string
+		want  int
+	}{
+		{name: "score_dimensions", query: "SELECT COUNT(*) FROM score_dimensions WHERE user_id = 1", want: 2},
+		{name: "talent_scores", query: "SELECT COUNT(*) FROM talent_scores", want: 2},
+		{name: "adjustments", query: "SELECT COUNT(*) FROM adjustments", want: 1},
+	}
+	for _, c := range counts {
+		var got int
+		if err := db.QueryRow(c.query).Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want {
+			t.Errorf("Delete() remaining %s = %d, want %d", c.name, got, c.want)
+		}
+	}
+
+	// 評価軸と合わせて削除した調整履歴も監査ログに残す
+	events, err := NewAuditRepository(db).Find(AuditFilter{UserID: 1, EntityType: model.AuditEntityAdjustment, Action: model.AuditActionDelete})
+	if err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+	if len(events) != 1 || events[0].EntityID != 1 || !events[0].ActorID.Valid {
+		t.Errorf("Find() adjustment deletes = %+v, want adjustment #1 deleted by the user", events)
+	}
+}
@@ -0,0 +1,309 @@
+package repository
+
+import (
+	"database/sql"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// TalentSearchSchema はタレントの全文検索の索引と、索引を更新するタレントを記録するトリガー。
+// 索引には名前・所属名・調整理由を SearchKey で正規化して入れ、rowid はタレントのIDにする。
+// 日本語は単語で区切れないため、3文字ずつに分けて部分一致で探せる trigram を使う。
+//
+// 正規化は Go で行うため、トリガーは変更のあったタレントのIDを talents_fts_pending に記録するだけにして、
+// タレント・調整履歴を書き換えるトランザクションの中で indexPendingTalents を呼んで索引に反映する。
+// 検索は索引を読むだけで書き込まない。トリガーを素のSQLに保つことで、sqlite3 コマンドなど
+// アプリ以外からDBを書き換えても失敗せず、次にアプリが書き換えたときか起動したときに索引に反映される。
+// 古いトリガーは正規化の関数を呼んでいたため、作り直す。
+const TalentSearchSchema = `
+	CREATE VIRTUAL TABLE IF NOT EXISTS talents_fts USING fts5(name, affiliation, reasons, tokenize = 'trigram');
+	CREATE TABLE IF NOT EXISTS talents_fts_pending (talent_id INTEGER PRIMARY KEY);
+
+	DROP TRIGGER IF EXISTS talents_fts_insert;
+	CREATE TRIGGER talents_fts_insert AFTER INSERT ON talents BEGIN
+		INSERT OR IGNORE INTO talents_fts_pending (talent_id) VALUES (new.id);
+	END;
+	DROP TRIGGER IF EXISTS talents_fts_update;
+	CREATE TRIGGER talents_fts_update AFTER UPDATE OF name, affiliation_id ON talents BEGIN
+		INSERT OR IGNORE INTO talents_fts_pending (talent_id) VALUES (new.id);
+	END;
+	DROP TRIGGER IF EXISTS talents_fts_delete;
+	CREATE TRIGGER talents_fts_delete AFTER DELETE ON talents BEGIN
+		DELETE FROM talents_fts WHERE rowid = old.id;
+		DELETE FROM talents_fts_pending WHERE talent_id = old.id;
+	END;
+
+	DROP TRIGGER IF EXISTS affiliations_fts_update;
+	CREATE TRIGGER affiliations_fts_update AFTER UPDATE OF name ON affiliations BEGIN
+		INSERT OR IGNORE INTO talents_fts_pending (talent_id)
+		SELECT id FROM talents WHERE affiliation_id = new.id;
+	END;
+
+	DROP TRIGGER IF EXISTS adjustments_fts_insert;
+	CREATE TRIGGER adjustments_fts_insert AFTER INSERT ON adjustments BEGIN
+		INSERT OR IGNORE INTO talents_fts_pending (talent_id) VALUES (new.talent_id);
+	END;
+	DROP TRIGGER IF EXISTS adjustments_fts_update;
+	CREATE TRIGGER adjustments_fts_update AFTER UPDATE OF reason, talent_id ON adjustments BEGIN
+		INSERT OR IGNORE INTO talents_fts_pending (talent_id) VALUES (old.talent_id), (new.talent_id);
+	END;
+	DROP TRIGGER IF EXISTS adjustments_fts_delete;
+	CREATE TRIGGER adjustments_fts_delete AFTER DELETE ON adjustments BEGIN
+		INSERT OR IGNORE INTO talents_fts_pending (talent_id) VALUES (old.talent_id);
+	END;
+`
+
+// RebuildTalentSearchIndex は全文検索の索引をタレントの現在の内容で作り直す。
+func RebuildTalentSearchIndex(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		"DELETE FROM talents_fts",
+		"DELETE FROM talents_fts_pending",
+		"INSERT INTO talents_fts_pending (talent_id) SELECT id FROM talents",
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	if err := indexPendingTalents(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SyncTalentSearchIndex はアプリ以外からの変更でトリガーが記録したタレントを全文検索の索引に反映する。
+// 起動時に呼ぶ。記録がない場合は書き込みをしない。
+func SyncTalentSearchIndex(db *sql.DB) error {
+	var pending bool
+	if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM talents_fts_pending)").Scan(&pending); err != nil {
+		return err
+	}
+	if !pending {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := indexPendingTalents(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// indexPendingTalents は talents_fts_pending のタレントの索引を、正規化した現在の内容で入れ替えて記録を消す。
+// 索引が書き換えた内容と食い違わないよう、タレント・調整履歴を書き換えたトランザクションの中で呼ぶ。
+func indexPendingTalents(tx *sql.Tx) error {
+	rows, err := tx.Query(`
+		SELECT t.id, t.name, COALESCE(a.name, ''),
+			COALESCE((SELECT group_concat(reason, ' ') FROM adjustments WHERE talent_id = t.id), '')
+		FROM talents_fts_pending p
+		JOIN talents t ON t.id = p.talent_id
+		LEFT JOIN affiliations a ON a.id = t.affiliation_id`)
+	if err != nil {
+		return err
+	}
+	type document struct {
+		id                         int
+		name, affiliation, reasons string
+	}
+	var documents []document
+	for rows.Next() {
+		var d document
+		if err := rows.Scan(&d.id, &d.name, &d.affiliation, &d.reasons); err != nil {
+			rows.Close()
+			return err
+		}
+		documents = append(documents, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM talents_fts WHERE rowid IN (SELECT talent_id FROM talents_fts_pending)"); err != nil {
+		return err
+	}
+	for _, d := range documents {
+		_, err := tx.Exec("INSERT INTO talents_fts (rowid, name, affiliation, reasons) VALUES (?, ?, ?, ?)",
+			d.id, SearchKey(d.name), SearchKey(d.affiliation), SearchKey(d.reasons))
+		if err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec("DELETE FROM talents_fts_pending")
+	return err
+}
+
+// SearchKey は検索で表記ゆれを区別しないように文字列を正規化する。
+// NFKC正規化で全角英数字と半角カタカナをそろえ、カタカナをひらがなに、英字を小文字にする。
+// ローマ字とかなは同じものとして扱わない（「kato」で「かとう」は見つからない）。
+func SearchKey(s string) string {
+	return foldKana(norm.NFKC.String(s))
+}
+
+// foldKana はカタカナをひらがなに、英字を小文字にする。
+func foldKana(s string) string {
+	return strings.Map(func(r rune) rune {
+		// ァ(U+30A1)〜ヶ(U+30F6) はひらがなの ぁ(U+3041)〜ゖ(U+3096) に対応する
+		if r >= 'ァ' && r <= 'ヶ' {
+			return r - 'ァ' + 'ぁ'
+		}
+		return unicode.ToLower(r)
+	}, s)
+}
+
+// searchTerms は検索語を空白で区切り、正規化した語の一覧を返す。
+func searchTerms(query string) []string {
+	var terms []string
+	for _, term := range strings.Fields(SearchKey(query)) {
+		if !slices.Contains(terms, term) {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// minTrigramTerm は trigram の索引で探せる語の最小の文字数。
+const minTrigramTerm = 3
+
+// searchMatch は索引を使って探せる語を FTS5 の MATCH の式にする。どの語も短すぎる場合は空を返す。
+func searchMatch(terms []string) string {
+	var phrases []string
+	for _, term := range terms {
+		if utf8.RuneCountInString(term) >= minTrigramTerm {
+			phrases = append(phrases, `"`+strings.ReplaceAll(term, `"`, `""`)+`"`)
+		}
+	}
+	return strings.Join(phrases, " AND ")
+}
+
+// searchCondition は検索語をすべて含むタレントに絞り込む条件を返す。
+// 3文字未満の語は索引で探せないため、索引の各列に含まれるかを1件ずつ調べる。
+func searchCondition(terms []string) (string, []any) {
+	where := []string{}
+	var args []any
+	if match := searchMatch(terms); match != "" {
+		where = append(where, "talents_fts MATCH ?")
+		args = append(args, match)
+	}
+	for _, term := range terms {
+		if utf8.RuneCountInString(term) < minTrigramTerm {
+			where = append(where, "(instr(name, ?) > 0 OR instr(affiliation, ?) > 0 OR instr(reasons, ?) > 0)")
+			args = append(args, term, term, term)
+		}
+	}
+	return "t.id IN (SELECT rowid FROM talents_fts WHERE " + strings.Join(where, " AND ") + ")", args
+}
+
+// 検索結果の抜粋の元になった項目。
+const (
+	SnippetName        = "name"
+	SnippetAffiliation = "affiliation"
+	SnippetReason      = "reason"
+)
+
+// snippetContext は抜粋で最初に一致した部分の前に残す文字数。
+const snippetContext = 10
+
+// snippetLength は抜粋のおおよその最大の文字数。一致した部分は途中で切らない。
+const snippetLength = 40
+
+// Snippet は検索語に一致した項目の抜粋。
+type Snippet struct {
+	Field string
+	Parts []SnippetPart
+}
+
+// SnippetPart は抜粋の一部分。Match が true の部分が検索語に一致している。
+type SnippetPart struct {
+	Text  string
+	Match bool
+}
+
+// highlight は text のうち検索語に一致する部分を区切って返す。一致しない場合は nil を返す。
+// 正規化の前後で文字数が変わっても元の文字列の位置で区切れるよう、正規化の単位ごとに元の範囲を記録して探す。
+func highlight(text string, terms []string) []SnippetPart {
+	var folded strings.Builder
+	var sources [][2]int // folded のバイトごとの、元の文字列での範囲
+	var it norm.Iter
+	it.InitString(norm.NFKC, text)
+	for !it.Done() {
+		start := it.Pos()
+		segment := foldKana(string(it.Next()))
+		for range len(segment) {
+			sources = append(sources, [2]int{start, it.Pos()})
+		}
+		folded.WriteString(segment)
+	}
+
+	key := folded.String()
+	matched := make([]bool, len(text))
+	found := false
+	for _, term := range terms {
+		for offset := 0; ; {
+			i := strings.Index(key[offset:], term)
+			if i < 0 {
+				break
+			}
+			begin, end := offset+i, offset+i+len(term)
+			for b := sources[begin][0]; b < sources[end-1][1]; b++ {
+				matched[b] = true
+			}
+			found = true
+			offset = begin + 1
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	var parts []SnippetPart
+	for i := 0; i < len(text); {
+		j := i
+		for j < len(text) && matched[j] == matched[i] {
+			j++
+		}
+		parts = append(parts, SnippetPart{Text: text[i:j], Match: matched[i]})
+		i = j
+	}
+	return trimSnippet(parts)
+}
+
+// trimSnippet は長い文章の抜粋を、最初に一致した部分の前後だけに縮める。
+func trimSnippet(parts []SnippetPart) []SnippetPart {
+	first := 0
+	for !parts[first].Match {
+		first++
+	}
+	if first > 0 {
+		if runes := []rune(parts[first-1].Text); len(runes) > snippetContext {
+			parts[first-1].Text = "…" + string(runes[len(runes)-snippetContext:])
+		}
+		parts = parts[first-1:]
+	}
+
+	length := 0
+	for i, part := range parts {
+		runes := []rune(part.Text)
+		if !part.Match && length+len(runes) > snippetLength {
+			parts[i].Text = string(runes[:max(snippetLength-length, 0)]) + "…"
+			return parts[:i+1]
+		}
+		length += len(runes)
+	}
+	return parts
+}
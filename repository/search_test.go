@@ -0,0 +1,245 @@
+package repository
+
+import (
+	"database/sql"
+	"slices"
+	"testing"
+
+	"github.com/Kamekure-Maisuke/maiyumi/model"
+)
+
+func TestSearchKey(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "カタカナはひらがなにする", input: "アイドル", want: "あいどる"},
+		{name: "半角カタカナの濁点をまとめる", input: "ｶﾞｰﾙｽﾞ", want: "がーるず"},
+		{name: "全角英数字は半角の小文字にする", input: "ＡＫＢ４８", want: "akb48"},
+		{name: "漢字とひらがなはそのまま", input: "乃木坂の", want: "乃木坂の"},
+		{name: "全角の空白は半角にする", input: "白石　麻衣", want: "白石 麻衣"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SearchKey(tt.input); got != tt.want {
+				t.Errorf("SearchKey(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		terms []string
+		want  []SnippetPart
+	}{
+		{
+			name:  "一致した部分を区切る",
+			text:  "山田花子",
+			terms: []string{"花子"},
+			want:  []SnippetPart{{Text: "山田"}, {Text: "花子", Match: true}},
+		},
+		{
+			name:  "カタカナと半角カタカナは元の表記のまま区切る",
+			text:  "ｶﾞｰﾙｽﾞバー",
+			terms: []string{"がーる", "ばー"},
+			want:  []SnippetPart{{Text: "ｶﾞｰﾙ", Match: true}, {Text: "ｽﾞ"}, {Text: "バー", Match: true}},
+		},
+		{
+			name:  "長い文章は一致した部分の前後に縮める",
+			text:  "あいうえおかきくけこさしすせそたちつてと歌唱力なにぬねのはひふへほまみむめもやゆよらりるれろわをんアイウエオカキクケコ",
+			terms: []string{"歌唱力"},
+			want: []SnippetPart{
+				{Text: "…さしすせそたちつてと"},
+				{Text: "歌唱力", Match: true},
+				{Text: "なにぬねのはひふへほまみむめもやゆよらりるれろわをん…"},
+			},
+		},
+		{
+			name:  "一致しない",
+			text:  "山田花子",
+			terms: []string{"太郎"},
+			want:  nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := highlight(tt.text, tt.terms); !slices.Equal(got, tt.want) {
+				t.Errorf("highlight(%q, %q) = %+v, want %+v", tt.text, tt.terms, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTalentRepository_FullTextSearch(t *testing.T) {
+	db, adjRepo := setupTalentTestDB(t)
+	defer db.Close()
+
+	repo := NewTalentRepository(db, adjRepo, NewScoreDimensionRepository(db))
+
+	talents := []*model.Talent{
+		{UserID: 1, Name: "カトウ ミク", Affiliation: sql.NullString{String: "ＡＢＣプロダクション", Valid: true}, Scores: testScores(1, 5, 5, 5)},
+		{UserID: 1, Name: "佐藤花子", Scores: testScores(1, 5, 5, 5)},
+		{UserID: 1, Name: "かとうみくの妹", Scores: testScores(1, 5, 5, 5)},
+		{UserID: 2, Name: "かとうみく", Scores: testScores(4, 5, 5, 5)},
+	}
+	for _, talent := range talents {
+		if err := repo.Create(talent); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := adjRepo.Create(&model.Adjustment{TalentID: talents[1].ID, DimensionID: 1, Points: 1, Reason: "ライブでのダンスパフォーマンス"}); err != nil {
+		t.Fatal(err)
+	}
+
+	search := func(t *testing.T, query string) *TalentPage {
+		t.Helper()
+		page, err := repo.FindPage(1, TalentQuery{Filter: TalentFilter{Search: query}, Sort: TalentSort{Column: SortRelevance}})
+		if err != nil {
+			t.Fatalf("FindPage(Search: %q) error = %v", query, err)
+		}
+		return page
+	}
+	names := func(page *TalentPage) []string {
+		var names []string
+		for _, talent := range page.Talents {
+			names = append(names, talent.Name)
+		}
+		return names
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{name: "ひらがなでカタカナの名前を探す", query: "かとう", want: []string{"かとうみくの妹", "カトウ ミク"}},
+		{name: "半角カタカナで探す", query: "ｶﾄｳ", want: []string{"かとうみくの妹", "カトウ ミク"}},
+		{name: "全角英字の所属を小文字で探す", query: "abcプロ", want: []string{"カトウ ミク"}},
+		{name: "調整理由を探す", query: "ダンス", want: []string{"佐藤花子"}},
+		{name: "索引で探せない短い語", query: "妹", want: []string{"かとうみくの妹"}},
+		{name: "複数の語はすべてを含む", query: "かとう 妹", want: []string{"かとうみくの妹"}},
+		{name: "記号を含む語", query: `"かとう`, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 関連度が同じ程度の場合の順序は決めないため、名前順で比べる
+			got := names(search(t, tt.query))
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("FindPage(Search: %q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("名前の一致を調整理由の一致より上にする", func(t *testing.T) {
+		if err := adjRepo.Create(&model.Adjustment{TalentID: talents[1].ID, DimensionID: 1, Points: 1, Reason: "カトウさんと共演"}); err != nil {
+			t.Fatal(err)
+		}
+		got := names(search(t, "かとう"))
+		if len(got) != 3 || got[2] != "佐藤花子" {
+			t.Errorf("FindPage(Search: かとう) = %v, want 佐藤花子 last", got)
+		}
+	})
+
+	t.Run("抜粋", func(t *testing.T) {
+		page := search(t, "だんす")
+		snippets := page.Snippets[talents[1].ID]
+		if len(snippets) != 1 || snippets[0].Field != SnippetReason {
+			t.Fatalf("FindPage() Snippets = %+v, want one reason snippet", snippets)
+		}
+		want := []SnippetPart{{Text: "ライブでの"}, {Text: "ダンス", Match: true}, {Text: "パフォーマンス"}}
+		if !slices.Equal(snippets[0].Parts, want) {
+			t.Errorf("FindPage() snippet = %+v, want %+v", snippets[0].Parts, want)
+		}
+	})
+
+	t.Run("更新と削除で索引が追従する", func(t *testing.T) {
+		talents[1].Name = "サトウ ハナコ"
+		talents[1].Affiliation = sql.NullString{String: "XYZ事務所", Valid: true}
+		if err := repo.Update(talents[1]); err != nil {
+			t.Fatal(err)
+		}
+		if got := names(search(t, "はなこ xyz")); !slices.Equal(got, []string{"サトウ ハナコ"}) {
+			t.Errorf("FindPage() after Update = %v", got)
+		}
+
+		adjustments, err := adjRepo.FindByTalentID(talents[1].ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, adj := range adjustments {
+			if err := adjRepo.Delete(adj.ID, 1); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := names(search(t, "ダンス")); got != nil {
+			t.Errorf("FindPage() after deleting adjustments = %v, want none", got)
+		}
+
+		if err := repo.Delete(talents[0].ID, 1); err != nil {
+			t.Fatal(err)
+		}
+		if got := names(search(t, "abcプロ")); got != nil {
+			t.Errorf("FindPage() after moving to trash = %v, want none", got)
+		}
+		if err := repo.DeletePermanently(talents[0].ID, 1); err != nil {
+			t.Fatal(err)
+		}
+		var count int
+		if err := db.QueryRow("SELECT COUNT(*) FROM talents_fts WHERE rowid = ?", talents[0].ID).Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		if count != 0 {
+			t.Errorf("DeletePermanently() left %d rows in the search index", count)
+		}
+	})
+
+	t.Run("検索は索引を書き換えず、アプリ以外からの変更は同期で反映する", func(t *testing.T) {
+		// トリガーは素のSQLだけで動くため、正規化の関数がなくても書き込める
+		if _, err := db.Exec(TalentSearchSchema); err != nil {
+			t.Fatalf("TalentSearchSchema re-run error = %v", err)
+		}
+		if _, err := db.Exec("UPDATE talents SET name = 'ﾀﾅｶ ﾊﾅｺ' WHERE id = ?", talents[1].ID); err != nil {
+			t.Fatal(err)
+		}
+		if got := names(search(t, "たなか")); got != nil {
+			t.Errorf("FindPage() before sync = %v, want none", got)
+		}
+		var pending int
+		if err := db.QueryRow("SELECT COUNT(*) FROM talents_fts_pending").Scan(&pending); err != nil {
+			t.Fatal(err)
+		}
+		if pending != 1 {
+			t.Errorf("talents_fts_pending after search = %d rows, want 1", pending)
+		}
+
+		if err := SyncTalentSearchIndex(db); err != nil {
+			t.Fatalf("SyncTalentSearchIndex() error = %v", err)
+		}
+		if got := names(search(t, "たなか")); !slices.Equal(got, []string{"ﾀﾅｶ ﾊﾅｺ"}) {
+			t.Errorf("FindPage() after sync = %v", got)
+		}
+		if err := db.QueryRow("SELECT COUNT(*) FROM talents_fts_pending").Scan(&pending); err != nil {
+			t.Fatal(err)
+		}
+		if pending != 0 {
+			t.Errorf("talents_fts_pending after sync = %d rows, want 0", pending)
+		}
+	})
+
+	t.Run("索引の作り直し", func(t *testing.T) {
+		if err := RebuildTalentSearchIndex(db); err != nil {
+			t.Fatalf("RebuildTalentSearchIndex() error = %v", err)
+		}
+		if got := names(search(t, "かとう")); !slices.Equal(got, []string{"かとうみくの妹"}) {
+			t.Errorf("FindPage() after rebuild = %v", got)
+		}
+	})
+}
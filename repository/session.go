@@ -1,39 +1,250 @@
 package repository
 
-import "sync"
+import (
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Kamekure-Maisuke/maiyumi/model"
+)
+
+// sqliteTimeLayout は CURRENT_TIMESTAMP と同じ形式。文字列比較で大小関係を判定できる。
+const sqliteTimeLayout = "2006-01-02 15:04:05"
 
 type SessionRepository interface {
-	Set(sessionID, username string)
-	Get(sessionID string) (string, bool)
-	Delete(sessionID string)
+	Create(session *model.Session) error
+	Get(sessionID string) (*model.Session, bool)
+	Delete(sessionID string) error
+	FindByUserID(userID int) ([]model.Session, error)
+	DeleteByUserID(userID int, exceptSessionID string) error
+	DeleteExpired() (int, error)
+}
+
+// SessionOptions はセッションの有効期限の設定。ゼロ値の項目は判定に使わない。
+type SessionOptions struct {
+	IdleTimeout time.Duration // 最終アクセスからの有効期間
+	MaxLifetime time.Duration // 作成からの最大有効期間
+	Sliding     bool          // Get のたびに最終アクセス時刻を更新する
+}
+
+func (o SessionOptions) expired(createdAt, lastSeenAt, now time.Time) bool {
+	if o.IdleTimeout > 0 && now.Sub(lastSeenAt) > o.IdleTimeout {
+		return true
+	}
+	if o.MaxLifetime > 0 && now.Sub(createdAt) > o.MaxLifetime {
+		return true
+	}
+	return false
 }
 
+// sessionRepository はメモリ上にセッションを保持する。
+// サーバー再起動で消えるため、主にテスト用途で使う。
 type sessionRepository struct {
-	sessions map[string]string
+	sessions map[string]*model.Session
+	opts     SessionOptions
+	now      func() time.Time
 	mu       sync.RWMutex
 }
 
-func NewSessionRepository() SessionRepository {
+func NewSessionRepository(opts SessionOptions) SessionRepository {
 	return &sessionRepository{
-		sessions: make(map[string]string),
+		sessions: make(map[string]*model.Session),
+		opts:     opts,
+		now:      time.Now,
+	}
+}
+
+func (r *sessionRepository) Create(session *model.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	session.CreatedAt = now
+	session.LastSeenAt = now
+	stored := *session
+	r.sessions[session.ID] = &stored
+	return nil
+}
+
+func (r *sessionRepository) Get(sessionID string) (*model.Session, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.sessions[sessionID]
+	if !ok {
+		return nil, false
+	}
+
+	now := r.now()
+	if r.opts.expired(session.CreatedAt, session.LastSeenAt, now) {
+		delete(r.sessions, sessionID)
+		return nil, false
 	}
+	if r.opts.Sliding {
+		session.LastSeenAt = now
+	}
+	found := *session
+	return &found, true
 }
 
-func (r *sessionRepository) Set(sessionID, username string) {
+func (r *sessionRepository) Delete(sessionID string) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	r.sessions[sessionID] = username
+	delete(r.sessions, sessionID)
+	return nil
 }
 
-func (r *sessionRepository) Get(sessionID string) (string, bool) {
+// FindByUserID は期限内のセッションを最終アクセスの新しい順に返す。
+func (r *sessionRepository) FindByUserID(userID int) ([]model.Session, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	username, ok := r.sessions[sessionID]
-	return username, ok
+	now := r.now()
+	var sessions []model.Session
+	for _, session := range r.sessions {
+		if session.UserID == userID && !r.opts.expired(session.CreatedAt, session.LastSeenAt, now) {
+			sessions = append(sessions, *session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+	return sessions, nil
 }
 
-func (r *sessionRepository) Delete(sessionID string) {
+// DeleteByUserID はユーザーのセッションを exceptSessionID 以外すべて削除する。
+func (r *sessionRepository) DeleteByUserID(userID int, exceptSessionID string) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	delete(r.sessions, sessionID)
+	for id, session := range r.sessions {
+		if session.UserID == userID && id != exceptSessionID {
+			delete(r.sessions, id)
+		}
+	}
+	return nil
+}
+
+func (r *sessionRepository) DeleteExpired() (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	deleted := 0
+	for id, session := range r.sessions {
+		if r.opts.expired(session.CreatedAt, session.LastSeenAt, now) {
+			delete(r.sessions, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// sqliteSessionRepository は sessions テーブルにセッションを永続化する。
+type sqliteSessionRepository struct {
+	db   *sql.DB
+	opts SessionOptions
+	now  func() time.Time
+}
+
+func NewSQLiteSessionRepository(db *sql.DB, opts SessionOptions) SessionRepository {
+	return &sqliteSessionRepository{db: db, opts: opts, now: time.Now}
+}
+
+func (r *sqliteSessionRepository) formatTime(t time.Time) string {
+	return t.UTC().Format(sqliteTimeLayout)
+}
+
+// expiryCondition は期限内のセッションに一致するWHERE句の条件と引数を返す。
+func (r *sqliteSessionRepository) expiryCondition(now time.Time) (string, []any) {
+	cond := "1 = 1"
+	var args []any
+	if r.opts.IdleTimeout > 0 {
+		cond += " AND last_seen_at >= ?"
+		args = append(args, r.formatTime(now.Add(-r.opts.IdleTimeout)))
+	}
+	if r.opts.MaxLifetime > 0 {
+		cond += " AND created_at >= ?"
+		args = append(args, r.formatTime(now.Add(-r.opts.MaxLifetime)))
+	}
+	return cond, args
+}
+
+func (r *sqliteSessionRepository) Create(session *model.Session) error {
+	now := r.now().UTC().Truncate(time.Second)
+	_, err := r.db.Exec(`
+		INSERT INTO sessions (id, user_id, user_agent, ip_address, csrf_token, created_at, last_seen_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		session.ID, session.UserID, session.UserAgent, session.IPAddress, session.CSRFToken, r.formatTime(now), r.formatTime(now))
+	if err != nil {
+		return err
+	}
+	session.CreatedAt = now
+	session.LastSeenAt = now
+	return nil
+}
+
+func (r *sqliteSessionRepository) Get(sessionID string) (*model.Session, bool) {
+	now := r.now()
+	cond, args := r.expiryCondition(now)
+
+	var s model.Session
+	err := r.db.QueryRow(`
+		SELECT id, user_id, user_agent, ip_address, csrf_token, created_at, last_seen_at
+		FROM sessions
+		WHERE id = ? AND `+cond,
+		append([]any{sessionID}, args...)...).Scan(
+		&s.ID, &s.UserID, &s.UserAgent, &s.IPAddress, &s.CSRFToken, &s.CreatedAt, &s.LastSeenAt)
+	if err != nil {
+		return nil, false
+	}
+
+	if r.opts.Sliding {
+		if _, err := r.db.Exec("UPDATE sessions SET last_seen_at = ? WHERE id = ?", r.formatTime(now), sessionID); err == nil {
+			s.LastSeenAt = now.UTC().Truncate(time.Second)
+		}
+	}
+	return &s, true
+}
+
+func (r *sqliteSessionRepository) Delete(sessionID string) error {
+	_, err := r.db.Exec("DELETE FROM sessions WHERE id = ?", sessionID)
+	return err
+}
+
+// FindByUserID は期限内のセッションを最終アクセスの新しい順に返す。
+func (r *sqliteSessionRepository) FindByUserID(userID int) ([]model.Session, error) {
+	cond, args := r.expiryCondition(r.now())
+	rows, err := r.db.Query(`
+		SELECT id, user_id, user_agent, ip_address, csrf_token, created_at, last_seen_at
+		FROM sessions
+		WHERE user_id = ? AND `+cond+`
+		ORDER BY last_seen_at DESC`,
+		append([]any{userID}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []model.Session
+	for rows.Next() {
+		var s model.Session
+		if err := rows.Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IPAddress, &s.CSRFToken, &s.CreatedAt, &s.LastSeenAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// DeleteByUserID はユーザーのセッションを exceptSessionID 以外すべて削除する。
+func (r *sqliteSessionRepository) DeleteByUserID(userID int, exceptSessionID string) error {
+	_, err := r.db.Exec("DELETE FROM sessions WHERE user_id = ? AND id != ?", userID, exceptSessionID)
+	return err
+}
+
+func (r *sqliteSessionRepository) DeleteExpired() (int, error) {
+	cond, args := r.expiryCondition(r.now())
+	result, err := r.db.Exec("DELETE FROM sessions WHERE NOT ("+cond+")", args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
 }
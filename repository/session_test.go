@@ -1,24 +1,66 @@
 package repository
 
 import (
+	"database/sql"
 	"sync"
 	"testing"
+	"time"
+
+	"github.com/Kamekure-Maisuke/maiyumi/model"
+	_ "modernc.org/sqlite"
 )
 
-func TestSessionRepository_SetAndGet(t *testing.T) {
-	repo := NewSessionRepository()
+func setupSessionTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`
+		CREATE TABLE sessions (
+			id TEXT PRIMARY KEY,
+			user_id INTEGER NOT NULL,
+			user_agent TEXT NOT NULL DEFAULT '',
+			ip_address TEXT NOT NULL DEFAULT '',
+			csrf_token TEXT NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			last_seen_at DATETIME
+		)
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	sessionID := "test-session-id"
-	username := "testuser"
+	return db
+}
 
-	repo.Set(sessionID, username)
+func TestSessionRepository_CreateAndGet(t *testing.T) {
+	repo := NewSessionRepository(SessionOptions{})
+
+	session := &model.Session{
+		ID:        "test-session-id",
+		UserID:    1,
+		UserAgent: "test-agent",
+		IPAddress: "192.0.2.1",
+	}
+
+	if err := repo.Create(session); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if session.CreatedAt.IsZero() || session.LastSeenAt.IsZero() {
+		t.Errorf("Create() should set CreatedAt and LastSeenAt")
+	}
 
-	retrievedUsername, ok := repo.Get(sessionID)
+	found, ok := repo.Get(session.ID)
 	if !ok {
-		t.Errorf("Get() returned ok = false, want true")
+		t.Fatalf("Get() returned ok = false, want true")
 	}
-	if retrievedUsername != username {
-		t.Errorf("Get() username = %v, want %v", retrievedUsername, username)
+	if found.UserID != session.UserID {
+		t.Errorf("Get() user_id = %v, want %v", found.UserID, session.UserID)
+	}
+	if found.UserAgent != session.UserAgent || found.IPAddress != session.IPAddress {
+		t.Errorf("Get() metadata = (%v, %v), want (%v, %v)", found.UserAgent, found.IPAddress, session.UserAgent, session.IPAddress)
 	}
 
 	_, ok = repo.Get("nonexistent-session")
@@ -28,12 +70,11 @@ func TestSessionRepository_SetAndGet(t *testing.T) {
 }
 
 func TestSessionRepository_Delete(t *testing.T) {
-	repo := NewSessionRepository()
+	repo := NewSessionRepository(SessionOptions{})
 
 	sessionID := "test-session-id"
-	username := "testuser"
 
-	repo.Set(sessionID, username)
+	repo.Create(&model.Session{ID: sessionID, UserID: 1})
 
 	_, ok := repo.Get(sessionID)
 	if !ok {
@@ -49,7 +90,7 @@ func TestSessionRepository_Delete(t *testing.T) {
 }
 
 func TestSessionRepository_ConcurrentAccess(t *testing.T) {
-	repo := NewSessionRepository()
+	repo := NewSessionRepository(SessionOptions{})
 
 	var wg sync.WaitGroup
 	numGoroutines := 100
@@ -60,8 +101,7 @@ func TestSessionRepository_ConcurrentAccess(t *testing.T) {
 		go func(id int) {
 			defer wg.Done()
 			sessionID := "session-" + string(rune(id))
-			username := "user-" + string(rune(id))
-			repo.Set(sessionID, username)
+			repo.Create(&model.Session{ID: sessionID, UserID: id})
 		}(i)
 
 		go func(id int) {
@@ -80,66 +120,317 @@ func TestSessionRepository_ConcurrentAccess(t *testing.T) {
 	wg.Wait()
 }
 
-func TestSessionRepository_UpdateSession(t *testing.T) {
-	repo := NewSessionRepository()
+func TestSessionRepository_MultipleSessionsPerUser(t *testing.T) {
+	repo := NewSessionRepository(SessionOptions{})
 
-	sessionID := "test-session-id"
-	username1 := "user1"
-	username2 := "user2"
+	userID := 1
+	sessionID1 := "session-1"
+	sessionID2 := "session-2"
 
-	repo.Set(sessionID, username1)
+	repo.Create(&model.Session{ID: sessionID1, UserID: userID})
+	repo.Create(&model.Session{ID: sessionID2, UserID: userID})
 
-	retrievedUsername, ok := repo.Get(sessionID)
-	if !ok {
-		t.Fatalf("Session should exist")
+	session1, ok1 := repo.Get(sessionID1)
+	session2, ok2 := repo.Get(sessionID2)
+
+	if !ok1 || !ok2 {
+		t.Fatalf("Both sessions should exist")
+	}
+	if session1.UserID != userID || session2.UserID != userID {
+		t.Errorf("Both sessions should return the same user_id")
 	}
-	if retrievedUsername != username1 {
-		t.Errorf("Get() username = %v, want %v", retrievedUsername, username1)
+
+	repo.Delete(sessionID1)
+
+	_, ok1 = repo.Get(sessionID1)
+	session2, ok2 = repo.Get(sessionID2)
+
+	if ok1 {
+		t.Errorf("Session 1 should not exist after delete")
+	}
+	if !ok2 {
+		t.Fatalf("Session 2 should still exist")
+	}
+	if session2.UserID != userID {
+		t.Errorf("Session 2 should still return the correct user_id")
+	}
+}
+
+func TestSQLiteSessionRepository_CreateAndGet(t *testing.T) {
+	db := setupSessionTestDB(t)
+	defer db.Close()
+
+	repo := NewSQLiteSessionRepository(db, SessionOptions{})
+
+	err := repo.Create(&model.Session{
+		ID:        "test-session-id",
+		UserID:    1,
+		UserAgent: "test-agent",
+		IPAddress: "192.0.2.1",
+		CSRFToken: "test-csrf-token",
+	})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		sessionID  string
+		wantUserID int
+		wantOK     bool
+	}{
+		{
+			name:       "存在するセッション",
+			sessionID:  "test-session-id",
+			wantUserID: 1,
+			wantOK:     true,
+		},
+		{
+			name:       "存在しないセッション",
+			sessionID:  "nonexistent-session",
+			wantUserID: 0,
+			wantOK:     false,
+		},
 	}
 
-	repo.Set(sessionID, username2)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session, ok := repo.Get(tt.sessionID)
+			if ok != tt.wantOK {
+				t.Fatalf("Get() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if session.UserID != tt.wantUserID {
+				t.Errorf("Get() user_id = %v, want %v", session.UserID, tt.wantUserID)
+			}
+			if session.UserAgent != "test-agent" || session.IPAddress != "192.0.2.1" {
+				t.Errorf("Get() metadata = (%v, %v), want (test-agent, 192.0.2.1)", session.UserAgent, session.IPAddress)
+			}
+			if session.CSRFToken != "test-csrf-token" {
+				t.Errorf("Get() csrf_token = %v, want %v", session.CSRFToken, "test-csrf-token")
+			}
+		})
+	}
+}
+
+func TestSQLiteSessionRepository_DuplicateID(t *testing.T) {
+	db := setupSessionTestDB(t)
+	defer db.Close()
 
-	retrievedUsername, ok = repo.Get(sessionID)
+	repo := NewSQLiteSessionRepository(db, SessionOptions{})
+
+	if err := repo.Create(&model.Session{ID: "test-session-id", UserID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Create(&model.Session{ID: "test-session-id", UserID: 2}); err == nil {
+		t.Errorf("Create() with duplicate id should return error")
+	}
+
+	session, ok := repo.Get("test-session-id")
 	if !ok {
-		t.Fatalf("Session should still exist")
+		t.Fatalf("Session should exist")
 	}
-	if retrievedUsername != username2 {
-		t.Errorf("Get() username = %v, want %v", retrievedUsername, username2)
+	if session.UserID != 1 {
+		t.Errorf("Get() user_id = %v, want %v", session.UserID, 1)
 	}
 }
 
-func TestSessionRepository_MultipleSessionsPerUser(t *testing.T) {
-	repo := NewSessionRepository()
+func TestSQLiteSessionRepository_Delete(t *testing.T) {
+	db := setupSessionTestDB(t)
+	defer db.Close()
 
-	username := "testuser"
-	sessionID1 := "session-1"
-	sessionID2 := "session-2"
+	repo := NewSQLiteSessionRepository(db, SessionOptions{})
 
-	repo.Set(sessionID1, username)
-	repo.Set(sessionID2, username)
+	if err := repo.Create(&model.Session{ID: "test-session-id", UserID: 1}); err != nil {
+		t.Fatal(err)
+	}
 
-	user1, ok1 := repo.Get(sessionID1)
-	user2, ok2 := repo.Get(sessionID2)
+	if err := repo.Delete("test-session-id"); err != nil {
+		t.Errorf("Delete() error = %v", err)
+	}
 
-	if !ok1 || !ok2 {
-		t.Errorf("Both sessions should exist")
+	if _, ok := repo.Get("test-session-id"); ok {
+		t.Errorf("Session should not exist after delete")
 	}
-	if user1 != username || user2 != username {
-		t.Errorf("Both sessions should return the same username")
+}
+
+func TestSQLiteSessionRepository_SurvivesRestart(t *testing.T) {
+	db := setupSessionTestDB(t)
+	defer db.Close()
+
+	if err := NewSQLiteSessionRepository(db, SessionOptions{}).Create(&model.Session{ID: "test-session-id", UserID: 1}); err != nil {
+		t.Fatal(err)
 	}
 
-	repo.Delete(sessionID1)
+	// 再起動を想定して、同じDBから新しいリポジトリを作り直す
+	repo := NewSQLiteSessionRepository(db, SessionOptions{})
 
-	_, ok1 = repo.Get(sessionID1)
-	user2, ok2 = repo.Get(sessionID2)
+	session, ok := repo.Get("test-session-id")
+	if !ok {
+		t.Fatalf("Session should survive repository re-creation")
+	}
+	if session.UserID != 1 {
+		t.Errorf("Get() user_id = %v, want %v", session.UserID, 1)
+	}
+}
 
-	if ok1 {
-		t.Errorf("Session 1 should not exist after delete")
+// fakeClock はテストから時刻を進められる時計。
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.t
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+func newTestSessionRepositories(t *testing.T, opts SessionOptions, clock *fakeClock) map[string]SessionRepository {
+	memRepo := NewSessionRepository(opts).(*sessionRepository)
+	memRepo.now = clock.Now
+
+	db := setupSessionTestDB(t)
+	t.Cleanup(func() { db.Close() })
+	sqliteRepo := NewSQLiteSessionRepository(db, opts).(*sqliteSessionRepository)
+	sqliteRepo.now = clock.Now
+
+	return map[string]SessionRepository{
+		"memory": memRepo,
+		"sqlite": sqliteRepo,
 	}
-	if !ok2 {
-		t.Errorf("Session 2 should still exist")
+}
+
+func TestSessionRepository_Expiry(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   SessionOptions
+		steps  []time.Duration
+		wantOK bool
+	}{
+		{
+			name:   "アイドル期限内",
+			opts:   SessionOptions{IdleTimeout: time.Hour},
+			steps:  []time.Duration{59 * time.Minute},
+			wantOK: true,
+		},
+		{
+			name:   "アイドル期限切れ",
+			opts:   SessionOptions{IdleTimeout: time.Hour},
+			steps:  []time.Duration{61 * time.Minute},
+			wantOK: false,
+		},
+		{
+			name:   "スライディングなしでは途中のアクセスで延長されない",
+			opts:   SessionOptions{IdleTimeout: time.Hour},
+			steps:  []time.Duration{50 * time.Minute, 50 * time.Minute},
+			wantOK: false,
+		},
+		{
+			name:   "スライディングありでは途中のアクセスで延長される",
+			opts:   SessionOptions{IdleTimeout: time.Hour, Sliding: true},
+			steps:  []time.Duration{50 * time.Minute, 50 * time.Minute},
+			wantOK: true,
+		},
+		{
+			name:   "スライディングでも最大有効期間は超えられない",
+			opts:   SessionOptions{IdleTimeout: time.Hour, MaxLifetime: 90 * time.Minute, Sliding: true},
+			steps:  []time.Duration{50 * time.Minute, 50 * time.Minute},
+			wantOK: false,
+		},
 	}
-	if user2 != username {
-		t.Errorf("Session 2 should still return the correct username")
+
+	for _, tt := range tests {
+		clock := &fakeClock{t: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+		for implName, repo := range newTestSessionRepositories(t, tt.opts, clock) {
+			t.Run(tt.name+"_"+implName, func(t *testing.T) {
+				clock.t = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+				if err := repo.Create(&model.Session{ID: "test-session-id", UserID: 1}); err != nil {
+					t.Fatal(err)
+				}
+
+				var ok bool
+				for _, step := range tt.steps {
+					clock.Advance(step)
+					_, ok = repo.Get("test-session-id")
+				}
+				if ok != tt.wantOK {
+					t.Errorf("Get() ok = %v, want %v", ok, tt.wantOK)
+				}
+			})
+		}
+	}
+}
+
+func TestSessionRepository_DeleteExpired(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	opts := SessionOptions{IdleTimeout: time.Hour}
+
+	for implName, repo := range newTestSessionRepositories(t, opts, clock) {
+		t.Run(implName, func(t *testing.T) {
+			clock.t = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+			if err := repo.Create(&model.Session{ID: "old-session", UserID: 1}); err != nil {
+				t.Fatal(err)
+			}
+			clock.Advance(2 * time.Hour)
+			if err := repo.Create(&model.Session{ID: "new-session", UserID: 2}); err != nil {
+				t.Fatal(err)
+			}
+
+			deleted, err := repo.DeleteExpired()
+			if err != nil {
+				t.Fatalf("DeleteExpired() error = %v", err)
+			}
+			if deleted != 1 {
+				t.Errorf("DeleteExpired() deleted = %d, want 1", deleted)
+			}
+			if _, ok := repo.Get("new-session"); !ok {
+				t.Errorf("Unexpired session should remain after DeleteExpired()")
+			}
+		})
+	}
+}
+
+func TestSessionRepository_FindAndDeleteByUserID(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	opts := SessionOptions{IdleTimeout: time.Hour}
+
+	for implName, repo := range newTestSessionRepositories(t, opts, clock) {
+		t.Run(implName, func(t *testing.T) {
+			clock.t = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+			repo.Create(&model.Session{ID: "expired", UserID: 1})
+			clock.Advance(2 * time.Hour)
+			repo.Create(&model.Session{ID: "older", UserID: 1})
+			clock.Advance(time.Minute)
+			repo.Create(&model.Session{ID: "newer", UserID: 1})
+			repo.Create(&model.Session{ID: "other-user", UserID: 2})
+
+			sessions, err := repo.FindByUserID(1)
+			if err != nil {
+				t.Fatalf("FindByUserID() error = %v", err)
+			}
+			if len(sessions) != 2 {
+				t.Fatalf("FindByUserID() returned %d sessions, want 2", len(sessions))
+			}
+			if sessions[0].ID != "newer" || sessions[1].ID != "older" {
+				t.Errorf("FindByUserID() order = [%v, %v], want [newer, older]", sessions[0].ID, sessions[1].ID)
+			}
+
+			if err := repo.DeleteByUserID(1, "newer"); err != nil {
+				t.Fatalf("DeleteByUserID() error = %v", err)
+			}
+			if _, ok := repo.Get("older"); ok {
+				t.Errorf("Other sessions of the user should be deleted")
+			}
+			if _, ok := repo.Get("newer"); !ok {
+				t.Errorf("Excepted session should remain")
+			}
+			if _, ok := repo.Get("other-user"); !ok {
+				t.Errorf("Sessions of other users should remain")
+			}
+		})
 	}
 }
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/Kamekure-Maisuke/maiyumi/model"
+)
+
+type TagRepository interface {
+	Create(tag *model.Tag) error
+	Update(tag *model.Tag) error
+	Delete(id, userID int) error
+	FindByUserID(userID int) ([]model.Tag, error)
+}
+
+var ErrDuplicateTagName = errors.New("同じ名前のタグがあります")
+
+type tagRepository struct {
+	db *sql.DB
+}
+
+func NewTagRepository(db *sql.DB) TagRepository {
+	return &tagRepository{db: db}
+}
+
+// Create はタグを登録する。名前が重複する場合は ErrDuplicateTagName を返す。
+func (r *tagRepository) Create(tag *model.Tag) error {
+	result, err := r.db.Exec("INSERT INTO tags (user_id, name) VALUES (?, ?)", tag.UserID, tag.Name)
+	if isUniqueViolation(err) {
+		return ErrDuplicateTagName
+	}
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	tag.ID = int(id)
+	return nil
+}
+
+// Update はタグの名前を変更する。
+func (r *tagRepository) Update(tag *model.Tag) error {
+	result, err := r.db.Exec("UPDATE tags SET name = ? WHERE id = ? AND user_id = ?", tag.Name, tag.ID, tag.UserID)
+	if isUniqueViolation(err) {
+		return ErrDuplicateTagName
+	}
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Delete はタグを削除し、タレントからも外す。
+func (r *tagRepository) Delete(id, userID int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists int
+	err = tx.QueryRow("SELECT 1 FROM tags WHERE id = ? AND user_id = ?", id, userID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM talent_tags WHERE tag_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM tags WHERE id = ?", id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// FindByUserID はユーザーのタグを、付いているタレントの数と合わせて名前順に返す。
+// ごみ箱のタレントは数に含めない。
+func (r *tagRepository) FindByUserID(userID int) ([]model.Tag, error) {
+	rows, err := r.db.Query(`
+		SELECT g.id, g.user_id, g.name, COUNT(t.id)
+		FROM tags g
+		LEFT JOIN talent_tags tt ON tt.tag_id = g.id
+		LEFT JOIN talents t ON t.id = tt.talent_id AND t.deleted_at IS NULL
+		WHERE g.user_id = ?
+		GROUP BY g.id
+		ORDER BY g.name, g.id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []model.Tag
+	for rows.Next() {
+		var t model.Tag
+		if err := rows.Scan(&t.ID, &t.UserID, &t.Name, &t.TalentCount); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
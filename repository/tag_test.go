@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/Kamekure-Maisuke/maiyumi/model"
+)
+
+func TestTagRepository_CreateAndFind(t *testing.T) {
+	db, adjRepo := setupTalentTestDB(t)
+	defer db.Close()
+
+	repo := NewTagRepository(db)
+
+	tests := []struct {
+		name    string
+		tag     model.Tag
+		wantErr error
+	}{
+		{name: "新しいタグ", tag: model.Tag{UserID: 1, Name: "推し"}, wantErr: nil},
+		{name: "別の名前のタグ", tag: model.Tag{UserID: 1, Name: "センター"}, wantErr: nil},
+		{name: "同じユーザーで名前が重複", tag: model.Tag{UserID: 1, Name: "推し"}, wantErr: ErrDuplicateTagName},
+		{name: "他のユーザーとは重複してよい", tag: model.Tag{UserID: 2, Name: "推し"}, wantErr: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tag := tt.tag
+			err := repo.Create(&tag)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Create() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && tag.ID == 0 {
+				t.Errorf("Create() did not set ID")
+			}
+		})
+	}
+
+	talentRepo := NewTalentRepository(db, adjRepo, NewScoreDimensionRepository(db))
+	err := talentRepo.Create(&model.Talent{UserID: 1, Name: "タレント", Scores: testScores(1, 5, 5, 5), Tags: []model.Tag{{ID: 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tags, err := repo.FindByUserID(1)
+	if err != nil {
+		t.Fatalf("FindByUserID() error = %v", err)
+	}
+	want := []model.Tag{
+		{ID: 2, UserID: 1, Name: "センター", TalentCount: 0},
+		{ID: 1, UserID: 1, Name: "推し", TalentCount: 1},
+	}
+	if len(tags) != len(want) {
+		t.Fatalf("FindByUserID() len = %d, want %d", len(tags), len(want))
+	}
+	for i := range want {
+		if tags[i] != want[i] {
+			t.Errorf("FindByUserID()[%d] = %+v, want %+v", i, tags[i], want[i])
+		}
+	}
+}
+
+func TestTagRepository_Update(t *testing.T) {
+	db, _ := setupTalentTestDB(t)
+	defer db.Close()
+
+	repo := NewTagRepository(db)
+	for _, name := range []string{"推し", "センター"} {
+		if err := repo.Create(&model.Tag{UserID: 1, Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		tag     model.Tag
+		wantErr error
+	}{
+		{name: "名前を変更", tag: model.Tag{ID: 1, UserID: 1, Name: "最推し"}, wantErr: nil},
+		{name: "名前が他のタグと重複", tag: model.Tag{ID: 1, UserID: 1, Name: "センター"}, wantErr: ErrDuplicateTagName},
+		{name: "他のユーザーのタグ", tag: model.Tag{ID: 1, UserID: 2, Name: "乗っ取り"}, wantErr: sql.ErrNoRows},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tag := tt.tag
+			if err := repo.Update(&tag); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Update() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	var name string
+	if err := db.QueryRow("SELECT name FROM tags WHERE id = 1").Scan(&name); err != nil {
+		t.Fatal(err)
+	}
+	if name != "最推し" {
+		t.Errorf("Update() name = %v, want %v", name, "最推し")
+	}
+}
+
+func TestTagRepository_Delete(t *testing.T) {
+	db, adjRepo := setupTalentTestDB(t)
+	defer db.Close()
+
+	repo := NewTagRepository(db)
+	tags := createTestTags(t, db, 1, "推し", "センター")
+	talentRepo := NewTalentRepository(db, adjRepo, NewScoreDimensionRepository(db))
+	talent := &model.Talent{UserID: 1, Name: "タレント", Scores: testScores(1, 5, 5, 5), Tags: []model.Tag{tags["推し"], tags["センター"]}}
+	if err := talentRepo.Create(talent); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Delete(tags["推し"].ID, 2); err != sql.ErrNoRows {
+		t.Errorf("Delete() with wrong user_id error = %v, want %v", err, sql.ErrNoRows)
+	}
+	if err := repo.Delete(tags["推し"].ID, 1); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	found, err := talentRepo.FindByID(talent.ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found.Tags) != 1 || found.Tags[0].Name != "センター" {
+		t.Errorf("Delete() remaining talent tags = %+v, want only センター", found.Tags)
+	}
+}
@@ -2,147 +2,564 @@ package repository
 
 import (
 	"database/sql"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/Kamekure-Maisuke/maiyumi/model"
 )
 
 type TalentRepository interface {
 	Create(talent *model.Talent) error
+	CreateMany(talents []*model.Talent) error
 	Update(talent *model.Talent) error
+	Revert(talent *model.Talent, revisionID int) error
+	FindRevisions(talentID, userID int) ([]model.TalentRevision, error)
 	Delete(id, userID int) error
+	Restore(id, userID int) error
+	DeletePermanently(id, userID int) error
+	PurgeTrash(before time.Time) (int64, error)
+	FindTrash(userID int) ([]model.Talent, error)
 	FindByID(id, userID int) (*model.Talent, error)
 	FindByUserID(userID int) ([]model.Talent, error)
 	SearchByUserID(userID int, query string) ([]model.Talent, error)
 	FindFavoritesByUserID(userID int) ([]model.Talent, error)
+	FindByTags(userID int, tagNames []string, matchAll bool) ([]model.Talent, error)
+	FindByAffiliationID(userID, affiliationID int) ([]model.Talent, error)
+	FindPage(userID int, query TalentQuery) (*TalentPage, error)
+	Count(userID int, filter TalentFilter) (int, error)
 	ToggleFavorite(id, userID int) error
 	Exists(id, userID int) (bool, error)
 }
 
 type talentRepository struct {
-	db       *sql.DB
-	adjRepo  AdjustmentRepository
+	db      *sql.DB
+	adjRepo AdjustmentRepository
+	dimRepo ScoreDimensionRepository
 }
 
-func NewTalentRepository(db *sql.DB, adjRepo AdjustmentRepository) TalentRepository {
-	return &talentRepository{db: db, adjRepo: adjRepo}
+func NewTalentRepository(db *sql.DB, adjRepo AdjustmentRepository, dimRepo ScoreDimensionRepository) TalentRepository {
+	return &talentRepository{db: db, adjRepo: adjRepo, dimRepo: dimRepo}
+}
+
+// talentColumns は scanTalent で読み取るタレントの列。
+const talentColumns = "t.id, t.user_id, t.name, t.affiliation_id, a.name, t.is_favorite, t.created_at, t.deleted_at"
+
+// selectTalents はタレントを所属名と合わせて取得するクエリの先頭部分。
+// ごみ箱のタレントも含むため、条件で t.deleted_at を指定すること。
+const selectTalents = `
+	SELECT ` + talentColumns + `
+	FROM talents t
+	LEFT JOIN affiliations a ON a.id = t.affiliation_id`
+
+func scanTalent(scan func(dest ...any) error, t *model.Talent) error {
+	return scan(&t.ID, &t.UserID, &t.Name, &t.AffiliationID, &t.Affiliation, &t.IsFavorite, &t.CreatedAt, &t.DeletedAt)
+}
+
+// idPlaceholders は IN 句に使うプレースホルダーと引数を返す。
+func idPlaceholders(ids []int) (string, []any) {
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return strings.TrimSuffix(strings.Repeat("?,", len(ids)), ","), args
+}
+
+// insertTalent はタレントと評価済みのスコア・タグを登録し、talent.ID に登録したIDを設定する。
+// 所属は所属名から探し、なければ作成する。
+func insertTalent(tx *sql.Tx, talent *model.Talent) error {
+	affiliationID, err := ensureAffiliation(tx, talent.UserID, talent.Affiliation.String)
+	if err != nil {
+		return err
+	}
+	talent.AffiliationID = affiliationID
+
+	result, err := tx.Exec(`
+		INSERT INTO talents (user_id, name, affiliation_id)
+		VALUES (?, ?, ?)`,
+		talent.UserID, talent.Name, affiliationID)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	talent.ID = int(id)
+	if err := insertScores(tx, talent); err != nil {
+		return err
+	}
+	if err := insertTags(tx, talent); err != nil {
+		return err
+	}
+	if err := insertRevision(tx, talent.ID, sql.NullInt64{}); err != nil {
+		return err
+	}
+
+	after, err := talentSnapshot(tx, talent.ID)
+	if err != nil {
+		return err
+	}
+	return recordAudit(tx, auditEntry{
+		userID: talent.UserID, actorID: actor(talent.UserID),
+		entityType: model.AuditEntityTalent, entityID: talent.ID, action: model.AuditActionCreate,
+		after: after,
+	})
+}
+
+func insertScores(tx *sql.Tx, talent *model.Talent) error {
+	for _, s := range talent.Scores {
+		if !s.Rated {
+			continue
+		}
+		_, err := tx.Exec(`
+			INSERT INTO talent_scores (talent_id, dimension_id, score)
+			VALUES (?, ?, ?)`,
+			talent.ID, s.DimensionID, s.Value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertTags はタレントにタグを付ける。他のユーザーのタグは無視する。
+func insertTags(tx *sql.Tx, talent *model.Talent) error {
+	for _, tag := range talent.Tags {
+		_, err := tx.Exec(`
+			INSERT OR IGNORE INTO talent_tags (talent_id, tag_id)
+			SELECT ?, id FROM tags WHERE id = ? AND user_id = ?`,
+			talent.ID, tag.ID, talent.UserID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (r *talentRepository) Create(talent *model.Talent) error {
-	var affiliation any
-	if talent.Affiliation.Valid {
-		affiliation = talent.Affiliation.String
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
 
-	_, err := r.db.Exec(`
-		INSERT INTO talents (user_id, name, affiliation, beauty, cuteness, talent)
-		VALUES (?, ?, ?, ?, ?, ?)`,
-		talent.UserID, talent.Name, affiliation, talent.Beauty, talent.Cuteness, talent.Talent)
-	return err
+	if err := insertTalent(tx, talent); err != nil {
+		return err
+	}
+	if err := indexPendingTalents(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
+// CreateMany は複数のタレントを1つのトランザクションで登録する。1件でも失敗した場合はすべて取り消す。
+func (r *talentRepository) CreateMany(talents []*model.Talent) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, talent := range talents {
+		if err := insertTalent(tx, talent); err != nil {
+			return err
+		}
+	}
+	if err := indexPendingTalents(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Update はタレントとスコア・タグを更新する。スコアとタグは talent.Scores と talent.Tags の内容で置き換える。
+// 他のユーザーやごみ箱のタレントの場合は sql.ErrNoRows を返す。
 func (r *talentRepository) Update(talent *model.Talent) error {
-	var affiliation any
-	if talent.Affiliation.Valid {
-		affiliation = talent.Affiliation.String
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
 
-	_, err := r.db.Exec(`
-		UPDATE talents
-		SET name = ?, affiliation = ?, beauty = ?, cuteness = ?, talent = ?
-		WHERE id = ? AND user_id = ?`,
-		talent.Name, affiliation, talent.Beauty, talent.Cuteness, talent.Talent, talent.ID, talent.UserID)
-	return err
+	if err := updateTalent(tx, talent, sql.NullInt64{}); err != nil {
+		return err
+	}
+	if err := indexPendingTalents(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Revert はタレントを過去の版の内容に更新し、戻した版を記録した新しい版を追加する。
+// talent には戻す版の内容を設定しておくこと。版がユーザーのタレントのものでない場合は sql.ErrNoRows を返す。
+func (r *talentRepository) Revert(talent *model.Talent, revisionID int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var found int
+	err = tx.QueryRow(`
+		SELECT 1 FROM talent_revisions
+		WHERE id = ? AND talent_id = ? AND user_id = ?`,
+		revisionID, talent.ID, talent.UserID).Scan(&found)
+	if err != nil {
+		return err
+	}
+
+	if err := updateTalent(tx, talent, sql.NullInt64{Int64: int64(revisionID), Valid: true}); err != nil {
+		return err
+	}
+	if err := indexPendingTalents(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// updateTalent はタレントとスコア・タグを更新し、変更があれば監査ログと新しい版を記録する。
+// 他のユーザーやごみ箱のタレントの場合は何も書き換えずに sql.ErrNoRows を返す。
+func updateTalent(tx *sql.Tx, talent *model.Talent, revertedFrom sql.NullInt64) error {
+	// 所属を追加する前に持ち主を確かめ、更新できないタレントのために所属が残らないようにする
+	var found int
+	err := tx.QueryRow("SELECT 1 FROM talents WHERE id = ? AND user_id = ? AND deleted_at IS NULL", talent.ID, talent.UserID).Scan(&found)
+	if err != nil {
+		return err
+	}
+
+	before, err := talentSnapshot(tx, talent.ID)
+	if err != nil {
+		return err
+	}
+
+	affiliationID, err := ensureAffiliation(tx, talent.UserID, talent.Affiliation.String)
+	if err != nil {
+		return err
+	}
+	talent.AffiliationID = affiliationID
+
+	_, err = tx.Exec("UPDATE talents SET name = ?, affiliation_id = ? WHERE id = ?", talent.Name, affiliationID, talent.ID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM talent_scores WHERE talent_id = ?", talent.ID); err != nil {
+		return err
+	}
+	if err := insertScores(tx, talent); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM talent_tags WHERE talent_id = ?", talent.ID); err != nil {
+		return err
+	}
+	if err := insertTags(tx, talent); err != nil {
+		return err
+	}
+	if err := deleteUnusedAffiliations(tx, talent.UserID); err != nil {
+		return err
+	}
+
+	after, err := talentSnapshot(tx, talent.ID)
+	if err != nil {
+		return err
+	}
+	// 内容が変わっていない場合は版を増やさない
+	if changes, err := auditDiff(before, after); err != nil || changes == "" {
+		return err
+	}
+	err = recordAudit(tx, auditEntry{
+		userID: talent.UserID, actorID: actor(talent.UserID),
+		entityType: model.AuditEntityTalent, entityID: talent.ID, action: model.AuditActionUpdate,
+		before: before, after: after,
+	})
+	if err != nil {
+		return err
+	}
+	return insertRevision(tx, talent.ID, revertedFrom)
+}
+
+// changeTalent はユーザーのタレントのうち condition に一致するものを set の内容で更新し、監査ログを記録する。
+// 一致するタレントがない場合は false を返す。
+func (r *talentRepository) changeTalent(id, userID int, set, condition, action string) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	before, err := talentSnapshot(tx, id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	result, err := tx.Exec("UPDATE talents SET "+set+" WHERE id = ? AND user_id = ? AND "+condition, id, userID)
+	if err != nil {
+		return false, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+
+	after, err := talentSnapshot(tx, id)
+	if err != nil {
+		return false, err
+	}
+	err = recordAudit(tx, auditEntry{
+		userID: userID, actorID: actor(userID),
+		entityType: model.AuditEntityTalent, entityID: id, action: action,
+		before: before, after: after,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
 }
 
+// Delete はタレントをごみ箱に移す。スコアや調整履歴は残し、Restore で元に戻せる。
 func (r *talentRepository) Delete(id, userID int) error {
-	_, err := r.db.Exec("DELETE FROM talents WHERE id = ? AND user_id = ?", id, userID)
+	_, err := r.changeTalent(id, userID, "deleted_at = CURRENT_TIMESTAMP", "deleted_at IS NULL", model.AuditActionTrash)
 	return err
 }
 
-func (r *talentRepository) FindByID(id, userID int) (*model.Talent, error) {
-	var t model.Talent
-	err := r.db.QueryRow(`
-		SELECT id, user_id, name, affiliation, beauty, cuteness, talent, is_favorite, created_at
-		FROM talents
-		WHERE id = ? AND user_id = ?`, id, userID).Scan(
-		&t.ID, &t.UserID, &t.Name, &t.Affiliation, &t.Beauty, &t.Cuteness, &t.Talent, &t.IsFavorite, &t.CreatedAt)
+// Restore はごみ箱のタレントを元に戻す。ごみ箱にない場合は sql.ErrNoRows を返す。
+func (r *talentRepository) Restore(id, userID int) error {
+	restored, err := r.changeTalent(id, userID, "deleted_at = NULL", "deleted_at IS NOT NULL", model.AuditActionRestore)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if !restored {
+		return sql.ErrNoRows
 	}
+	return nil
+}
 
-	t.TotalBeauty, _ = r.adjRepo.CalculateTotalScore(t.ID, t.Beauty, "beauty")
-	t.TotalCuteness, _ = r.adjRepo.CalculateTotalScore(t.ID, t.Cuteness, "cuteness")
-	t.TotalTalent, _ = r.adjRepo.CalculateTotalScore(t.ID, t.Talent, "talent")
+// DeletePermanently はごみ箱のタレントをスコアや調整履歴と合わせて削除する。ごみ箱にない場合は sql.ErrNoRows を返す。
+func (r *talentRepository) DeletePermanently(id, userID int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	n, err := deleteTalents(tx, actor(userID), "id = ? AND user_id = ? AND deleted_at IS NOT NULL", id, userID)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	if err := deleteUnusedAffiliations(tx, userID); err != nil {
+		return err
+	}
 
-	return &t, nil
+	return tx.Commit()
 }
 
-func (r *talentRepository) FindByUserID(userID int) ([]model.Talent, error) {
-	rows, err := r.db.Query(`
-		SELECT id, user_id, name, affiliation, beauty, cuteness, talent, is_favorite, created_at
-		FROM talents
-		WHERE user_id = ?
-		ORDER BY is_favorite DESC, created_at DESC`, userID)
+// PurgeTrash は before より前にごみ箱に移したすべてのユーザーのタレントを削除し、削除した件数を返す。
+func (r *talentRepository) PurgeTrash(before time.Time) (int64, error) {
+	tx, err := r.db.Begin()
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-	defer rows.Close()
+	defer tx.Rollback()
 
-	var talents []model.Talent
-	var talentIDs []int
+	cutoff := before.UTC().Format(sqliteTimeLayout)
+	rows, err := tx.Query("SELECT DISTINCT user_id FROM talents WHERE deleted_at < ?", cutoff)
+	if err != nil {
+		return 0, err
+	}
+	var userIDs []int
 	for rows.Next() {
-		var t model.Talent
-		if err := rows.Scan(&t.ID, &t.UserID, &t.Name, &t.Affiliation, &t.Beauty, &t.Cuteness, &t.Talent, &t.IsFavorite, &t.CreatedAt); err != nil {
-			continue
+		var userID int
+		if err := rows.Scan(&userID); err != nil {
+			rows.Close()
+			return 0, err
 		}
-		talentIDs = append(talentIDs, t.ID)
-		talents = append(talents, t)
+		userIDs = append(userIDs, userID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
 	}
 
-	if len(talents) == 0 {
-		return talents, nil
+	// 自動で削除するため、監査ログには変更したユーザーを残さない
+	n, err := deleteTalents(tx, sql.NullInt64{}, "deleted_at < ?", cutoff)
+	if err != nil {
+		return 0, err
+	}
+	// 所属の整理は、タレントを削除したユーザーに限る
+	for _, userID := range userIDs {
+		if err := deleteUnusedAffiliations(tx, userID); err != nil {
+			return 0, err
+		}
 	}
 
-	adjustments, err := r.adjRepo.CalculateTotalScores(talentIDs)
+	return n, tx.Commit()
+}
+
+// deleteTalents は condition に一致するタレントを、スコア・調整履歴・タグの付与・版と合わせて削除する。
+// 削除したタレントと調整履歴ごとに、削除前の内容を actorID が削除したものとして監査ログに記録する。
+func deleteTalents(tx *sql.Tx, actorID sql.NullInt64, condition string, args ...any) (int64, error) {
+	rows, err := tx.Query("SELECT id, user_id FROM talents WHERE "+condition, args...)
 	if err != nil {
-		return talents, nil
+		return 0, err
+	}
+	var entries []auditEntry
+	for rows.Next() {
+		e := auditEntry{actorID: actorID, entityType: model.AuditEntityTalent, action: model.AuditActionDelete}
+		if err := rows.Scan(&e.entityID, &e.userID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	for i := range entries {
+		if entries[i].before, err = talentSnapshot(tx, entries[i].entityID); err != nil {
+			return 0, err
+		}
 	}
 
-	for i := range talents {
-		if adj, ok := adjustments[talents[i].ID]; ok {
-			talents[i].TotalBeauty = talents[i].Beauty + adj["beauty"]
-			talents[i].TotalCuteness = talents[i].Cuteness + adj["cuteness"]
-			talents[i].TotalTalent = talents[i].Talent + adj["talent"]
-		} else {
-			talents[i].TotalBeauty = talents[i].Beauty
-			talents[i].TotalCuteness = talents[i].Cuteness
-			talents[i].TotalTalent = talents[i].Talent
+	if err := recordAdjustmentDeletes(tx, actorID, "a.talent_id IN (SELECT id FROM talents WHERE "+condition+")", args...); err != nil {
+		return 0, err
+	}
+	for _, table := range []string{"adjustments", "talent_scores", "talent_tags", "talent_revisions"} {
+		_, err := tx.Exec("DELETE FROM "+table+" WHERE talent_id IN (SELECT id FROM talents WHERE "+condition+")", args...)
+		if err != nil {
+			return 0, err
 		}
 	}
+	result, err := tx.Exec("DELETE FROM talents WHERE "+condition, args...)
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range entries {
+		if err := recordAudit(tx, e); err != nil {
+			return 0, err
+		}
+	}
+	return result.RowsAffected()
+}
 
-	return talents, nil
+// FindTrash はごみ箱のタレントを、ごみ箱に移した新しい順に返す。
+func (r *talentRepository) FindTrash(userID int) ([]model.Talent, error) {
+	return r.findTalents(userID, selectTalents+`
+		WHERE t.user_id = ? AND t.deleted_at IS NOT NULL
+		ORDER BY t.deleted_at DESC, t.id DESC`, userID)
 }
 
+func (r *talentRepository) FindByID(id, userID int) (*model.Talent, error) {
+	var t model.Talent
+	err := scanTalent(r.db.QueryRow(selectTalents+`
+		WHERE t.id = ? AND t.user_id = ? AND t.deleted_at IS NULL`, id, userID).Scan, &t)
+	if err != nil {
+		return nil, err
+	}
+
+	talents := []model.Talent{t}
+	if err := r.attachScores(userID, talents); err != nil {
+		return nil, err
+	}
+	if err := r.attachTags(talents); err != nil {
+		return nil, err
+	}
+	return &talents[0], nil
+}
+
+func (r *talentRepository) FindByUserID(userID int) ([]model.Talent, error) {
+	return r.findTalents(userID, selectTalents+`
+		WHERE t.user_id = ? AND t.deleted_at IS NULL
+		ORDER BY t.is_favorite DESC, t.created_at DESC`, userID)
+}
+
+// SearchByUserID は名前・所属名・調整理由を全文検索し、関連度の高い順に返す。
+// ひらがなとカタカナ、全角と半角の違いは区別しない。
 func (r *talentRepository) SearchByUserID(userID int, query string) ([]model.Talent, error) {
-	searchQuery := "%" + query + "%"
-	rows, err := r.db.Query(`
-		SELECT id, user_id, name, affiliation, beauty, cuteness, talent, is_favorite, created_at
-		FROM talents
-		WHERE user_id = ? AND (name LIKE ? OR affiliation LIKE ?)
-		ORDER BY is_favorite DESC, created_at DESC`, userID, searchQuery, searchQuery)
+	page, err := r.FindPage(userID, TalentQuery{Filter: TalentFilter{Search: query}, Sort: TalentSort{Column: SortRelevance}})
+	if err != nil {
+		return nil, err
+	}
+	return page.Talents, nil
+}
+
+func (r *talentRepository) FindFavoritesByUserID(userID int) ([]model.Talent, error) {
+	return r.findTalents(userID, selectTalents+`
+		WHERE t.user_id = ? AND t.is_favorite = 1 AND t.deleted_at IS NULL
+		ORDER BY t.created_at DESC`, userID)
+}
+
+func (r *talentRepository) FindByAffiliationID(userID, affiliationID int) ([]model.Talent, error) {
+	return r.findTalents(userID, selectTalents+`
+		WHERE t.user_id = ? AND t.affiliation_id = ? AND t.deleted_at IS NULL
+		ORDER BY t.is_favorite DESC, t.created_at DESC`, userID, affiliationID)
+}
+
+// FindByTags は指定した名前のタグが付いたタレントを返す。
+// matchAll が true の場合はすべてのタグが付いたタレント、false の場合はいずれかのタグが付いたタレントを返す。
+func (r *talentRepository) FindByTags(userID int, tagNames []string, matchAll bool) ([]model.Talent, error) {
+	if len(tagNames) == 0 {
+		return r.FindByUserID(userID)
+	}
+
+	condition, args := tagCondition(userID, tagNames, matchAll)
+	return r.findTalents(userID, selectTalents+`
+		WHERE t.user_id = ? AND t.deleted_at IS NULL AND `+condition+`
+		ORDER BY t.is_favorite DESC, t.created_at DESC`, append([]any{userID}, args...)...)
+}
+
+// tagCondition はタグで絞り込む WHERE 句の条件と引数を返す。
+func tagCondition(userID int, tagNames []string, matchAll bool) (string, []any) {
+	// 同じタグ名が重複して指定されても件数の比較がずれないようにする
+	names := slices.Clone(tagNames)
+	slices.Sort(names)
+	names = slices.Compact(names)
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(names)), ",")
+	args := []any{userID}
+	for _, name := range names {
+		args = append(args, name)
+	}
+
+	having := ""
+	if matchAll {
+		// 存在しないタグ名が含まれる場合は該当なしになる
+		having = "HAVING COUNT(DISTINCT g.id) = ?"
+		args = append(args, len(names))
+	}
+
+	return `t.id IN (
+		SELECT tt.talent_id
+		FROM talent_tags tt
+		JOIN tags g ON g.id = tt.tag_id
+		WHERE g.user_id = ? AND g.name IN (` + placeholders + `)
+		GROUP BY tt.talent_id
+		` + having + `
+	)`, args
+}
+
+// findTalents はユーザーのタレントを検索し、スコアとタグを付けて返す。
+func (r *talentRepository) findTalents(userID int, query string, args ...any) ([]model.Talent, error) {
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	var talents []model.Talent
-	var talentIDs []int
 	for rows.Next() {
 		var t model.Talent
-		if err := rows.Scan(&t.ID, &t.UserID, &t.Name, &t.Affiliation, &t.Beauty, &t.Cuteness, &t.Talent, &t.IsFavorite, &t.CreatedAt); err != nil {
+		if err := scanTalent(rows.Scan, &t); err != nil {
 			continue
 		}
-		talentIDs = append(talentIDs, t.ID)
 		talents = append(talents, t)
 	}
 
@@ -150,83 +567,117 @@ func (r *talentRepository) SearchByUserID(userID int, query string) ([]model.Tal
 		return talents, nil
 	}
 
-	adjustments, err := r.adjRepo.CalculateTotalScores(talentIDs)
-	if err != nil {
-		return talents, nil
+	if err := r.attachScores(userID, talents); err != nil {
+		return nil, err
 	}
-
-	for i := range talents {
-		if adj, ok := adjustments[talents[i].ID]; ok {
-			talents[i].TotalBeauty = talents[i].Beauty + adj["beauty"]
-			talents[i].TotalCuteness = talents[i].Cuteness + adj["cuteness"]
-			talents[i].TotalTalent = talents[i].Talent + adj["talent"]
-		} else {
-			talents[i].TotalBeauty = talents[i].Beauty
-			talents[i].TotalCuteness = talents[i].Cuteness
-			talents[i].TotalTalent = talents[i].Talent
-		}
+	if err := r.attachTags(talents); err != nil {
+		return nil, err
 	}
-
 	return talents, nil
 }
 
-func (r *talentRepository) FindFavoritesByUserID(userID int) ([]model.Talent, error) {
+// attachScores はタレントにユーザーの評価軸の表示順でスコアを設定する。
+// 調整の合計が取得できない場合は初期値を合計として扱う。
+func (r *talentRepository) attachScores(userID int, talents []model.Talent) error {
+	dimensions, err := r.dimRepo.FindByUserID(userID)
+	if err != nil {
+		return err
+	}
+
+	talentIDs := make([]int, len(talents))
+	for i, t := range talents {
+		talentIDs[i] = t.ID
+	}
+
+	placeholders, args := idPlaceholders(talentIDs)
 	rows, err := r.db.Query(`
-		SELECT id, user_id, name, affiliation, beauty, cuteness, talent, is_favorite, created_at
-		FROM talents
-		WHERE user_id = ? AND is_favorite = 1
-		ORDER BY created_at DESC`, userID)
+		SELECT talent_id, dimension_id, score
+		FROM talent_scores
+		WHERE talent_id IN (`+placeholders+`)`, args...)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer rows.Close()
 
-	var talents []model.Talent
-	var talentIDs []int
+	base := make(map[int]map[int]int)
 	for rows.Next() {
-		var t model.Talent
-		if err := rows.Scan(&t.ID, &t.UserID, &t.Name, &t.Affiliation, &t.Beauty, &t.Cuteness, &t.Talent, &t.IsFavorite, &t.CreatedAt); err != nil {
-			continue
+		var talentID, dimensionID, score int
+		if err := rows.Scan(&talentID, &dimensionID, &score); err != nil {
+			return err
 		}
-		talentIDs = append(talentIDs, t.ID)
-		talents = append(talents, t)
+		if base[talentID] == nil {
+			base[talentID] = make(map[int]int)
+		}
+		base[talentID][dimensionID] = score
 	}
-
-	if len(talents) == 0 {
-		return talents, nil
+	if err := rows.Err(); err != nil {
+		return err
 	}
 
 	adjustments, err := r.adjRepo.CalculateTotalScores(talentIDs)
 	if err != nil {
-		return talents, nil
+		adjustments = nil
 	}
 
 	for i := range talents {
-		if adj, ok := adjustments[talents[i].ID]; ok {
-			talents[i].TotalBeauty = talents[i].Beauty + adj["beauty"]
-			talents[i].TotalCuteness = talents[i].Cuteness + adj["cuteness"]
-			talents[i].TotalTalent = talents[i].Talent + adj["talent"]
-		} else {
-			talents[i].TotalBeauty = talents[i].Beauty
-			talents[i].TotalCuteness = talents[i].Cuteness
-			talents[i].TotalTalent = talents[i].Talent
+		scores := make([]model.Score, len(dimensions))
+		for j, d := range dimensions {
+			value, rated := base[talents[i].ID][d.ID]
+			scores[j] = model.Score{
+				DimensionID: d.ID,
+				Name:        d.Name,
+				Value:       value,
+				Total:       value + adjustments[talents[i].ID][d.ID],
+				Rated:       rated,
+			}
 		}
+		talents[i].Scores = scores
 	}
 
-	return talents, nil
+	return nil
+}
+
+// attachTags はタレントに付いているタグを名前順に設定する。
+func (r *talentRepository) attachTags(talents []model.Talent) error {
+	talentIDs := make([]int, len(talents))
+	index := make(map[int]int, len(talents))
+	for i, t := range talents {
+		talentIDs[i] = t.ID
+		index[t.ID] = i
+	}
+
+	placeholders, args := idPlaceholders(talentIDs)
+	rows, err := r.db.Query(`
+		SELECT tt.talent_id, g.id, g.user_id, g.name
+		FROM talent_tags tt
+		JOIN tags g ON g.id = tt.tag_id
+		WHERE tt.talent_id IN (`+placeholders+`)
+		ORDER BY g.name, g.id`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var talentID int
+		var tag model.Tag
+		if err := rows.Scan(&talentID, &tag.ID, &tag.UserID, &tag.Name); err != nil {
+			return err
+		}
+		i := index[talentID]
+		talents[i].Tags = append(talents[i].Tags, tag)
+	}
+	return rows.Err()
 }
 
 func (r *talentRepository) ToggleFavorite(id, userID int) error {
-	_, err := r.db.Exec(`
-		UPDATE talents
-		SET is_favorite = NOT is_favorite
-		WHERE id = ? AND user_id = ?`, id, userID)
+	_, err := r.changeTalent(id, userID, "is_favorite = NOT is_favorite", "deleted_at IS NULL", model.AuditActionUpdate)
 	return err
 }
 
 func (r *talentRepository) Exists(id, userID int) (bool, error) {
 	var exists int
-	err := r.db.QueryRow("SELECT 1 FROM talents WHERE id = ? AND user_id = ?", id, userID).Scan(&exists)
+	err := r.db.QueryRow("SELECT 1 FROM talents WHERE id = ? AND user_id = ? AND deleted_at IS NULL", id, userID).Scan(&exists)
 	if err == sql.ErrNoRows {
 		return false, nil
 	}
@@ -0,0 +1,405 @@
+package repository
+
+import (
+	"cmp"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Kamekure-Maisuke/maiyumi/model"
+)
+
+// タレント一覧の並び替えに使える列。
+const (
+	SortDefault   = ""           // お気に入りを先頭に、新しく登録した順
+	SortName      = "name"       // 名前
+	SortCreatedAt = "created_at" // 登録日時
+	SortScore     = "score"      // 評価軸の調整後のスコア（TalentSort.DimensionID で指定）
+	SortRelevance = "relevance"  // 検索語との関連度（検索していない場合は既定の並び順）
+)
+
+// ErrInvalidCursor はページのカーソルが読み取れない場合に返る。
+var ErrInvalidCursor = errors.New("ページの指定が正しくありません")
+
+// TalentSort はタレント一覧の並び順。
+type TalentSort struct {
+	Column      string
+	DimensionID int // Column が SortScore の場合の評価軸
+	Desc        bool
+}
+
+// ScoreRange は評価軸の調整後のスコアの範囲。Min と Max は指定したものだけを条件にする。
+type ScoreRange struct {
+	DimensionID int
+	Min         sql.NullInt64
+	Max         sql.NullInt64
+}
+
+// TalentFilter はタレント一覧の絞り込みの条件。指定した条件をすべて満たすタレントに絞り込む。
+// ごみ箱のタレントは含めない。
+type TalentFilter struct {
+	Search        string       // 名前・所属名・調整理由の全文検索。空白で区切った語をすべて含むタレント
+	FavoriteOnly  bool         // お気に入りのみ
+	Tags          []string     // タグ名
+	MatchAll      bool         // true の場合は Tags のすべて、false の場合はいずれかが付いたタレント
+	AffiliationID int          // 0 の場合は所属で絞り込まない
+	Scores        []ScoreRange // 範囲を指定した評価軸が未評価のタレントは含めない
+	CreatedFrom   time.Time    // この日時以降に登録したタレント。ゼロ値の場合は絞り込まない
+	CreatedBefore time.Time    // この日時より前に登録したタレント。ゼロ値の場合は絞り込まない
+}
+
+// where は絞り込みの条件を WHERE 句の式に変換する。値はすべてパラメータとして返す。
+func (f TalentFilter) where(userID int) (string, []any) {
+	where := []string{"t.user_id = ?", "t.deleted_at IS NULL"}
+	args := []any{userID}
+	if terms := searchTerms(f.Search); len(terms) > 0 {
+		condition, searchArgs := searchCondition(terms)
+		where = append(where, condition)
+		args = append(args, searchArgs...)
+	}
+	if f.FavoriteOnly {
+		where = append(where, "t.is_favorite = 1")
+	}
+	if len(f.Tags) > 0 {
+		condition, tagArgs := tagCondition(userID, f.Tags, f.MatchAll)
+		where = append(where, condition)
+		args = append(args, tagArgs...)
+	}
+	if f.AffiliationID != 0 {
+		where = append(where, "t.affiliation_id = ?")
+		args = append(args, f.AffiliationID)
+	}
+	for _, score := range f.Scores {
+		if score.Min.Valid {
+			where = append(where, scoreTotal+" >= ?")
+			args = append(args, score.DimensionID, score.Min.Int64)
+		}
+		if score.Max.Valid {
+			where = append(where, scoreTotal+" <= ?")
+			args = append(args, score.DimensionID, score.Max.Int64)
+		}
+	}
+	if !f.CreatedFrom.IsZero() {
+		where = append(where, "t.created_at >= ?")
+		args = append(args, f.CreatedFrom.UTC().Format(sqliteTimeLayout))
+	}
+	if !f.CreatedBefore.IsZero() {
+		where = append(where, "t.created_at < ?")
+		args = append(args, f.CreatedBefore.UTC().Format(sqliteTimeLayout))
+	}
+	return strings.Join(where, " AND "), args
+}
+
+// TalentQuery はタレント一覧の絞り込み・並び順・ページの指定。
+type TalentQuery struct {
+	Filter TalentFilter
+	Sort   TalentSort
+
+	// After と Before はどちらか一方だけ指定する。どちらも空の場合は最初のページを返す。
+	After  string // TalentPage.NextCursor の値
+	Before string // TalentPage.PrevCursor の値
+	Limit  int    // 0 以下の場合はすべて返す
+}
+
+// TalentPage はタレント一覧の1ページ分。
+type TalentPage struct {
+	Talents    []model.Talent
+	TotalCount int               // 絞り込みに一致するタレントの総数
+	Snippets   map[int][]Snippet // 検索した場合の、タレントのIDごとの検索語に一致した項目の抜粋
+	NextCursor string            // 空の場合は次のページがない
+	PrevCursor string            // 空の場合は前のページがない
+}
+
+// sortKey は並び替えのキーとなる式。NULL にならない式を使う。
+// 式は SELECT・WHERE・ORDER BY で繰り返し使うため、使うたびに args を渡す。
+type sortKey struct {
+	expr string
+	desc bool
+	args []any
+}
+
+// defaultSortKeys は既定の並び順のキー。
+var defaultSortKeys = []sortKey{{expr: "t.is_favorite", desc: true}, {expr: "t.created_at", desc: true}}
+
+// scoreTotal はタレントの評価軸の調整後のスコアを返す式。未評価の場合は NULL になる。
+// 評価軸のIDはパラメータで渡す。
+const scoreTotal = `(
+		SELECT s.score + COALESCE((
+			SELECT SUM(adj.points) FROM adjustments adj
+			WHERE adj.talent_id = t.id AND adj.dimension_id = s.dimension_id
+		), 0)
+		FROM talent_scores s
+		WHERE s.talent_id = t.id AND s.dimension_id = ?
+	)`
+
+// sortKeys は並び順をキーの式の列に変換する。最後のキーは同じ値の行の順序を決めるためのIDにする。
+// 関連度順は terms のうち索引で探せる語の一致の度合いで並べ、同じ度合いの場合は既定の並び順にする。
+func sortKeys(sort TalentSort, terms []string) []sortKey {
+	var keys []sortKey
+	switch sort.Column {
+	case SortName:
+		keys = []sortKey{{expr: "t.name", desc: sort.Desc}}
+	case SortCreatedAt:
+		keys = []sortKey{{expr: "t.created_at", desc: sort.Desc}}
+	case SortScore:
+		total := scoreTotal
+		// 未評価のタレントは並び順に関係なく最後にする
+		keys = []sortKey{
+			// 比較演算子は IS より優先順位が高いため括弧で囲む
+			{expr: "(" + total + " IS NULL)", args: []any{sort.DimensionID}},
+			{expr: "COALESCE(" + total + ", 0)", desc: sort.Desc, args: []any{sort.DimensionID}},
+		}
+	case SortRelevance:
+		keys = defaultSortKeys
+		if match := searchMatch(terms); match != "" {
+			// bm25 は一致の度合いが高いほど小さい。名前、所属名、調整理由の順に重みを付ける
+			rank := sortKey{
+				expr: "(SELECT bm25(talents_fts, 10.0, 5.0, 1.0) FROM talents_fts WHERE talents_fts MATCH ? AND rowid = t.id)",
+				args: []any{match},
+			}
+			keys = append([]sortKey{rank}, keys...)
+		}
+	default:
+		keys = defaultSortKeys
+	}
+	return append(slices.Clip(keys), sortKey{expr: "t.id", desc: keys[len(keys)-1].desc})
+}
+
+// keysetCondition は values の行より後（backward が true の場合は前）の行に絞り込む条件を返す。
+func keysetCondition(keys []sortKey, values []any, backward bool) (string, []any) {
+	var or []string
+	var args []any
+	for i, key := range keys {
+		var and []string
+		for j := range i {
+			and = append(and, keys[j].expr+" = ?")
+			args = append(append(args, keys[j].args...), values[j])
+		}
+		op := ">"
+		if key.desc != backward {
+			op = "<"
+		}
+		and = append(and, key.expr+" "+op+" ?")
+		args = append(append(args, key.args...), values[i])
+		or = append(or, "("+strings.Join(and, " AND ")+")")
+	}
+	return "(" + strings.Join(or, " OR ") + ")", args
+}
+
+// encodeCursor はページの境界の行のキーの値をカーソルにする。
+func encodeCursor(values []any) string {
+	b, _ := json.Marshal(values)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor はカーソルをキーの値に戻す。数値は float64 になるが、SQLiteでは整数と同じように比較できる。
+func decodeCursor(cursor string, keys []sortKey) ([]any, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var values []any
+	if err := json.Unmarshal(b, &values); err != nil || len(values) != len(keys) {
+		return nil, ErrInvalidCursor
+	}
+	for _, v := range values {
+		switch v.(type) {
+		case float64, string:
+		default:
+			return nil, ErrInvalidCursor
+		}
+	}
+	return values, nil
+}
+
+// Count は絞り込みに一致するタレントの数を返す。
+func (r *talentRepository) Count(userID int, filter TalentFilter) (int, error) {
+	condition, args := filter.where(userID)
+	var count int
+	err := r.db.QueryRow(`
+		SELECT COUNT(*)
+		FROM talents t
+		LEFT JOIN affiliations a ON a.id = t.affiliation_id
+		WHERE `+condition, args...).Scan(&count)
+	return count, err
+}
+
+// FindPage は絞り込みと並び順に従ってタレント一覧の1ページを返す。
+// ページはキーの値で区切るため、ページを移動する間に登録や削除があっても行が重複したり抜けたりしない。
+func (r *talentRepository) FindPage(userID int, query TalentQuery) (*TalentPage, error) {
+	condition, args := query.Filter.where(userID)
+	where := []string{condition}
+
+	total, err := r.Count(userID, query.Filter)
+	if err != nil {
+		return nil, err
+	}
+	page := &TalentPage{TotalCount: total}
+
+	terms := searchTerms(query.Filter.Search)
+	keys := sortKeys(query.Sort, terms)
+	backward := query.Before != ""
+	if cursor := cmp.Or(query.Before, query.After); cursor != "" {
+		values, err := decodeCursor(cursor, keys)
+		if err != nil {
+			return nil, err
+		}
+		condition, keyArgs := keysetCondition(keys, values, backward)
+		where = append(where, condition)
+		args = append(args, keyArgs...)
+	}
+
+	var columns, orderBy []string
+	var columnArgs, orderArgs []any
+	for _, key := range keys {
+		// 単項の + で列の型宣言を外し、created_at が time.Time ではなく保存されている文字列のまま返るようにする
+		columns = append(columns, "+("+key.expr+")")
+		columnArgs = append(columnArgs, key.args...)
+		if key.desc != backward {
+			orderBy = append(orderBy, key.expr+" DESC")
+		} else {
+			orderBy = append(orderBy, key.expr+" ASC")
+		}
+		orderArgs = append(orderArgs, key.args...)
+	}
+	args = slices.Concat(columnArgs, args, orderArgs)
+
+	stmt := `
+		SELECT ` + talentColumns + `, ` + strings.Join(columns, ", ") + `
+		FROM talents t
+		LEFT JOIN affiliations a ON a.id = t.affiliation_id
+		WHERE ` + strings.Join(where, " AND ") + `
+		ORDER BY ` + strings.Join(orderBy, ", ")
+	if query.Limit > 0 {
+		// 1件多く取得して、続きのページがあるか判定する
+		stmt += " LIMIT " + strconv.Itoa(query.Limit+1)
+	}
+
+	rows, err := r.db.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var talents []model.Talent
+	var cursors []string
+	for rows.Next() {
+		var t model.Talent
+		values := make([]any, len(keys))
+		dest := make([]any, len(keys))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		err := scanTalent(func(talentDest ...any) error {
+			return rows.Scan(append(talentDest, dest...)...)
+		}, &t)
+		if err != nil {
+			return nil, err
+		}
+		talents = append(talents, t)
+		cursors = append(cursors, encodeCursor(normalizeCursorValues(values)))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	hasMore := query.Limit > 0 && len(talents) > query.Limit
+	if hasMore {
+		talents, cursors = talents[:query.Limit], cursors[:query.Limit]
+	}
+	if backward {
+		slices.Reverse(talents)
+		slices.Reverse(cursors)
+	}
+
+	if len(talents) > 0 {
+		if (backward && hasMore) || (!backward && query.After != "") {
+			page.PrevCursor = cursors[0]
+		}
+		// 逆向きに取得した場合は、取得を始めた位置の後ろに必ず続きがある
+		if (!backward && hasMore) || backward {
+			page.NextCursor = cursors[len(cursors)-1]
+		}
+
+		if err := r.attachScores(userID, talents); err != nil {
+			return nil, err
+		}
+		if err := r.attachTags(talents); err != nil {
+			return nil, err
+		}
+		if len(terms) > 0 {
+			if page.Snippets, err = r.snippets(talents, terms); err != nil {
+				return nil, err
+			}
+		}
+	}
+	page.Talents = talents
+	return page, nil
+}
+
+// normalizeCursorValues はドライバが返した値をカーソルに入れられる型にそろえる。
+func normalizeCursorValues(values []any) []any {
+	normalized := make([]any, len(values))
+	for i, v := range values {
+		switch v := v.(type) {
+		case int64:
+			normalized[i] = float64(v)
+		case []byte:
+			normalized[i] = string(v)
+		case nil:
+			normalized[i] = 0.0
+		default:
+			normalized[i] = v
+		}
+	}
+	return normalized
+}
+
+// snippets はタレントの名前・所属名・調整理由のうち、検索語に一致した項目の抜粋を返す。
+func (r *talentRepository) snippets(talents []model.Talent, terms []string) (map[int][]Snippet, error) {
+	snippets := make(map[int][]Snippet, len(talents))
+	talentIDs := make([]int, len(talents))
+	for i, t := range talents {
+		talentIDs[i] = t.ID
+		if parts := highlight(t.Name, terms); parts != nil {
+			snippets[t.ID] = append(snippets[t.ID], Snippet{Field: SnippetName, Parts: parts})
+		}
+		if parts := highlight(t.Affiliation.String, terms); parts != nil {
+			snippets[t.ID] = append(snippets[t.ID], Snippet{Field: SnippetAffiliation, Parts: parts})
+		}
+	}
+
+	placeholders, args := idPlaceholders(talentIDs)
+	rows, err := r.db.Query(`
+		SELECT talent_id, reason
+		FROM adjustments
+		WHERE talent_id IN (`+placeholders+`) AND reason IS NOT NULL
+		ORDER BY created_at DESC, id DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// 調整理由は新しいものから、一致した最初の1件だけを抜粋する
+	reasonFound := make(map[int]bool)
+	for rows.Next() {
+		var talentID int
+		var reason string
+		if err := rows.Scan(&talentID, &reason); err != nil {
+			return nil, err
+		}
+		if reasonFound[talentID] {
+			continue
+		}
+		if parts := highlight(reason, terms); parts != nil {
+			snippets[talentID] = append(snippets[talentID], Snippet{Field: SnippetReason, Parts: parts})
+			reasonFound[talentID] = true
+		}
+	}
+	return snippets, rows.Err()
+}
@@ -2,7 +2,9 @@ package repository
 
 import (
 	"database/sql"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/Kamekure-Maisuke/maiyumi/model"
 	_ "modernc.org/sqlite"
@@ -13,54 +15,126 @@ func setupTalentTestDB(t *testing.T) (*sql.DB, AdjustmentRepository) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	db.SetMaxOpenConns(1)
 
 	_, err = db.Exec(`
 		CREATE TABLE talents (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			user_id INTEGER NOT NULL,
 			name TEXT NOT NULL,
-			affiliation TEXT,
-			beauty INTEGER NOT NULL,
-			cuteness INTEGER NOT NULL,
-			talent INTEGER NOT NULL,
+			affiliation_id INTEGER,
 			is_favorite BOOLEAN DEFAULT 0,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)
-	`)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	_, err = db.Exec(`
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			deleted_at DATETIME
+		);
+		CREATE TABLE affiliations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			name_key TEXT NOT NULL,
+			UNIQUE (user_id, name_key)
+		);
+		CREATE TABLE score_dimensions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			min_score INTEGER NOT NULL,
+			max_score INTEGER NOT NULL,
+			display_order INTEGER NOT NULL DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (user_id, name)
+		);
+		CREATE TABLE talent_scores (
+			talent_id INTEGER NOT NULL,
+			dimension_id INTEGER NOT NULL,
+			score INTEGER NOT NULL,
+			PRIMARY KEY (talent_id, dimension_id)
+		);
 		CREATE TABLE adjustments (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			talent_id INTEGER NOT NULL,
-			adjustment_type TEXT NOT NULL,
+			dimension_id INTEGER NOT NULL,
 			points INTEGER NOT NULL,
 			reason TEXT,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE audit_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			actor_id INTEGER,
+			entity_type TEXT NOT NULL,
+			entity_id INTEGER NOT NULL,
+			action TEXT NOT NULL,
+			changes TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE talent_revisions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			talent_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			affiliation TEXT NOT NULL,
+			scores TEXT NOT NULL,
+			tags TEXT NOT NULL,
+			reverted_from INTEGER,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE tags (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			UNIQUE (user_id, name)
+		);
+		CREATE TABLE talent_tags (
+			talent_id INTEGER NOT NULL,
+			tag_id INTEGER NOT NULL,
+			PRIMARY KEY (talent_id, tag_id)
+		);
+		CREATE TABLE saved_searches (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			query TEXT NOT NULL,
+			UNIQUE (user_id, name)
 		)
 	`)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if _, err := db.Exec(TalentSearchSchema); err != nil {
+		t.Fatal(err)
+	}
+
+	// ユーザー1の評価軸はID 1〜3、ユーザー2の評価軸はID 4〜6 になる
+	for _, userID := range []int{1, 2} {
+		if err := createDefaultScoreDimensions(db, userID); err != nil {
+			t.Fatal(err)
+		}
+	}
 
 	adjRepo := NewAdjustmentRepository(db)
 	return db, adjRepo
 }
 
+// testScores は firstDimensionID から連番の評価軸に順にスコアを割り当てる。
+func testScores(firstDimensionID int, values ...int) []model.Score {
+	scores := make([]model.Score, len(values))
+	for i, v := range values {
+		scores[i] = model.Score{DimensionID: firstDimensionID + i, Value: v, Rated: true}
+	}
+	return scores
+}
+
 func TestTalentRepository_Create(t *testing.T) {
 	db, adjRepo := setupTalentTestDB(t)
 	defer db.Close()
 
-	repo := NewTalentRepository(db, adjRepo)
+	repo := NewTalentRepository(db, adjRepo, NewScoreDimensionRepository(db))
 
 	talent := &model.Talent{
-		UserID:   1,
-		Name:     "テストタレント",
-		Beauty:   80,
-		Cuteness: 75,
-		Talent:   90,
+		UserID: 1,
+		Name:   "テストタレント",
+		Scores: testScores(1, 80, 75, 90),
 	}
 
 	err := repo.Create(talent)
@@ -76,20 +150,74 @@ func TestTalentRepository_Create(t *testing.T) {
 	if count != 1 {
 		t.Errorf("Create() did not insert talent, count = %d", count)
 	}
+
+	err = db.QueryRow("SELECT COUNT(*) FROM talent_scores WHERE talent_id = ?", talent.ID).Scan(&count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("Create() inserted %d scores, want 3", count)
+	}
+}
+
+func TestTalentRepository_CreateMany(t *testing.T) {
+	tests := []struct {
+		name      string
+		names     []string
+		wantErr   bool
+		wantCount int
+	}{
+		{name: "すべて登録", names: []string{"タレント1", "タレント2", "タレント3"}, wantErr: false, wantCount: 3},
+		{name: "空のリスト", names: nil, wantErr: false, wantCount: 0},
+		{name: "1件でも失敗したらすべて取り消す", names: []string{"タレント1", "NG", "タレント3"}, wantErr: true, wantCount: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, adjRepo := setupTalentTestDB(t)
+			defer db.Close()
+
+			_, err := db.Exec(`
+				CREATE TRIGGER reject_ng BEFORE INSERT ON talents
+				WHEN NEW.name = 'NG'
+				BEGIN SELECT RAISE(ABORT, 'rejected'); END`)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			repo := NewTalentRepository(db, adjRepo, NewScoreDimensionRepository(db))
+
+			var talents []*model.Talent
+			for _, name := range tt.names {
+				talents = append(talents, &model.Talent{UserID: 1, Name: name, Scores: testScores(1, 5, 5, 5)})
+			}
+
+			err = repo.CreateMany(talents)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CreateMany() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var count int
+			if err := db.QueryRow("SELECT COUNT(*) FROM talents").Scan(&count); err != nil {
+				t.Fatal(err)
+			}
+			if count != tt.wantCount {
+				t.Errorf("CreateMany() count = %d, want %d", count, tt.wantCount)
+			}
+		})
+	}
 }
 
 func TestTalentRepository_Update(t *testing.T) {
 	db, adjRepo := setupTalentTestDB(t)
 	defer db.Close()
 
-	repo := NewTalentRepository(db, adjRepo)
+	repo := NewTalentRepository(db, adjRepo, NewScoreDimensionRepository(db))
 
 	talent := &model.Talent{
-		UserID:   1,
-		Name:     "元の名前",
-		Beauty:   80,
-		Cuteness: 75,
-		Talent:   90,
+		UserID: 1,
+		Name:   "元の名前",
+		Scores: testScores(1, 80, 75, 90),
 	}
 	err := repo.Create(talent)
 	if err != nil {
@@ -103,12 +231,10 @@ func TestTalentRepository_Update(t *testing.T) {
 	}
 
 	updatedTalent := &model.Talent{
-		ID:       id,
-		UserID:   1,
-		Name:     "更新後の名前",
-		Beauty:   85,
-		Cuteness: 80,
-		Talent:   95,
+		ID:     id,
+		UserID: 1,
+		Name:   "更新後の名前",
+		Scores: testScores(1, 85, 80, 95),
 	}
 
 	err = repo.Update(updatedTalent)
@@ -118,7 +244,10 @@ func TestTalentRepository_Update(t *testing.T) {
 
 	var name string
 	var beauty int
-	err = db.QueryRow("SELECT name, beauty FROM talents WHERE id = ?", id).Scan(&name, &beauty)
+	err = db.QueryRow(`
+		SELECT t.name, s.score FROM talents t
+		JOIN talent_scores s ON s.talent_id = t.id AND s.dimension_id = 1
+		WHERE t.id = ?`, id).Scan(&name, &beauty)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -128,18 +257,83 @@ func TestTalentRepository_Update(t *testing.T) {
 	if beauty != 85 {
 		t.Errorf("Update() beauty = %v, want %v", beauty, 85)
 	}
+
+	// 他のユーザーとして更新してもスコアは書き換わらず、所属も追加されない
+	err = repo.Update(&model.Talent{ID: id, UserID: 2, Name: "他のユーザー", Affiliation: sql.NullString{String: "事務所B", Valid: true}, Scores: testScores(4, 1, 1, 1)})
+	if err != sql.ErrNoRows {
+		t.Errorf("Update() with wrong user_id error = %v, want sql.ErrNoRows", err)
+	}
+	var affiliations int
+	if err := db.QueryRow("SELECT COUNT(*) FROM affiliations WHERE user_id = 2").Scan(&affiliations); err != nil {
+		t.Fatal(err)
+	}
+	if affiliations != 0 {
+		t.Errorf("Update() with wrong user_id added %d affiliations, want 0", affiliations)
+	}
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM talent_scores WHERE talent_id = ? AND dimension_id <= 3", id).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("Update() with wrong user_id changed scores, count = %d, want 3", count)
+	}
+}
+
+func TestTalentRepository_FindByID_Scores(t *testing.T) {
+	db, adjRepo := setupTalentTestDB(t)
+	defer db.Close()
+
+	repo := NewTalentRepository(db, adjRepo, NewScoreDimensionRepository(db))
+
+	// 3つ目の評価軸は未評価のままにする
+	talent := &model.Talent{UserID: 1, Name: "スコアテスト", Scores: testScores(1, 5, 6)}
+	if err := repo.Create(talent); err != nil {
+		t.Fatal(err)
+	}
+	for _, adj := range []*model.Adjustment{
+		{TalentID: talent.ID, DimensionID: 1, Points: 2, Reason: "加点"},
+		{TalentID: talent.ID, DimensionID: 1, Points: -1, Reason: "減点"},
+		{TalentID: talent.ID, DimensionID: 3, Points: 3, Reason: "未評価の軸への加点"},
+	} {
+		if err := adjRepo.Create(adj); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	found, err := repo.FindByID(talent.ID, 1)
+	if err != nil {
+		t.Fatalf("FindByID() error = %v", err)
+	}
+
+	want := []model.Score{
+		{DimensionID: 1, Name: "美しさ", Value: 5, Total: 6, Rated: true},
+		{DimensionID: 2, Name: "可愛さ", Value: 6, Total: 6, Rated: true},
+		{DimensionID: 3, Name: "才能", Value: 0, Total: 3, Rated: false},
+	}
+	if len(found.Scores) != len(want) {
+		t.Fatalf("FindByID() scores = %+v, want %+v", found.Scores, want)
+	}
+	for i := range want {
+		if found.Scores[i] != want[i] {
+			t.Errorf("FindByID() Scores[%d] = %+v, want %+v", i, found.Scores[i], want[i])
+		}
+	}
+
+	if _, err := repo.FindByID(talent.ID, 2); err != sql.ErrNoRows {
+		t.Errorf("FindByID() with wrong user_id error = %v, want %v", err, sql.ErrNoRows)
+	}
 }
 
 func TestTalentRepository_FindByUserID(t *testing.T) {
 	db, adjRepo := setupTalentTestDB(t)
 	defer db.Close()
 
-	repo := NewTalentRepository(db, adjRepo)
+	repo := NewTalentRepository(db, adjRepo, NewScoreDimensionRepository(db))
 
 	talents := []*model.Talent{
-		{UserID: 1, Name: "タレント1", Beauty: 80, Cuteness: 75, Talent: 90},
-		{UserID: 1, Name: "タレント2", Beauty: 85, Cuteness: 80, Talent: 85},
-		{UserID: 2, Name: "タレント3", Beauty: 70, Cuteness: 85, Talent: 80},
+		{UserID: 1, Name: "タレント1", Scores: testScores(1, 80, 75, 90)},
+		{UserID: 1, Name: "タレント2", Scores: testScores(1, 85, 80, 85)},
+		{UserID: 2, Name: "タレント3", Scores: testScores(4, 70, 85, 80)},
 	}
 
 	for _, talent := range talents {
@@ -162,12 +356,12 @@ func TestTalentRepository_SearchByUserID(t *testing.T) {
 	db, adjRepo := setupTalentTestDB(t)
 	defer db.Close()
 
-	repo := NewTalentRepository(db, adjRepo)
+	repo := NewTalentRepository(db, adjRepo, NewScoreDimensionRepository(db))
 
 	talents := []*model.Talent{
-		{UserID: 1, Name: "山田花子", Beauty: 80, Cuteness: 75, Talent: 90},
-		{UserID: 1, Name: "佐藤太郎", Beauty: 85, Cuteness: 80, Talent: 85},
-		{UserID: 1, Name: "田中次郎", Beauty: 70, Cuteness: 85, Talent: 80},
+		{UserID: 1, Name: "山田花子", Scores: testScores(1, 80, 75, 90)},
+		{UserID: 1, Name: "佐藤太郎", Scores: testScores(1, 85, 80, 85)},
+		{UserID: 1, Name: "田中次郎", Scores: testScores(1, 70, 85, 80)},
 	}
 
 	for _, talent := range talents {
@@ -217,18 +411,138 @@ func TestTalentRepository_SearchByUserID(t *testing.T) {
 	}
 }
 
+// createTestTags はユーザーのタグを登録し、名前からIDを引けるようにして返す。
+func createTestTags(t *testing.T, db *sql.DB, userID int, names ...string) map[string]model.Tag {
+	t.Helper()
+	repo := NewTagRepository(db)
+	tags := make(map[string]model.Tag, len(names))
+	for _, name := range names {
+		tag := model.Tag{UserID: userID, Name: name}
+		if err := repo.Create(&tag); err != nil {
+			t.Fatal(err)
+		}
+		tags[name] = tag
+	}
+	return tags
+}
+
+func TestTalentRepository_Tags(t *testing.T) {
+	db, adjRepo := setupTalentTestDB(t)
+	defer db.Close()
+
+	repo := NewTalentRepository(db, adjRepo, NewScoreDimensionRepository(db))
+	tags := createTestTags(t, db, 1, "推し", "センター", "卒業予定")
+	otherTag := createTestTags(t, db, 2, "他人のタグ")["他人のタグ"]
+
+	talent := &model.Talent{
+		UserID: 1,
+		Name:   "タレント",
+		Scores: testScores(1, 5, 5, 5),
+		Tags:   []model.Tag{tags["推し"], tags["センター"], otherTag},
+	}
+	if err := repo.Create(talent); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		update   *model.Talent
+		wantErr  error
+		wantTags []string
+	}{
+		{name: "登録時のタグ（他のユーザーのタグは付かない）", update: nil, wantTags: []string{"センター", "推し"}},
+		{name: "タグを置き換える", update: &model.Talent{ID: talent.ID, UserID: 1, Name: "タレント", Scores: testScores(1, 5, 5, 5), Tags: []model.Tag{tags["卒業予定"]}}, wantTags: []string{"卒業予定"}},
+		{name: "他のユーザーとして更新してもタグは変わらない", update: &model.Talent{ID: talent.ID, UserID: 2, Name: "タレント", Tags: []model.Tag{otherTag}}, wantErr: sql.ErrNoRows, wantTags: []string{"卒業予定"}},
+		{name: "タグをすべて外す", update: &model.Talent{ID: talent.ID, UserID: 1, Name: "タレント", Scores: testScores(1, 5, 5, 5)}, wantTags: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.update != nil {
+				if err := repo.Update(tt.update); err != tt.wantErr {
+					t.Fatalf("Update() error = %v, want %v", err, tt.wantErr)
+				}
+			}
+
+			found, err := repo.FindByID(talent.ID, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, tag := range found.Tags {
+				got = append(got, tag.Name)
+			}
+			if !slices.Equal(got, tt.wantTags) {
+				t.Errorf("FindByID() tags = %v, want %v", got, tt.wantTags)
+			}
+		})
+	}
+}
+
+func TestTalentRepository_FindByTags(t *testing.T) {
+	db, adjRepo := setupTalentTestDB(t)
+	defer db.Close()
+
+	repo := NewTalentRepository(db, adjRepo, NewScoreDimensionRepository(db))
+	tags := createTestTags(t, db, 1, "推し", "センター", "卒業予定")
+	createTestTags(t, db, 2, "推し")
+
+	talents := []*model.Talent{
+		{UserID: 1, Name: "推しのセンター", Tags: []model.Tag{tags["推し"], tags["センター"]}},
+		{UserID: 1, Name: "推し", Tags: []model.Tag{tags["推し"]}},
+		{UserID: 1, Name: "卒業予定", Tags: []model.Tag{tags["卒業予定"]}},
+		{UserID: 1, Name: "タグなし"},
+	}
+	for _, talent := range talents {
+		talent.Scores = testScores(1, 5, 5, 5)
+		if err := repo.Create(talent); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		userID    int
+		tagNames  []string
+		matchAll  bool
+		wantNames []string
+	}{
+		{name: "1つのタグ", userID: 1, tagNames: []string{"推し"}, wantNames: []string{"推し", "推しのセンター"}},
+		{name: "いずれかのタグ", userID: 1, tagNames: []string{"センター", "卒業予定"}, wantNames: []string{"卒業予定", "推しのセンター"}},
+		{name: "すべてのタグ", userID: 1, tagNames: []string{"推し", "センター"}, matchAll: true, wantNames: []string{"推しのセンター"}},
+		{name: "同じタグの重複指定", userID: 1, tagNames: []string{"推し", "推し"}, matchAll: true, wantNames: []string{"推し", "推しのセンター"}},
+		{name: "存在しないタグを含むすべて", userID: 1, tagNames: []string{"推し", "存在しない"}, matchAll: true, wantNames: nil},
+		{name: "他のユーザーの同名タグ", userID: 2, tagNames: []string{"推し"}, wantNames: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, err := repo.FindByTags(tt.userID, tt.tagNames, tt.matchAll)
+			if err != nil {
+				t.Fatalf("FindByTags() error = %v", err)
+			}
+			var got []string
+			for _, talent := range found {
+				got = append(got, talent.Name)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.wantNames) {
+				t.Errorf("FindByTags() = %v, want %v", got, tt.wantNames)
+			}
+		})
+	}
+}
+
 func TestTalentRepository_ToggleFavorite(t *testing.T) {
 	db, adjRepo := setupTalentTestDB(t)
 	defer db.Close()
 
-	repo := NewTalentRepository(db, adjRepo)
+	repo := NewTalentRepository(db, adjRepo, NewScoreDimensionRepository(db))
 
 	talent := &model.Talent{
-		UserID:   1,
-		Name:     "お気に入りテスト",
-		Beauty:   80,
-		Cuteness: 75,
-		Talent:   90,
+		UserID: 1,
+		Name:   "お気に入りテスト",
+		Scores: testScores(1, 80, 75, 90),
 	}
 	err := repo.Create(talent)
 	if err != nil {
@@ -276,24 +590,26 @@ func TestTalentRepository_Delete(t *testing.T) {
 	db, adjRepo := setupTalentTestDB(t)
 	defer db.Close()
 
-	repo := NewTalentRepository(db, adjRepo)
+	repo := NewTalentRepository(db, adjRepo, NewScoreDimensionRepository(db))
 
 	talent := &model.Talent{
-		UserID:   1,
-		Name:     "削除テスト",
-		Beauty:   80,
-		Cuteness: 75,
-		Talent:   90,
+		UserID:      1,
+		Name:        "削除テスト",
+		Affiliation: sql.NullString{String: "テスト事務所", Valid: true},
+		Scores:      testScores(1, 80, 75, 90),
 	}
 	err := repo.Create(talent)
 	if err != nil {
 		t.Fatal(err)
 	}
+	id := talent.ID
 
-	var id int
-	err = db.QueryRow("SELECT id FROM talents WHERE name = ?", talent.Name).Scan(&id)
+	err = repo.Delete(id, 2)
 	if err != nil {
-		t.Fatal(err)
+		t.Errorf("Delete() with wrong user_id should not error = %v", err)
+	}
+	if _, err := repo.FindByID(id, 1); err != nil {
+		t.Errorf("Delete() with wrong user_id moved talent to trash, FindByID() error = %v", err)
 	}
 
 	err = repo.Delete(id, 1)
@@ -301,17 +617,399 @@ func TestTalentRepository_Delete(t *testing.T) {
 		t.Errorf("Delete() error = %v", err)
 	}
 
-	var count int
-	err = db.QueryRow("SELECT COUNT(*) FROM talents WHERE id = ?", id).Scan(&count)
+	if _, err := repo.FindByID(id, 1); err != sql.ErrNoRows {
+		t.Errorf("FindByID() after Delete() error = %v, want sql.ErrNoRows", err)
+	}
+	if talents, err := repo.FindByUserID(1); err != nil || len(talents) != 0 {
+		t.Errorf("FindByUserID() after Delete() = %v, %v, want none", talents, err)
+	}
+	if exists, err := repo.Exists(id, 1); err != nil || exists {
+		t.Errorf("Exists() after Delete() = %v, %v, want false", exists, err)
+	}
+
+	trash, err := repo.FindTrash(1)
 	if err != nil {
+		t.Fatalf("FindTrash() error = %v", err)
+	}
+	if len(trash) != 1 || trash[0].ID != id || !trash[0].DeletedAt.Valid {
+		t.Fatalf("FindTrash() = %+v, want the deleted talent", trash)
+	}
+	if trash, err := repo.FindTrash(2); err != nil || len(trash) != 0 {
+		t.Errorf("FindTrash() for other user = %v, %v, want none", trash, err)
+	}
+
+	// ごみ箱のタレントは編集できない
+	talent.Name = "編集"
+	if err := repo.Update(talent); err != sql.ErrNoRows {
+		t.Errorf("Update() talent in trash error = %v, want sql.ErrNoRows", err)
+	}
+	var name string
+	if err := db.QueryRow("SELECT name FROM talents WHERE id = ?", id).Scan(&name); err != nil {
 		t.Fatal(err)
 	}
-	if count != 0 {
-		t.Errorf("Delete() did not remove talent, count = %d", count)
+	if name != "削除テスト" {
+		t.Errorf("Update() changed a talent in trash, name = %q", name)
 	}
+}
 
-	err = repo.Delete(id, 2)
+func TestTalentRepository_Restore(t *testing.T) {
+	db, adjRepo := setupTalentTestDB(t)
+	defer db.Close()
+
+	repo := NewTalentRepository(db, adjRepo, NewScoreDimensionRepository(db))
+
+	talent := &model.Talent{UserID: 1, Name: "復元テスト", Scores: testScores(1, 80, 75, 90)}
+	if err := repo.Create(talent); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Restore(talent.ID, 1); err != sql.ErrNoRows {
+		t.Errorf("Restore() not in trash error = %v, want sql.ErrNoRows", err)
+	}
+
+	if err := repo.Delete(talent.ID, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Restore(talent.ID, 2); err != sql.ErrNoRows {
+		t.Errorf("Restore() with wrong user_id error = %v, want sql.ErrNoRows", err)
+	}
+	if err := repo.Restore(talent.ID, 1); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+
+	got, err := repo.FindByID(talent.ID, 1)
 	if err != nil {
-		t.Errorf("Delete() with wrong user_id should not error = %v", err)
+		t.Fatalf("FindByID() after Restore() error = %v", err)
+	}
+	if got.DeletedAt.Valid || len(got.Scores) != 3 || got.Scores[0].Value != 80 {
+		t.Errorf("FindByID() after Restore() = %+v", got)
+	}
+}
+
+func TestTalentRepository_DeletePermanently(t *testing.T) {
+	db, adjRepo := setupTalentTestDB(t)
+	defer db.Close()
+
+	repo := NewTalentRepository(db, adjRepo, NewScoreDimensionRepository(db))
+
+	talent := &model.Talent{
+		UserID:      1,
+		Name:        "完全削除テスト",
+		Affiliation: sql.NullString{String: "テスト事務所", Valid: true},
+		Scores:      testScores(1, 80, 75, 90),
+	}
+	if err := repo.Create(talent); err != nil {
+		t.Fatal(err)
+	}
+	if err := adjRepo.Create(&model.Adjustment{TalentID: talent.ID, DimensionID: 1, Points: 1, Reason: "加点"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.DeletePermanently(talent.ID, 1); err != sql.ErrNoRows {
+		t.Errorf("DeletePermanently() not in trash error = %v, want sql.ErrNoRows", err)
+	}
+
+	if err := repo.Delete(talent.ID, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.DeletePermanently(talent.ID, 2); err != sql.ErrNoRows {
+		t.Errorf("DeletePermanently() with wrong user_id error = %v, want sql.ErrNoRows", err)
+	}
+	if err := repo.DeletePermanently(talent.ID, 1); err != nil {
+		t.Fatalf("DeletePermanently() error = %v", err)
+	}
+
+	for _, table := range []string{"talents", "talent_scores", "adjustments", "affiliations", "talent_revisions"} {
+		var count int
+		if err := db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		if count != 0 {
+			t.Errorf("DeletePermanently() left %d rows in %s", count, table)
+		}
+	}
+}
+
+func TestTalentRepository_PurgeTrash(t *testing.T) {
+	db, adjRepo := setupTalentTestDB(t)
+	defer db.Close()
+
+	repo := NewTalentRepository(db, adjRepo, NewScoreDimensionRepository(db))
+
+	now := time.Now()
+	talents := []struct {
+		talent    *model.Talent
+		deletedAt time.Time // ゼロ値の場合はごみ箱に移さない
+	}{
+		{talent: &model.Talent{UserID: 1, Name: "古いごみ箱", Affiliation: sql.NullString{String: "事務所A", Valid: true}, Scores: testScores(1, 5, 5, 5)}, deletedAt: now.AddDate(0, 0, -31)},
+		{talent: &model.Talent{UserID: 2, Name: "他のユーザーの古いごみ箱", Scores: testScores(4, 5, 5, 5)}, deletedAt: now.AddDate(0, 0, -40)},
+		{talent: &model.Talent{UserID: 1, Name: "新しいごみ箱", Scores: testScores(1, 5, 5, 5)}, deletedAt: now.AddDate(0, 0, -1)},
+		{talent: &model.Talent{UserID: 1, Name: "ごみ箱にない", Scores: testScores(1, 5, 5, 5)}},
+	}
+	for _, tt := range talents {
+		if err := repo.Create(tt.talent); err != nil {
+			t.Fatal(err)
+		}
+		if !tt.deletedAt.IsZero() {
+			_, err := db.Exec("UPDATE talents SET deleted_at = ? WHERE id = ?", tt.deletedAt.UTC().Format(sqliteTimeLayout), tt.talent.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// ごみ箱を削除していないユーザーの所属には触れない
+	if _, err := db.Exec("INSERT INTO affiliations (user_id, name, name_key) VALUES (3, '未使用', '未使用')"); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := repo.PurgeTrash(now.AddDate(0, 0, -30))
+	if err != nil {
+		t.Fatalf("PurgeTrash() error = %v", err)
+	}
+	if n != 2 {
+		t.Errorf("PurgeTrash() = %d, want 2", n)
+	}
+
+	rows, err := db.Query("SELECT name FROM talents ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, name)
+	}
+	if want := []string{"新しいごみ箱", "ごみ箱にない"}; !slices.Equal(got, want) {
+		t.Errorf("talents after PurgeTrash() = %v, want %v", got, want)
+	}
+
+	var affiliations []string
+	rows, err = db.Query("SELECT name FROM affiliations ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		affiliations = append(affiliations, name)
+	}
+	if want := []string{"未使用"}; !slices.Equal(affiliations, want) {
+		t.Errorf("affiliations after PurgeTrash() = %v, want %v", affiliations, want)
+	}
+}
+
+func TestTalentRepository_FindPage(t *testing.T) {
+	db, adjRepo := setupTalentTestDB(t)
+	defer db.Close()
+
+	repo := NewTalentRepository(db, adjRepo, NewScoreDimensionRepository(db))
+
+	// 美しさ: い=3, ろ=9, は=未評価, に=5(+4で9), ほ=1
+	unrated := testScores(2, 5, 5)
+	talents := []*model.Talent{
+		{UserID: 1, Name: "い", Scores: testScores(1, 3, 5, 5)},
+		{UserID: 1, Name: "ろ", Scores: testScores(1, 9, 5, 5)},
+		{UserID: 1, Name: "は", Scores: unrated},
+		{UserID: 1, Name: "に", Scores: testScores(1, 5, 5, 5)},
+		{UserID: 1, Name: "ほ", Scores: testScores(1, 1, 5, 5)},
+		{UserID: 2, Name: "他のユーザー", Scores: testScores(4, 5, 5, 5)},
+	}
+	for _, talent := range talents {
+		if err := repo.Create(talent); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := adjRepo.Create(&model.Adjustment{TalentID: talents[3].ID, DimensionID: 1, Points: 4, Reason: "加点"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.ToggleFavorite(talents[4].ID, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query TalentQuery
+		want  []string
+	}{
+		{name: "既定の並び順はお気に入りが先頭", query: TalentQuery{}, want: []string{"ほ", "に", "は", "ろ", "い"}},
+		{name: "名前の昇順", query: TalentQuery{Sort: TalentSort{Column: SortName}}, want: []string{"い", "に", "は", "ほ", "ろ"}},
+		{name: "名前の降順", query: TalentQuery{Sort: TalentSort{Column: SortName, Desc: true}}, want: []string{"ろ", "ほ", "は", "に", "い"}},
+		{name: "登録順", query: TalentQuery{Sort: TalentSort{Column: SortCreatedAt}}, want: []string{"い", "ろ", "は", "に", "ほ"}},
+		{name: "スコアの降順で同点は後に登録した順、未評価は最後", query: TalentQuery{Sort: TalentSort{Column: SortScore, DimensionID: 1, Desc: true}}, want: []string{"に", "ろ", "い", "ほ", "は"}},
+		{name: "スコアの昇順でも未評価は最後", query: TalentQuery{Sort: TalentSort{Column: SortScore, DimensionID: 1}}, want: []string{"ほ", "い", "ろ", "に", "は"}},
+		{name: "絞り込みと組み合わせる", query: TalentQuery{Filter: TalentFilter{Search: "ほ", FavoriteOnly: true}}, want: []string{"ほ"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 2件ずつ最後のページまで進む
+			query := tt.query
+			query.Limit = 2
+			var got []string
+			var pages []*TalentPage
+			for {
+				page, err := repo.FindPage(1, query)
+				if err != nil {
+					t.Fatalf("FindPage() error = %v", err)
+				}
+				if page.TotalCount != len(tt.want) {
+					t.Errorf("FindPage() TotalCount = %d, want %d", page.TotalCount, len(tt.want))
+				}
+				for _, talent := range page.Talents {
+					got = append(got, talent.Name)
+				}
+				pages = append(pages, page)
+				if page.NextCursor == "" {
+					break
+				}
+				query.After, query.Before = page.NextCursor, ""
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("FindPage() forward = %v, want %v", got, tt.want)
+			}
+			if pages[0].PrevCursor != "" {
+				t.Errorf("FindPage() first page PrevCursor = %q, want empty", pages[0].PrevCursor)
+			}
+
+			// 最後のページから前のページに戻ると同じ区切りになる
+			for i := len(pages) - 1; i > 0; i-- {
+				query.After, query.Before = "", pages[i].PrevCursor
+				page, err := repo.FindPage(1, query)
+				if err != nil {
+					t.Fatalf("FindPage() error = %v", err)
+				}
+				var names, want []string
+				for _, talent := range page.Talents {
+					names = append(names, talent.Name)
+				}
+				for _, talent := range pages[i-1].Talents {
+					want = append(want, talent.Name)
+				}
+				if !slices.Equal(names, want) {
+					t.Errorf("FindPage() backward page %d = %v, want %v", i-1, names, want)
+				}
+				if (page.PrevCursor == "") != (i == 1) {
+					t.Errorf("FindPage() backward page %d PrevCursor = %q", i-1, page.PrevCursor)
+				}
+				if page.NextCursor == "" {
+					t.Errorf("FindPage() backward page %d NextCursor is empty", i-1)
+				}
+			}
+		})
+	}
+
+	t.Run("すべて取得", func(t *testing.T) {
+		page, err := repo.FindPage(1, TalentQuery{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Talents) != 5 || page.NextCursor != "" || page.Talents[0].Scores == nil {
+			t.Errorf("FindPage() without limit = %d talents, NextCursor %q", len(page.Talents), page.NextCursor)
+		}
+	})
+
+	t.Run("不正なカーソル", func(t *testing.T) {
+		for _, cursor := range []string{"!!!", "W10", "WyJhIl0"} {
+			if _, err := repo.FindPage(1, TalentQuery{After: cursor, Limit: 2}); err != ErrInvalidCursor {
+				t.Errorf("FindPage(After: %q) error = %v, want %v", cursor, err, ErrInvalidCursor)
+			}
+		}
+	})
+}
+
+func TestTalentRepository_FindPage_Filter(t *testing.T) {
+	db, adjRepo := setupTalentTestDB(t)
+	defer db.Close()
+
+	repo := NewTalentRepository(db, adjRepo, NewScoreDimensionRepository(db))
+	tags := createTestTags(t, db, 1, "推し")
+
+	// 美しさ: い=3, ろ=9, は=未評価, に=5(+4で9)
+	talents := []*model.Talent{
+		{UserID: 1, Name: "い", Affiliation: sql.NullString{String: "乃木坂46", Valid: true}, Scores: testScores(1, 3, 5, 5), Tags: []model.Tag{tags["推し"]}},
+		{UserID: 1, Name: "ろ", Affiliation: sql.NullString{String: "櫻坂46", Valid: true}, Scores: testScores(1, 9, 5, 5)},
+		{UserID: 1, Name: "は", Affiliation: sql.NullString{String: "乃木坂46", Valid: true}, Scores: testScores(2, 5, 5)},
+		{UserID: 1, Name: "に", Scores: testScores(1, 5, 1, 5), Tags: []model.Tag{tags["推し"]}},
+		{UserID: 2, Name: "他のユーザー", Affiliation: sql.NullString{String: "乃木坂46", Valid: true}, Scores: testScores(4, 5, 5, 5)},
+	}
+	for i, talent := range talents {
+		if err := repo.Create(talent); err != nil {
+			t.Fatal(err)
+		}
+		// 登録日時を1日ずつずらす
+		createdAt := time.Date(2025, 4, 1+i, 12, 0, 0, 0, time.UTC).Format(sqliteTimeLayout)
+		if _, err := db.Exec("UPDATE talents SET created_at = ? WHERE id = ?", createdAt, talent.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := adjRepo.Create(&model.Adjustment{TalentID: talents[3].ID, DimensionID: 1, Points: 4, Reason: "加点"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.ToggleFavorite(talents[1].ID, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.ToggleFavorite(talents[3].ID, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	score := func(min, max int) ScoreRange {
+		r := ScoreRange{DimensionID: 1}
+		if min >= 0 {
+			r.Min = sql.NullInt64{Int64: int64(min), Valid: true}
+		}
+		if max >= 0 {
+			r.Max = sql.NullInt64{Int64: int64(max), Valid: true}
+		}
+		return r
+	}
+
+	tests := []struct {
+		name   string
+		filter TalentFilter
+		want   []string
+	}{
+		{name: "条件なし", filter: TalentFilter{}, want: []string{"い", "ろ", "は", "に"}},
+		{name: "所属", filter: TalentFilter{AffiliationID: int(talents[0].AffiliationID.Int64)}, want: []string{"い", "は"}},
+		{name: "スコアの下限は調整後の値で比べる", filter: TalentFilter{Scores: []ScoreRange{score(9, -1)}}, want: []string{"ろ", "に"}},
+		{name: "スコアの範囲", filter: TalentFilter{Scores: []ScoreRange{score(3, 5)}}, want: []string{"い"}},
+		{name: "スコアの範囲を指定すると未評価は含めない", filter: TalentFilter{Scores: []ScoreRange{score(0, 10)}}, want: []string{"い", "ろ", "に"}},
+		{name: "複数の評価軸の範囲", filter: TalentFilter{Scores: []ScoreRange{score(5, -1), {DimensionID: 2, Max: sql.NullInt64{Int64: 3, Valid: true}}}}, want: []string{"に"}},
+		{name: "登録日の範囲", filter: TalentFilter{
+			CreatedFrom:   time.Date(2025, 4, 2, 0, 0, 0, 0, time.UTC),
+			CreatedBefore: time.Date(2025, 4, 4, 0, 0, 0, 0, time.UTC),
+		}, want: []string{"ろ", "は"}},
+		{name: "お気に入りの中で検索", filter: TalentFilter{Search: "ろ", FavoriteOnly: true}, want: []string{"ろ"}},
+		{name: "お気に入りとタグとスコア", filter: TalentFilter{FavoriteOnly: true, Tags: []string{"推し"}, Scores: []ScoreRange{score(9, -1)}}, want: []string{"に"}},
+		{name: "一致しない組み合わせ", filter: TalentFilter{AffiliationID: int(talents[0].AffiliationID.Int64), FavoriteOnly: true}, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := repo.FindPage(1, TalentQuery{Filter: tt.filter, Sort: TalentSort{Column: SortCreatedAt}})
+			if err != nil {
+				t.Fatalf("FindPage() error = %v", err)
+			}
+			var got []string
+			for _, talent := range page.Talents {
+				got = append(got, talent.Name)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("FindPage() = %v, want %v", got, tt.want)
+			}
+			if page.TotalCount != len(tt.want) {
+				t.Errorf("FindPage() TotalCount = %d, want %d", page.TotalCount, len(tt.want))
+			}
+			if count, err := repo.Count(1, tt.filter); err != nil || count != len(tt.want) {
+				t.Errorf("Count() = %d, %v, want %d", count, err, len(tt.want))
+			}
+		})
 	}
 }
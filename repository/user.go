@@ -2,8 +2,12 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
+	"time"
 
 	"github.com/Kamekure-Maisuke/maiyumi/model"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
 )
 
 type UserRepository interface {
@@ -13,25 +17,106 @@ type UserRepository interface {
 	UpdateUsername(userID int, newUsername string) error
 	UpdatePassword(userID int, newPassword string) error
 	FindByID(userID int) (*model.User, error)
+	EnableTOTP(userID int, secret string, recoveryCodeHashes []string) error
+	DisableTOTP(userID int) error
+	UseTOTPStep(userID int, step int64) (bool, error)
+	UseRecoveryCode(userID int, codeHash string) (bool, error)
+	CountRecoveryCodes(userID int) (int, error)
+	Delete(userID int) error
 }
 
 type userRepository struct {
-	db *sql.DB
+	db  *sql.DB
+	now func() time.Time
 }
 
 func NewUserRepository(db *sql.DB) UserRepository {
-	return &userRepository{db: db}
+	return &userRepository{db: db, now: time.Now}
 }
 
+// isUniqueViolation はUNIQUE制約違反のエラーか判定する。
+func isUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
+
+// Create はユーザー名を正規化して登録し、初期の評価軸を用意する。
+// 規則に合わない場合は *UsernameError、既に使われている場合は ErrDuplicateUsername を返す。
 func (r *userRepository) Create(username, password string) error {
-	_, err := r.db.Exec("INSERT INTO users (username, password) VALUES (?, ?)", username, password)
-	return err
+	username, err := NormalizeUsername(username)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("INSERT INTO users (username, password) VALUES (?, ?)", username, password)
+	if isUniqueViolation(err) {
+		return ErrDuplicateUsername
+	}
+	if err != nil {
+		return err
+	}
+
+	userID, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	if err := createDefaultScoreDimensions(tx, int(userID)); err != nil {
+		return err
+	}
+	if err := recordUserAudit(tx, int(userID), model.AuditActionCreate, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
+// updateUser はユーザーを更新する SQL を実行し、変更前後の内容を監査ログに記録する。
+func (r *userRepository) updateUser(userID int, update func(tx *sql.Tx) error) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := userSnapshot(tx, userID)
+	if err != nil {
+		return err
+	}
+	if err := update(tx); err != nil {
+		return err
+	}
+	if err := recordUserAudit(tx, userID, model.AuditActionUpdate, before); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// recordUserAudit はユーザー自身による変更を監査ログに記録する。before は作成した場合は nil にする。
+func recordUserAudit(tx *sql.Tx, userID int, action string, before auditSnapshot) error {
+	after, err := userSnapshot(tx, userID)
+	if err != nil {
+		return err
+	}
+	return recordAudit(tx, auditEntry{
+		userID: userID, actorID: actor(userID),
+		entityType: model.AuditEntityUser, entityID: userID, action: action,
+		before: before, after: after,
+	})
+}
+
+// FindByUsername は正規化したユーザー名で、大文字小文字を区別せずに検索する。
+// 規則に合わない既存のユーザー名も検索できる。
 func (r *userRepository) FindByUsername(username string) (*model.User, error) {
 	var user model.User
-	err := r.db.QueryRow("SELECT id, username, password, created_at FROM users WHERE username = ?", username).
-		Scan(&user.ID, &user.Username, &user.Password, &user.CreatedAt)
+	err := r.db.QueryRow("SELECT id, username, password, totp_secret, created_at FROM users WHERE username = ? COLLATE NOCASE", lookupUsername(username)).
+		Scan(&user.ID, &user.Username, &user.Password, &user.TOTPSecret, &user.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -40,26 +125,160 @@ func (r *userRepository) FindByUsername(username string) (*model.User, error) {
 
 func (r *userRepository) GetID(username string) (int, error) {
 	var id int
-	err := r.db.QueryRow("SELECT id FROM users WHERE username = ?", username).Scan(&id)
+	err := r.db.QueryRow("SELECT id FROM users WHERE username = ? COLLATE NOCASE", lookupUsername(username)).Scan(&id)
 	return id, err
 }
 
+// UpdateUsername は Create と同じ規則でユーザー名を変更する。
 func (r *userRepository) UpdateUsername(userID int, newUsername string) error {
-	_, err := r.db.Exec("UPDATE users SET username = ? WHERE id = ?", newUsername, userID)
-	return err
+	newUsername, err := NormalizeUsername(newUsername)
+	if err != nil {
+		return err
+	}
+
+	return r.updateUser(userID, func(tx *sql.Tx) error {
+		_, err := tx.Exec("UPDATE users SET username = ? WHERE id = ?", newUsername, userID)
+		if isUniqueViolation(err) {
+			return ErrDuplicateUsername
+		}
+		return err
+	})
 }
 
+// UpdatePassword はパスワードのハッシュを書き換える。監査ログにはハッシュを残さず、変更したことだけを記録する。
 func (r *userRepository) UpdatePassword(userID int, newPassword string) error {
-	_, err := r.db.Exec("UPDATE users SET password = ? WHERE id = ?", newPassword, userID)
-	return err
+	return r.updateUser(userID, func(tx *sql.Tx) error {
+		_, err := tx.Exec("UPDATE users SET password = ? WHERE id = ?", newPassword, userID)
+		return err
+	})
 }
 
 func (r *userRepository) FindByID(userID int) (*model.User, error) {
 	var user model.User
-	err := r.db.QueryRow("SELECT id, username, password, created_at FROM users WHERE id = ?", userID).
-		Scan(&user.ID, &user.Username, &user.Password, &user.CreatedAt)
+	err := r.db.QueryRow("SELECT id, username, password, totp_secret, created_at FROM users WHERE id = ?", userID).
+		Scan(&user.ID, &user.Username, &user.Password, &user.TOTPSecret, &user.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
+
+// EnableTOTP は二要素認証を有効にし、リカバリーコードを入れ替える。
+func (r *userRepository) EnableTOTP(userID int, secret string, recoveryCodeHashes []string) error {
+	return r.updateUser(userID, func(tx *sql.Tx) error {
+		if _, err := tx.Exec("UPDATE users SET totp_secret = ?, totp_last_step = 0 WHERE id = ?", secret, userID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID); err != nil {
+			return err
+		}
+		for _, hash := range recoveryCodeHashes {
+			if _, err := tx.Exec("INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)", userID, hash); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DisableTOTP は二要素認証を無効にし、リカバリーコードを削除する。
+func (r *userRepository) DisableTOTP(userID int) error {
+	return r.updateUser(userID, func(tx *sql.Tx) error {
+		if _, err := tx.Exec("UPDATE users SET totp_secret = '', totp_last_step = 0 WHERE id = ?", userID); err != nil {
+			return err
+		}
+		_, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID)
+		return err
+	})
+}
+
+// UseTOTPStep はワンタイムパスワードのステップ番号を使用済みにする。
+// 同じか古いステップが既に使われていた場合は false を返す（コードの再利用防止）。
+func (r *userRepository) UseTOTPStep(userID int, step int64) (bool, error) {
+	result, err := r.db.Exec("UPDATE users SET totp_last_step = ? WHERE id = ? AND totp_last_step < ?", step, userID, step)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// UseRecoveryCode は未使用のリカバリーコードを使用済みにする。一致するコードがなければ false を返す。
+func (r *userRepository) UseRecoveryCode(userID int, codeHash string) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE recovery_codes SET used_at = ?
+		WHERE user_id = ? AND code_hash = ? AND used_at IS NULL`,
+		r.now().UTC().Format(sqliteTimeLayout), userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// CountRecoveryCodes は未使用のリカバリーコードの数を返す。
+func (r *userRepository) CountRecoveryCodes(userID int) (int, error) {
+	var count int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM recovery_codes WHERE user_id = ? AND used_at IS NULL", userID).Scan(&count)
+	return count, err
+}
+
+// Delete はユーザーと、そのユーザーのタレント・所属・評価軸・タグ・調整履歴・タレントの版・リカバリーコード・監査ログ・
+// セッション・ログイン試行の記録を1つのトランザクションで削除する。
+// 外部キー制約が無効なDBでも残らないよう、関連する行は明示的に削除する。
+// 削除したことだけは、ユーザー名などを含めずに監査ログに残す。
+func (r *userRepository) Delete(userID int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// ログイン試行の記録はユーザーIDを持たないため、ユーザー名で削除する
+	var username string
+	if err := tx.QueryRow("SELECT username FROM users WHERE id = ?", userID).Scan(&username); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM login_attempts WHERE username = ? COLLATE NOCASE", username); err != nil {
+		return err
+	}
+
+	statements := []string{
+		"DELETE FROM adjustments WHERE talent_id IN (SELECT id FROM talents WHERE user_id = ?)",
+		"DELETE FROM talent_scores WHERE talent_id IN (SELECT id FROM talents WHERE user_id = ?)",
+		"DELETE FROM talent_tags WHERE talent_id IN (SELECT id FROM talents WHERE user_id = ?)",
+		"DELETE FROM talent_revisions WHERE user_id = ?",
+		"DELETE FROM talents WHERE user_id = ?",
+		"DELETE FROM affiliations WHERE user_id = ?",
+		"DELETE FROM score_dimensions WHERE user_id = ?",
+		"DELETE FROM tags WHERE user_id = ?",
+		"DELETE FROM saved_searches WHERE user_id = ?",
+		"DELETE FROM recovery_codes WHERE user_id = ?",
+		"DELETE FROM audit_events WHERE user_id = ?",
+		"DELETE FROM sessions WHERE user_id = ?",
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt, userID); err != nil {
+			return err
+		}
+	}
+
+	result, err := tx.Exec("DELETE FROM users WHERE id = ?", userID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	err = recordAudit(tx, auditEntry{
+		userID: userID, actorID: actor(userID),
+		entityType: model.AuditEntityUser, entityID: userID, action: model.AuditActionDelete,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -2,8 +2,12 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/Kamekure-Maisuke/maiyumi/model"
 	_ "modernc.org/sqlite"
 )
 
@@ -12,14 +16,113 @@ func setupUserTestDB(t *testing.T) *sql.DB {
 	if err != nil {
 		t.Fatal(err)
 	}
+	db.SetMaxOpenConns(1)
 
 	_, err = db.Exec(`
 		CREATE TABLE users (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			username TEXT NOT NULL UNIQUE,
+			username TEXT NOT NULL UNIQUE COLLATE NOCASE,
 			password TEXT NOT NULL,
+			totp_secret TEXT NOT NULL DEFAULT '',
+			totp_last_step INTEGER NOT NULL DEFAULT 0,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)
+		);
+		CREATE TABLE recovery_codes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			code_hash TEXT NOT NULL,
+			used_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		);
+		CREATE TABLE affiliations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		);
+		CREATE TABLE talents (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			affiliation_id INTEGER,
+			FOREIGN KEY (user_id) REFERENCES users(id),
+			FOREIGN KEY (affiliation_id) REFERENCES affiliations(id)
+		);
+		CREATE TABLE score_dimensions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			min_score INTEGER NOT NULL,
+			max_score INTEGER NOT NULL,
+			display_order INTEGER NOT NULL DEFAULT 0,
+			FOREIGN KEY (user_id) REFERENCES users(id),
+			UNIQUE (user_id, name)
+		);
+		CREATE TABLE talent_scores (
+			talent_id INTEGER NOT NULL,
+			dimension_id INTEGER NOT NULL,
+			score INTEGER NOT NULL,
+			FOREIGN KEY (talent_id) REFERENCES talents(id),
+			FOREIGN KEY (dimension_id) REFERENCES score_dimensions(id)
+		);
+		CREATE TABLE adjustments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			talent_id INTEGER NOT NULL,
+			FOREIGN KEY (talent_id) REFERENCES talents(id) ON DELETE CASCADE
+		);
+		CREATE TABLE audit_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			actor_id INTEGER,
+			entity_type TEXT NOT NULL,
+			entity_id INTEGER NOT NULL,
+			action TEXT NOT NULL,
+			changes TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE talent_revisions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			talent_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			affiliation TEXT NOT NULL,
+			scores TEXT NOT NULL,
+			tags TEXT NOT NULL,
+			reverted_from INTEGER,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE tags (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		);
+		CREATE TABLE talent_tags (
+			talent_id INTEGER NOT NULL,
+			tag_id INTEGER NOT NULL,
+			FOREIGN KEY (talent_id) REFERENCES talents(id),
+			FOREIGN KEY (tag_id) REFERENCES tags(id)
+		);
+		CREATE TABLE saved_searches (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			query TEXT NOT NULL,
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		);
+		CREATE TABLE sessions (
+			id TEXT PRIMARY KEY,
+			user_id INTEGER NOT NULL
+		);
+		CREATE TABLE login_attempts (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			username TEXT NOT NULL,
+			ip_address TEXT NOT NULL,
+			succeeded BOOLEAN NOT NULL,
+			cleared BOOLEAN NOT NULL DEFAULT 0
+		);
+		PRAGMA foreign_keys = ON;
 	`)
 	if err != nil {
 		t.Fatal(err)
@@ -38,30 +141,102 @@ func TestUserRepository_Create(t *testing.T) {
 		name     string
 		username string
 		password string
-		wantErr  bool
+		wantErr  error
 	}{
 		{
 			name:     "正常なユーザー作成",
 			username: "testuser",
 			password: "password123",
-			wantErr:  false,
+			wantErr:  nil,
 		},
 		{
 			name:     "重複ユーザー名",
 			username: "testuser",
 			password: "password456",
-			wantErr:  true,
+			wantErr:  ErrDuplicateUsername,
+		},
+		{
+			name:     "大文字小文字だけが異なる重複",
+			username: "TestUser",
+			password: "password456",
+			wantErr:  ErrDuplicateUsername,
+		},
+		{
+			name:     "全角英字は正規化して重複を判定",
+			username: "　ｔｅｓｔｕｓｅｒ　",
+			password: "password456",
+			wantErr:  ErrDuplicateUsername,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			err := repo.Create(tt.username, tt.password)
-			if (err != nil) != tt.wantErr {
+			if !errors.Is(err, tt.wantErr) {
 				t.Errorf("Create() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
+
+	// 登録に成功したユーザーにだけ初期の評価軸が作られる
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM score_dimensions").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != len(model.DefaultScoreDimensions) {
+		t.Errorf("Create() score_dimensions = %d, want %d", count, len(model.DefaultScoreDimensions))
+	}
+}
+
+func TestUserRepository_CreateInvalidUsername(t *testing.T) {
+	db := setupUserTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db)
+
+	for _, username := range []string{"", "   ", "a", "user name", "аdmin"} {
+		err := repo.Create(username, "password123")
+		var usernameErr *UsernameError
+		if !errors.As(err, &usernameErr) {
+			t.Errorf("Create(%q) error = %v, want *UsernameError", username, err)
+		}
+	}
+}
+
+func TestNormalizeUsername(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		want     string
+		wantErr  bool
+	}{
+		{name: "英数字", username: "alice_01", want: "alice_01"},
+		{name: "前後の空白を除去", username: "  alice  ", want: "alice"},
+		{name: "全角英数字を半角に", username: "ａｌｉｃｅ１", want: "alice1"},
+		{name: "半角カナを全角に", username: "ﾏｲﾕﾐ", want: "マイユミ"},
+		{name: "大文字は保持", username: "Alice", want: "Alice"},
+		{name: "日本語", username: "山田たろう", want: "山田たろう"},
+		{name: "空白のみ", username: "　 ", wantErr: true},
+		{name: "短すぎる", username: "a", wantErr: true},
+		{name: "長すぎる", username: "abcdefghijklmnopqrstuvwxyz1234567", wantErr: true},
+		{name: "途中に空白", username: "al ice", wantErr: true},
+		{name: "記号", username: "alice!", wantErr: true},
+		{name: "紛らわしいキリル文字", username: "аlice", wantErr: true},
+		{name: "制御文字", username: "ali\u200bce", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeUsername(tt.username)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NormalizeUsername() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeUsername() = %v, want %v", got, tt.want)
+			}
+		})
+	}
 }
 
 func TestUserRepository_FindByUsername(t *testing.T) {
@@ -87,6 +262,11 @@ func TestUserRepository_FindByUsername(t *testing.T) {
 			username: "testuser",
 			wantErr:  false,
 		},
+		{
+			name:     "大文字小文字を区別しない",
+			username: "TESTUSER",
+			wantErr:  false,
+		},
 		{
 			name:     "存在しないユーザー",
 			username: "nonexistent",
@@ -113,6 +293,32 @@ func TestUserRepository_FindByUsername(t *testing.T) {
 	}
 }
 
+func TestUserRepository_FindByUsername_LegacyName(t *testing.T) {
+	db := setupUserTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db)
+
+	// 規則の導入前に登録された名前は Create を通さずに直接入れる
+	for _, username := range []string{"a", "john doe", "me@example"} {
+		if _, err := db.Exec("INSERT INTO users (username, password) VALUES (?, ?)", username, "hash"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, username := range []string{"a", " John Doe ", "ME@example"} {
+		user, err := repo.FindByUsername(username)
+		if err != nil {
+			t.Errorf("FindByUsername(%q) error = %v", username, err)
+			continue
+		}
+		id, err := repo.GetID(username)
+		if err != nil || id != user.ID {
+			t.Errorf("GetID(%q) = %v, %v, want %v", username, id, err, user.ID)
+		}
+	}
+}
+
 func TestUserRepository_GetID(t *testing.T) {
 	db := setupUserTestDB(t)
 	defer db.Close()
@@ -173,23 +379,60 @@ func TestUserRepository_UpdateUsername(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	if err := repo.Create("otheruser", "password123"); err != nil {
+		t.Fatal(err)
+	}
+
 	tests := []struct {
-		name        string
-		userID      int
-		newUsername string
-		wantErr     bool
+		name          string
+		userID        int
+		newUsername   string
+		wantErr       bool
+		wantDuplicate bool
+		wantValue     string
 	}{
 		{
 			name:        "正常なユーザー名更新",
 			userID:      userID,
 			newUsername: "newusername",
 			wantErr:     false,
+			wantValue:   "newusername",
+		},
+		{
+			name:        "自分のユーザー名の大文字小文字だけを変更",
+			userID:      userID,
+			newUsername: "NewUserName",
+			wantErr:     false,
+			wantValue:   "NewUserName",
+		},
+		{
+			name:        "正規化して保存",
+			userID:      userID,
+			newUsername: " ｎｅｗｕｓｅｒ ",
+			wantErr:     false,
+			wantValue:   "newuser",
+		},
+		{
+			name:          "他のユーザーと重複",
+			userID:        userID,
+			newUsername:   "OtherUser",
+			wantErr:       true,
+			wantDuplicate: true,
+		},
+		{
+			name:        "規則に合わない",
+			userID:      userID,
+			newUsername: "new user",
+			wantErr:     true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			err := repo.UpdateUsername(tt.userID, tt.newUsername)
+			if tt.wantDuplicate && !errors.Is(err, ErrDuplicateUsername) {
+				t.Errorf("UpdateUsername() error = %v, want %v", err, ErrDuplicateUsername)
+			}
 			if (err != nil) != tt.wantErr {
 				t.Errorf("UpdateUsername() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -199,8 +442,8 @@ func TestUserRepository_UpdateUsername(t *testing.T) {
 					t.Errorf("FindByID() error = %v", err)
 					return
 				}
-				if user.Username != tt.newUsername {
-					t.Errorf("UpdateUsername() username = %v, want %v", user.Username, tt.newUsername)
+				if user.Username != tt.wantValue {
+					t.Errorf("UpdateUsername() username = %v, want %v", user.Username, tt.wantValue)
 				}
 			}
 		})
@@ -311,3 +554,287 @@ func TestUserRepository_FindByID(t *testing.T) {
 		})
 	}
 }
+
+func TestUserRepository_EnableAndDisableTOTP(t *testing.T) {
+	db := setupUserTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db)
+
+	if err := repo.Create("testuser", "password123"); err != nil {
+		t.Fatal(err)
+	}
+	userID, err := repo.GetID("testuser")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.EnableTOTP(userID, "SECRET", []string{"hash1", "hash2", "hash3"}); err != nil {
+		t.Fatalf("EnableTOTP() error = %v", err)
+	}
+
+	user, err := repo.FindByID(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.TOTPSecret != "SECRET" {
+		t.Errorf("EnableTOTP() secret = %v, want %v", user.TOTPSecret, "SECRET")
+	}
+	if count, _ := repo.CountRecoveryCodes(userID); count != 3 {
+		t.Errorf("CountRecoveryCodes() = %v, want %v", count, 3)
+	}
+
+	// 再登録するとリカバリーコードは入れ替わる
+	if err := repo.EnableTOTP(userID, "SECRET2", []string{"hash4"}); err != nil {
+		t.Fatalf("EnableTOTP() error = %v", err)
+	}
+	if count, _ := repo.CountRecoveryCodes(userID); count != 1 {
+		t.Errorf("CountRecoveryCodes() after re-enroll = %v, want %v", count, 1)
+	}
+
+	if err := repo.DisableTOTP(userID); err != nil {
+		t.Fatalf("DisableTOTP() error = %v", err)
+	}
+	user, err = repo.FindByID(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.TOTPSecret != "" {
+		t.Errorf("DisableTOTP() secret = %v, want empty", user.TOTPSecret)
+	}
+	if count, _ := repo.CountRecoveryCodes(userID); count != 0 {
+		t.Errorf("CountRecoveryCodes() after disable = %v, want %v", count, 0)
+	}
+}
+
+func TestUserRepository_UseTOTPStep(t *testing.T) {
+	db := setupUserTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db)
+
+	if err := repo.Create("testuser", "password123"); err != nil {
+		t.Fatal(err)
+	}
+	userID, err := repo.GetID("testuser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.EnableTOTP(userID, "SECRET", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		step int64
+		want bool
+	}{
+		{name: "初回のステップ", step: 100, want: true},
+		{name: "同じステップの再利用", step: 100, want: false},
+		{name: "古いステップ", step: 99, want: false},
+		{name: "新しいステップ", step: 101, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.UseTOTPStep(userID, tt.step)
+			if err != nil {
+				t.Fatalf("UseTOTPStep() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("UseTOTPStep(%d) = %v, want %v", tt.step, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserRepository_UseRecoveryCode(t *testing.T) {
+	db := setupUserTestDB(t)
+	defer db.Close()
+
+	clock := &fakeClock{t: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	repo := NewUserRepository(db).(*userRepository)
+	repo.now = clock.Now
+
+	if err := repo.Create("testuser", "password123"); err != nil {
+		t.Fatal(err)
+	}
+	userID, err := repo.GetID("testuser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.EnableTOTP(userID, "SECRET", []string{"hash1", "hash2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		userID   int
+		codeHash string
+		want     bool
+	}{
+		{name: "未使用のコード", userID: userID, codeHash: "hash1", want: true},
+		{name: "使用済みのコード", userID: userID, codeHash: "hash1", want: false},
+		{name: "存在しないコード", userID: userID, codeHash: "unknown", want: false},
+		{name: "他のユーザーのコード", userID: 9999, codeHash: "hash2", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.UseRecoveryCode(tt.userID, tt.codeHash)
+			if err != nil {
+				t.Fatalf("UseRecoveryCode() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("UseRecoveryCode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	var usedAt time.Time
+	if err := db.QueryRow("SELECT used_at FROM recovery_codes WHERE code_hash = 'hash1'").Scan(&usedAt); err != nil {
+		t.Fatal(err)
+	}
+	if !usedAt.Equal(clock.Now()) {
+		t.Errorf("UseRecoveryCode() used_at = %v, want %v", usedAt, clock.Now())
+	}
+	if count, _ := repo.CountRecoveryCodes(userID); count != 1 {
+		t.Errorf("CountRecoveryCodes() = %v, want %v", count, 1)
+	}
+}
+
+func TestUserRepository_Delete(t *testing.T) {
+	db := setupUserTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db)
+
+	// 削除するユーザーと残すユーザーにそれぞれタレントと調整履歴を作る
+	var userIDs []int
+	for _, username := range []string{"deleteme", "keepme"} {
+		if err := repo.Create(username, "password123"); err != nil {
+			t.Fatal(err)
+		}
+		userID, err := repo.GetID(username)
+		if err != nil {
+			t.Fatal(err)
+		}
+		userIDs = append(userIDs, userID)
+
+		result, err := db.Exec("INSERT INTO affiliations (user_id, name) VALUES (?, ?)", userID, "事務所")
+		if err != nil {
+			t.Fatal(err)
+		}
+		affiliationID, _ := result.LastInsertId()
+		result, err = db.Exec("INSERT INTO talents (user_id, name, affiliation_id) VALUES (?, ?, ?)", userID, "タレント", affiliationID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		talentID, _ := result.LastInsertId()
+		if _, err := db.Exec("INSERT INTO adjustments (talent_id) VALUES (?), (?)", talentID, talentID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec("INSERT INTO talent_scores (talent_id, dimension_id, score) SELECT ?, id, 5 FROM score_dimensions WHERE user_id = ?", talentID, userID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec("INSERT INTO tags (user_id, name) VALUES (?, 'センター')", userID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec("INSERT INTO talent_tags (talent_id, tag_id) SELECT ?, id FROM tags WHERE user_id = ?", talentID, userID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec("INSERT INTO saved_searches (user_id, name, query) VALUES (?, '推し', 'favorite=true')", userID); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.EnableTOTP(userID, "SECRET", []string{"hash"}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec("INSERT INTO sessions (id, user_id) VALUES (?, ?), (?, ?)", username+"-1", userID, username+"-2", userID); err != nil {
+			t.Fatal(err)
+		}
+		// ログイン試行はログイン画面で入力された表記のまま記録される
+		if _, err := db.Exec("INSERT INTO login_attempts (username, ip_address, succeeded) VALUES (?, '192.0.2.1', 0), (?, '192.0.2.1', 1)", strings.ToUpper(username), username); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deleteID, keepID := userIDs[0], userIDs[1]
+
+	if err := repo.Delete(deleteID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	counts := []struct {
+		name  string
+		query string
+		want  int
+	}{
+		{name: "users", query: "SELECT COUNT(*) FROM users WHERE id = ?", want: 0},
+		{name: "talents", query: "SELECT COUNT(*) FROM talents WHERE user_id = ?", want: 0},
+		{name: "adjustments", query: "SELECT COUNT(*) FROM adjustments WHERE talent_id IN (SELECT id FROM talents WHERE user_id = ?)", want: 0},
+		{name: "recovery_codes", query: "SELECT COUNT(*) FROM recovery_codes WHERE user_id = ?", want: 0},
+		{name: "score_dimensions", query: "SELECT COUNT(*) FROM score_dimensions WHERE user_id = ?", want: 0},
+		{name: "tags", query: "SELECT COUNT(*) FROM tags WHERE user_id = ?", want: 0},
+		{name: "affiliations", query: "SELECT COUNT(*) FROM affiliations WHERE user_id = ?", want: 0},
+		{name: "saved_searches", query: "SELECT COUNT(*) FROM saved_searches WHERE user_id = ?", want: 0},
+		{name: "sessions", query: "SELECT COUNT(*) FROM sessions WHERE user_id = ?", want: 0},
+	}
+	for _, c := range counts {
+		var got int
+		if err := db.QueryRow(c.query, deleteID).Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want {
+			t.Errorf("Delete() remaining %s = %d, want %d", c.name, got, c.want)
+		}
+	}
+
+	var adjustments int
+	if err := db.QueryRow("SELECT COUNT(*) FROM adjustments").Scan(&adjustments); err != nil {
+		t.Fatal(err)
+	}
+	if adjustments != 2 {
+		t.Errorf("Delete() should keep other users' adjustments, count = %d, want 2", adjustments)
+	}
+
+	var scores int
+	if err := db.QueryRow("SELECT COUNT(*) FROM talent_scores").Scan(&scores); err != nil {
+		t.Fatal(err)
+	}
+	if scores != len(model.DefaultScoreDimensions) {
+		t.Errorf("Delete() should keep other users' scores, count = %d, want %d", scores, len(model.DefaultScoreDimensions))
+	}
+	var talentTags int
+	if err := db.QueryRow("SELECT COUNT(*) FROM talent_tags").Scan(&talentTags); err != nil {
+		t.Fatal(err)
+	}
+	if talentTags != 1 {
+		t.Errorf("Delete() should keep other users' talent tags, count = %d, want 1", talentTags)
+	}
+	var loginAttempts int
+	if err := db.QueryRow("SELECT COUNT(*) FROM login_attempts WHERE username = 'deleteme' COLLATE NOCASE").Scan(&loginAttempts); err != nil {
+		t.Fatal(err)
+	}
+	if loginAttempts != 0 {
+		t.Errorf("Delete() remaining login_attempts = %d, want 0", loginAttempts)
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM login_attempts").Scan(&loginAttempts); err != nil {
+		t.Fatal(err)
+	}
+	if loginAttempts != 2 {
+		t.Errorf("Delete() should keep other users' login attempts, count = %d, want 2", loginAttempts)
+	}
+	var sessions int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sessions WHERE user_id = ?", keepID).Scan(&sessions); err != nil {
+		t.Fatal(err)
+	}
+	if sessions != 2 {
+		t.Errorf("Delete() should keep other users' sessions, count = %d, want 2", sessions)
+	}
+	if _, err := repo.FindByID(keepID); err != nil {
+		t.Errorf("Delete() should keep other users, FindByID() error = %v", err)
+	}
+
+	if err := repo.Delete(deleteID); err != sql.ErrNoRows {
+		t.Errorf("Delete() for missing user error = %v, want %v", err, sql.ErrNoRows)
+	}
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	usernameMinLength = 2
+	usernameMaxLength = 32
+)
+
+// ErrDuplicateUsername は大文字小文字を区別せずに同じユーザー名が既に存在する場合に返る。
+var ErrDuplicateUsername = errors.New("このユーザー名は既に使われています")
+
+// UsernameError はユーザー名が規則に合わない理由を表す。
+type UsernameError struct {
+	Reason string
+}
+
+func (e *UsernameError) Error() string {
+	return e.Reason
+}
+
+// NormalizeUsername はユーザー名をNFKC正規化して前後の空白を取り除き、規則に合うか確認する。
+// 全角英数字は半角になる。大文字小文字は保持し、一意性の判定だけ区別しない。
+func NormalizeUsername(username string) (string, error) {
+	normalized := strings.TrimSpace(norm.NFKC.String(username))
+
+	length := utf8.RuneCountInString(normalized)
+	if length < usernameMinLength || length > usernameMaxLength {
+		return "", &UsernameError{Reason: fmt.Sprintf("ユーザー名は%d〜%d文字にしてください", usernameMinLength, usernameMaxLength)}
+	}
+
+	for _, r := range normalized {
+		if !isUsernameRune(r) {
+			return "", &UsernameError{Reason: "ユーザー名に使えるのは英数字、ひらがな、カタカナ、漢字と「_」「-」「.」だけです"}
+		}
+	}
+	return normalized, nil
+}
+
+// lookupUsername は検索に使うユーザー名を返す。
+// 規則が導入される前に登録された名前（1文字や空白入りなど）でもログインできるよう、
+// 正規化できない場合は前後の空白を取り除いた値をそのまま使う。
+func lookupUsername(username string) string {
+	if normalized, err := NormalizeUsername(username); err == nil {
+		return normalized
+	}
+	return strings.TrimSpace(username)
+}
+
+// isUsernameRune はユーザー名に使える文字か判定する。
+// 見た目が英字と紛らわしいキリル文字やギリシャ文字などは受け付けない。
+func isUsernameRune(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return true
+	case r == '_' || r == '-' || r == '.':
+		return true
+	case unicode.In(r, unicode.Hiragana, unicode.Katakana, unicode.Han):
+		return true
+	case r == 'ー' || r == '々':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+//go:build ignore
+
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"log"
+
+	"github.com/Kamekure-Maisuke/maiyumi/repository"
+	_ "modernc.org/sqlite"
+)
+
+func main() {
+	username := flag.String("user", "", "ロックを解除するユーザー名")
+	ip := flag.String("ip", "", "ロックを解除するIPアドレス")
+	flag.Parse()
+
+	if *username == "" && *ip == "" {
+		log.Fatal("-user または -ip を指定してください")
+	}
+
+	db, err := sql.Open("sqlite", "data.db")
+	if err != nil {
+		log.Fatalf("データベース接続エラー: %v", err)
+	}
+	defer db.Close()
+
+	repo := repository.NewLoginAttemptRepository(db, repository.LoginThrottlePolicy{})
+
+	if *username != "" {
+		// ログイン画面と同じく、試行は正規化したユーザー名で記録されている
+		if normalized, err := repository.NormalizeUsername(*username); err == nil {
+			*username = normalized
+		}
+		if err := repo.Unlock(*username); err != nil {
+			log.Fatalf("ユーザー %s のロック解除エラー: %v", *username, err)
+		}
+		log.Printf("ユーザー %s のロックを解除しました", *username)
+	}
+
+	if *ip != "" {
+		if err := repo.UnlockIP(*ip); err != nil {
+			log.Fatalf("IPアドレス %s のロック解除エラー: %v", *ip, err)
+		}
+		log.Printf("IPアドレス %s のロックを解除しました", *ip)
+	}
+}